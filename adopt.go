@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+)
+
+var (
+	adoptZone                = adoptCmd.Flag("zone", "Zone of the records to adopt").Required().String()
+	adoptRecordTypes         = adoptCmd.Flag("record-type", "Adopt records of this type; specify multiple times for multiple types").Default("A", "AAAA", "CNAME").Strings()
+	adoptHostnames           = adoptCmd.Flag("hostname", "Adopt records whose fully qualified name matches this glob, e.g. '*.apps.example.com'; specify multiple times for multiple globs, all records if unset").Strings()
+	adoptTXTPrefix           = adoptCmd.Flag("txt-prefix", "The --txt-prefix of external-dns").Default("").String()
+	adoptTXTSuffix           = adoptCmd.Flag("txt-suffix", "The --txt-suffix of external-dns").Default("").String()
+	adoptWildcardReplacement = adoptCmd.Flag("txt-wildcard-replacement", "The --txt-wildcard-replacement of external-dns").Default("").String()
+)
+
+// runAdopt creates the ownership records of the selected records of a zone for the external-dns
+// instance of --owner-id and exits non-zero on failure, with the exit code telling rejected
+// credentials and an unavailable Netcup API apart.
+func runAdopt(logger *slog.Logger) {
+	if *ownerID == "" {
+		logger.Error("adopting records requires --owner-id, the --txt-owner-id of external-dns")
+		os.Exit(exitConfigError)
+	}
+	providerOptions := []netcup.Option{netcup.WithTXTQuoting(netcup.TXTQuoting(*txtQuoting))}
+	if *zoneCredentialsFile != "" {
+		zoneCredentials, err := netcup.ReadZoneCredentialsFile(*zoneCredentialsFile)
+		if err != nil {
+			logger.Error("Failed to read zone credentials file", "path", *zoneCredentialsFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		providerOptions = append(providerOptions, netcup.WithZoneCredentials(zoneCredentials))
+	}
+
+	credentials, err := defaultCredentials(context.Background())
+	if err != nil {
+		logger.Error("Failed to read credentials", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	zones := []string{*adoptZone}
+	ncProvider, err := netcup.NewNetcupProvider(&zones, credentials.CustomerID, credentials.APIKey, credentials.APIPassword, *dryRun, logger, providerOptions...)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+
+	selector := netcup.AdoptSelector{RecordTypes: *adoptRecordTypes, Hostnames: *adoptHostnames}
+	registry := netcup.TXTRegistry{OwnerID: *ownerID, Prefix: *adoptTXTPrefix, Suffix: *adoptTXTSuffix, WildcardReplacement: *adoptWildcardReplacement}
+	if _, err := ncProvider.Adopt(context.Background(), zones[0], selector, registry); err != nil {
+		logger.Error("Failed to adopt records", "zone", *adoptZone, "error", err.Error())
+		os.Exit(exitCodeForError(err))
+	}
+}
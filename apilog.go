@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// levelTrace is below debug and only enabled to log API payloads with --log-api-payloads.
+const levelTrace = slog.LevelDebug - 4
+
+// sensitivePayloadKeys are the fields of CCP API payloads carrying credentials or session IDs.
+var sensitivePayloadKeys = []string{"apikey", "apipassword", "apisessionid"}
+
+// traceHandler enables the trace level in addition to the levels enabled by the wrapped handler.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h *traceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level == levelTrace || h.Handler.Enabled(ctx, level)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{h.Handler.WithGroup(name)}
+}
+
+// payloadLogTransport logs the bodies of API requests and responses at trace level with
+// credentials and session IDs removed.
+type payloadLogTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *payloadLogTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var payload []byte
+	if r.Body != nil {
+		var err error
+		payload, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.Body = io.NopCloser(bytes.NewReader(payload))
+	}
+	t.logger.Log(r.Context(), levelTrace, "API request", "method", r.Method, "url", r.URL.Redacted(), "payload", sanitizePayload(payload))
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		t.logger.Log(r.Context(), levelTrace, "API request failed", "url", r.URL.Redacted(), "error", err.Error())
+		return nil, err
+	}
+	payload, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	t.logger.Log(r.Context(), levelTrace, "API response", "url", r.URL.Redacted(), "status", resp.StatusCode, "payload", sanitizePayload(payload))
+	return resp, nil
+}
+
+// sanitizePayload redacts the sensitive fields of a JSON payload as well as any literal
+// occurrence of the configured secrets.
+func sanitizePayload(payload []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err == nil {
+		redactPayload(value)
+		var sanitized bytes.Buffer
+		encoder := json.NewEncoder(&sanitized)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err == nil {
+			payload = bytes.TrimSuffix(sanitized.Bytes(), []byte("\n"))
+		}
+	}
+	return string(scrubSecrets(payload))
+}
+
+func redactPayload(value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, v := range value {
+			if slices.Contains(sensitivePayloadKeys, strings.ToLower(key)) {
+				value[key] = "<redacted>"
+				continue
+			}
+			redactPayload(v)
+		}
+	case []any:
+		for _, v := range value {
+			redactPayload(v)
+		}
+	}
+}
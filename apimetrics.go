@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var apiRequestsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+	Name: "api_requests_total",
+	Help: "Total number of Netcup API requests by action and CCP status code, e.g. 2011 for failed logins or 4013 for invalid requests; 'error' if no response was received.",
+}, []string{"action", "status_code"})
+
+// apiMetricsTransport counts the requests to the CCP API by action and the status code of the
+// response. Other requests, e.g. drift notifications, are not counted.
+type apiMetricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *apiMetricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil {
+		return t.next.RoundTrip(r)
+	}
+	payload, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	var request struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(payload, &request); err != nil || request.Action == "" {
+		return t.next.RoundTrip(r)
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		apiRequestsTotal.WithLabelValues(request.Action, "error").Inc()
+		return nil, err
+	}
+	payload, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		apiRequestsTotal.WithLabelValues(request.Action, "error").Inc()
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	var response struct {
+		StatusCode int `json:"statuscode"`
+	}
+	status := "unknown"
+	if err := json.Unmarshal(payload, &response); err == nil && response.StatusCode != 0 {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	apiRequestsTotal.WithLabelValues(request.Action, status).Inc()
+	return resp, nil
+}
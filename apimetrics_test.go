@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIMetricsTransport(t *testing.T) {
+	api := netcuptest.NewServer()
+	defer api.Close()
+	api.AddZone("example.com", "300")
+	apiRequestsTotal.Reset()
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = &apiMetricsTransport{next: transport}
+	defer func() { http.DefaultTransport = transport }()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	zones := []string{"example.com"}
+	p, err := netcup.NewNetcupProvider(&zones, 10, "KEY", "PASSWORD", false, logger, netcup.WithAPIEndpoint(api.URL))
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	api.FailAction("login", 2011)
+	_, err = p.Records(context.TODO())
+	assert.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiRequestsTotal.WithLabelValues("login", "2000")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiRequestsTotal.WithLabelValues("login", "2011")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apiRequestsTotal.WithLabelValues("infoDnsRecords", "5029")))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/mrueg/external-dns-netcup-webhook/server"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
+)
+
+var (
+	benchDuration       = benchCmd.Flag("duration", "Time to send requests for").Default("10s").Duration()
+	benchConcurrency    = benchCmd.Flag("concurrency", "Number of clients sending requests concurrently").Default("4").Int()
+	benchZones          = benchCmd.Flag("zones", "Number of zones served by the fake Netcup API").Default("10").Int()
+	benchRecordsPerZone = benchCmd.Flag("records-per-zone", "Number of A records in each zone").Default("100").Int()
+	benchApplyRatio     = benchCmd.Flag("apply-ratio", "Fraction of requests applying changes instead of reading records").Default("0.2").Float64()
+)
+
+// benchResult collects the latencies and response codes of one kind of request.
+type benchResult struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    int
+}
+
+func (r *benchResult) record(latency time.Duration, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+	r.statuses[status]++
+}
+
+// percentile returns the latency below which the fraction p of the requests completed.
+func (r *benchResult) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	return r.latencies[int(p*float64(len(r.latencies)-1))]
+}
+
+// runBench serves the webhook API backed by a fake Netcup API with synthetic zones, sends a mix of
+// record reads and change sets at the configured concurrency and reports the latency percentiles.
+// The provider and the webhook use the flags of the serve command, e.g. --max-concurrent-applies
+// or --session-keepalive-interval, so their effect can be compared.
+func runBench(logger *slog.Logger) {
+	if *benchConcurrency < 1 || *benchZones < 1 || *benchApplyRatio < 0 || *benchApplyRatio > 1 {
+		logger.Error("concurrency and zones must be positive and the apply ratio between 0 and 1")
+		os.Exit(exitConfigError)
+	}
+
+	api := netcuptest.NewServer()
+	defer api.Close()
+	zones := make([]string, *benchZones)
+	for i := range zones {
+		zones[i] = fmt.Sprintf("zone%d.example", i)
+		records := make([]nc.DnsRecord, *benchRecordsPerZone)
+		for j := range records {
+			records[j] = nc.DnsRecord{Hostname: fmt.Sprintf("host%d", j), Type: endpoint.RecordTypeA, Destination: fmt.Sprintf("192.0.2.%d", j%254+1)}
+		}
+		api.AddZone(zones[i], "300", records...)
+	}
+
+	// the provider logs every zone it reads, which would drown the report
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	options := []netcup.Option{netcup.WithAPIEndpoint(api.URL)}
+	if *sessionKeepAlive > 0 {
+		options = append(options, netcup.WithSessionKeepAlive(*sessionKeepAlive))
+	}
+	if *maxRecordsPerRequest > 0 {
+		options = append(options, netcup.WithMaxRecordsPerRequest(*maxRecordsPerRequest))
+	}
+	providers, err := newReloadableProvider(func() (*netcup.NetcupProvider, error) {
+		return netcup.NewNetcupProvider(&zones, *customerID, *apiKey, *apiPassword, false, quiet, options...)
+	}, quiet)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	defer providers.Load().Close()
+
+	webhookServer := httptest.NewServer(server.RecoverHandler(server.NewWebhookMux(providers,
+		server.WithLogger(quiet),
+		server.WithMaxBodySize(int64(*maxRequestBodySize)),
+		server.WithApplyLimit(*maxConcurrentApplies, *applyQueueSize)), nil, quiet))
+	defer webhookServer.Close()
+
+	logger.Info("running benchmark", "duration", benchDuration.String(), "concurrency", *benchConcurrency, "zones", *benchZones, "records_per_zone", *benchRecordsPerZone, "apply_ratio", *benchApplyRatio)
+	results := map[string]*benchResult{
+		"records": {statuses: map[int]int{}},
+		"apply":   {statuses: map[int]int{}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *benchDuration)
+	defer cancel()
+	var wg sync.WaitGroup
+	for client := range *benchConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every client creates and deletes its own record, so the zones keep their size
+			ep := endpoint.NewEndpoint(fmt.Sprintf("bench%d.%s", client, zones[client%len(zones)]), endpoint.RecordTypeA, "198.51.100.1")
+			created := false
+			for ctx.Err() == nil {
+				if rand.Float64() < *benchApplyRatio {
+					changes := &plan.Changes{Create: []*endpoint.Endpoint{ep}}
+					if created {
+						changes = &plan.Changes{Delete: []*endpoint.Endpoint{ep}}
+					}
+					start := time.Now()
+					status, err := benchRequest(ctx, webhookServer.Client(), http.MethodPost, webhookServer.URL+"/records", changes)
+					if ctx.Err() != nil {
+						return
+					}
+					if status == http.StatusNoContent {
+						created = !created
+					}
+					results["apply"].record(time.Since(start), status, err)
+					continue
+				}
+				start := time.Now()
+				status, err := benchRequest(ctx, webhookServer.Client(), http.MethodGet, webhookServer.URL+"/records", nil)
+				if ctx.Err() != nil {
+					return
+				}
+				results["records"].record(time.Since(start), status, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST\tCOUNT\tPER SECOND\tP50\tP90\tP99\tMAX\tSTATUS CODES\tERRORS")
+	for _, name := range []string{"records", "apply"} {
+		r := results[name]
+		slices.Sort(r.latencies)
+		var statuses []string
+		for _, status := range slices.Sorted(maps.Keys(r.statuses)) {
+			statuses = append(statuses, strconv.Itoa(status)+":"+strconv.Itoa(r.statuses[status]))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%v\t%d\n", name, len(r.latencies), float64(len(r.latencies))/benchDuration.Seconds(),
+			r.percentile(0.5), r.percentile(0.9), r.percentile(0.99), r.percentile(1), statuses, r.errors)
+	}
+	_ = w.Flush()
+	fmt.Printf("Netcup API calls: login %d, infoDnsZone %d, infoDnsRecords %d, updateDnsRecords %d\n",
+		api.Calls("login"), api.Calls("infoDnsZone"), api.Calls("infoDnsRecords"), api.Calls("updateDnsRecords"))
+}
+
+// benchRequest sends a webhook API request and returns the status code.
+func benchRequest(ctx context.Context, client *http.Client, method, url string, body any) (int, error) {
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		payload = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", webhook.MediaTypeFormatAndVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", webhook.MediaTypeFormatAndVersion)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// configSource is where the value of a setting came from.
+type configSource string
+
+const (
+	sourceDefault   configSource = "default"
+	sourceEnv       configSource = "env"
+	sourceFlag      configSource = "flag"
+	sourceConfigDir configSource = "config-dir"
+)
+
+// setting is the resolved value of a flag.
+type setting struct {
+	Name  string
+	Value string
+	// Source is where the value came from.
+	Source configSource
+	// Origin is the flag, environment variable or configuration directory file the value was read from.
+	Origin string
+}
+
+// flagSources parses the flags of app and reports where their values came from: the command line,
+// the environment, a file of the configuration directory or the defaults, in this order of
+// precedence. The values themselves are read from the flags as usual.
+type flagSources struct {
+	app  *kingpin.Application
+	args []string
+	// files maps the environment variables set from the configuration directory to their file.
+	files map[string]string
+}
+
+func newFlagSources(app *kingpin.Application, args []string) *flagSources {
+	return &flagSources{app: app, args: args, files: map[string]string{}}
+}
+
+// parse applies the environment prefix and the configuration directory, then parses the flags and
+// returns the selected command.
+func (r *flagSources) parse() (string, error) {
+	applyEnvPrefix(r.app, r.args)
+	files, err := applyConfigDir(r.app, r.args)
+	if err != nil {
+		return "", err
+	}
+	r.files = files
+	return r.app.Parse(r.args)
+}
+
+// source returns where the value of f came from.
+func (r *flagSources) source(f *kingpin.FlagModel) (configSource, string) {
+	if arg, ok := flagSet(r.args, f); ok {
+		return sourceFlag, arg
+	}
+	if f.Envar != "" && os.Getenv(f.Envar) != "" {
+		if path, ok := r.files[f.Envar]; ok {
+			return sourceConfigDir, path
+		}
+		return sourceEnv, f.Envar
+	}
+	return sourceDefault, ""
+}
+
+// settings returns the resolved global flags, with secrets replaced by their fingerprint.
+func (r *flagSources) settings() []setting {
+	var settings []setting
+	for _, f := range r.app.Model().Flags {
+		if f.Hidden || f.Name == "help" || f.Name == "version" {
+			continue
+		}
+		source, origin := r.source(f)
+		settings = append(settings, setting{Name: f.Name, Value: displayValue(f), Source: source, Origin: origin})
+	}
+	return settings
+}
+
+// explain writes a table of the settings and where their values came from to w.
+func (r *flagSources) explain(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE\tORIGIN")
+	for _, s := range r.settings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.Value, s.Source, s.Origin)
+	}
+	return tw.Flush()
+}
+
+// flagSet reports whether f is set in args, also in its negated form for boolean flags, and
+// returns the flag as it was given.
+func flagSet(args []string, f *kingpin.FlagModel) (string, bool) {
+	for _, arg := range args {
+		if arg == "--" {
+			return "", false
+		}
+		name, ok := strings.CutPrefix(arg, "--")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "=")
+		if name == f.Name || f.IsBoolFlag() && name == "no-"+f.Name {
+			return "--" + name, true
+		}
+	}
+	return "", false
+}
+
+// runConfigExplain prints every setting, its value and where it came from.
+func runConfigExplain(logger *slog.Logger) {
+	if err := configs.explain(os.Stdout); err != nil {
+		logger.Error("Failed to write settings", "error", err.Error())
+		os.Exit(exitFailure)
+	}
+}
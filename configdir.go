@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// applyConfigDir sets flags from the files in the directory given by --config-dir in args or its
+// environment variable, one file per flag, as projected from a Kubernetes Secret or ConfigMap. A
+// file is named after the environment variable of its flag, e.g. NETCUP_API_KEY, or after the flag,
+// e.g. netcup-api-key, and holds the value, or one value per line for repeatable flags.
+//
+// Values are taken in this order: command line, environment, configuration directory, default. The
+// files only fill environment variables that are unset, so kingpin applies this order when parsing.
+// It returns the environment variables it set with the files they were read from.
+func applyConfigDir(app *kingpin.Application, args []string) (map[string]string, error) {
+	dirFlag := app.GetFlag("config-dir").Model()
+	dir, ok := flagArg(args, dirFlag.Name)
+	if !ok {
+		dir = os.Getenv(dirFlag.Envar)
+	}
+	if dir == "" {
+		return nil, nil
+	}
+
+	envars := map[string]string{}
+	for _, f := range app.Model().Flags {
+		if f.Envar != "" {
+			envars[f.Envar] = f.Envar
+			envars[f.Name] = f.Envar
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration directory: %w", err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		// Kubernetes keeps the projected files in hidden directories and links to them
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		envar, ok := envars[entry.Name()]
+		if !ok {
+			return nil, fmt.Errorf("configuration file %s does not match the environment variable or name of a flag", path)
+		}
+		if _, set := os.LookupEnv(envar); set {
+			continue
+		}
+		value, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read configuration file: %w", err)
+		}
+		if err := os.Setenv(envar, strings.TrimSpace(string(value))); err != nil {
+			return nil, err
+		}
+		files[envar] = path
+	}
+	return files, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+)
+
+var (
+	createZoneName   = createZoneCmd.Flag("zone", "Zone of the newly registered domain").Required().String()
+	createZoneTTL    = createZoneCmd.Flag("ttl", "Default TTL of the records of the zone in seconds").Default("300").Int64()
+	createZoneDNSSEC = createZoneCmd.Flag("dnssec", "Sign the zone with DNSSEC").Default("true").Bool()
+)
+
+// runCreateZone sets up the zone of a newly registered domain for external-dns and exits non-zero
+// on failure, with the exit code telling rejected credentials and an unavailable Netcup API apart.
+func runCreateZone(logger *slog.Logger) {
+	if *createZoneTTL <= 0 {
+		logger.Error("TTL must be positive", "ttl", *createZoneTTL)
+		os.Exit(exitConfigError)
+	}
+	var providerOptions []netcup.Option
+	if *zoneCredentialsFile != "" {
+		zoneCredentials, err := netcup.ReadZoneCredentialsFile(*zoneCredentialsFile)
+		if err != nil {
+			logger.Error("Failed to read zone credentials file", "path", *zoneCredentialsFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		providerOptions = append(providerOptions, netcup.WithZoneCredentials(zoneCredentials))
+	}
+
+	credentials, err := defaultCredentials(context.Background())
+	if err != nil {
+		logger.Error("Failed to read credentials", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	zones := []string{*createZoneName}
+	ncProvider, err := netcup.NewNetcupProvider(&zones, credentials.CustomerID, credentials.APIKey, credentials.APIPassword, *dryRun, logger, providerOptions...)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+
+	settings := netcup.ZoneSettings{TTL: *createZoneTTL, DNSSEC: *createZoneDNSSEC}
+	if err := ncProvider.PrepareZone(zones[0], settings); err != nil {
+		logger.Error("Failed to set up zone", "zone", *createZoneName, "error", err.Error())
+		os.Exit(exitCodeForError(err))
+	}
+}
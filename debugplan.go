@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/server"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// changePlanner plans changes without applying them.
+type changePlanner interface {
+	PlanChanges(ctx context.Context, changes *plan.Changes) (*netcup.ChangePlan, error)
+}
+
+// planHandler serves POST /debug/plan, authenticated with a bearer token: it accepts the changes
+// payload external-dns sends to /records and responds with the records the provider would send to
+// the Netcup API for them, without applying them.
+func planHandler(planner changePlanner, token string, maxBodySize int64, proxies []netip.Prefix, logger *slog.Logger) http.Handler {
+	return requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		var changes plan.Changes
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&changes); err != nil {
+			http.Error(w, "invalid changes: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := planner.PlanChanges(r.Context(), &changes)
+		if err != nil {
+			logger.Error("Failed to plan changes", "error", err.Error())
+			server.WriteProviderError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}), token, proxies, logger)
+}
+
+// requireToken passes only requests with token as bearer token on to next, for the debug routes
+// of the webhook server.
+func requireToken(next http.Handler, token string, proxies []netip.Prefix, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			logger.Warn("rejecting unauthorized debug request", "path", r.URL.Path, "client", server.ClientIP(r, proxies))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// defaultEnvPrefix is the prefix of the environment variables declared for the flags.
+const defaultEnvPrefix = "NETCUP"
+
+// applyEnvPrefix renames the environment variables of all flags to start with the prefix given by
+// --env-prefix in args instead of NETCUP. kingpin reads the environment while parsing the flags, so
+// the prefix is taken from args before.
+func applyEnvPrefix(app *kingpin.Application, args []string) {
+	prefix, ok := flagArg(args, "env-prefix")
+	if !ok || prefix == defaultEnvPrefix {
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	rename := func(flags []*kingpin.FlagModel, clause func(name string) *kingpin.FlagClause) {
+		for _, f := range flags {
+			if name, ok := strings.CutPrefix(f.Envar, defaultEnvPrefix+"_"); ok {
+				clause(f.Name).Envar(prefix + name)
+			}
+		}
+	}
+	model := app.Model()
+	rename(model.Flags, app.GetFlag)
+	for _, cmd := range model.Commands {
+		rename(cmd.Flags, app.GetCommand(cmd.Name).GetFlag)
+	}
+}
+
+// flagArg returns the value of the flag name in args, if set, for flags needed before parsing.
+func flagArg(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return "", false
+		case arg == "--"+name && i+1 < len(args):
+			return args[i+1], true
+		case strings.HasPrefix(arg, "--"+name+"="):
+			return strings.TrimPrefix(arg, "--"+name+"="), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+)
+
+// Exit codes of the commands, so scripts and CI pipelines can tell failures apart without parsing
+// the logs.
+const (
+	// exitFailure is returned for failures without a more specific exit code.
+	exitFailure = 1
+	// exitConfigError is returned for invalid flags and configuration files, including usage errors
+	// reported by kingpin.
+	exitConfigError = 2
+	// exitAuthError is returned if the Netcup API rejected the credentials.
+	exitAuthError = 3
+	// exitAPIUnavailable is returned if the Netcup API could not be reached, failed or rate limited
+	// the webhook.
+	exitAPIUnavailable = 4
+	// exitDriftDetected is returned by commands comparing records if they found differences.
+	exitDriftDetected = 5
+)
+
+// exitCodeForError returns the exit code for an error of the provider.
+func exitCodeForError(err error) int {
+	switch {
+	case errors.Is(err, netcup.ErrAuth):
+		return exitAuthError
+	case errors.Is(err, netcup.ErrBackendUnavailable), errors.Is(err, netcup.ErrRateLimited):
+		return exitAPIUnavailable
+	default:
+		return exitFailure
+	}
+}
@@ -0,0 +1,44 @@
+// Package metrics creates the metrics of the webhook, so all of them share the external_dns_netcup
+// namespace.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Namespace prefixes the names of all metrics of the webhook.
+const Namespace = "external_dns_netcup"
+
+// NewCounter creates a counter in the namespace of the webhook. Counter names end in _total.
+func NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	opts.Namespace = Namespace
+	return prometheus.NewCounter(opts)
+}
+
+// NewCounterVec creates a counter vector in the namespace of the webhook. Counter names end in _total.
+func NewCounterVec(opts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	opts.Namespace = Namespace
+	return prometheus.NewCounterVec(opts, labelNames)
+}
+
+// NewGauge creates a gauge in the namespace of the webhook.
+func NewGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	opts.Namespace = Namespace
+	return prometheus.NewGauge(opts)
+}
+
+// NewGaugeVec creates a gauge vector in the namespace of the webhook.
+func NewGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	opts.Namespace = Namespace
+	return prometheus.NewGaugeVec(opts, labelNames)
+}
+
+// NewDesc describes a metric in the namespace of the webhook for collectors computing their
+// metrics when scraped.
+func NewDesc(name, help string, labelNames []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", name), help, labelNames, nil)
+}
+
+// NewHistogramVec creates a histogram vector in the namespace of the webhook.
+func NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *prometheus.HistogramVec {
+	opts.Namespace = Namespace
+	return prometheus.NewHistogramVec(opts, labelNames)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// logBuffer keeps the most recent log lines in memory to be served at /debug/logs.
+type logBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{lines: make([][]byte, size)}
+}
+
+// Write stores p as a single line. slog handlers write each record with a single call.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = append([]byte{}, p...)
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+	return len(p), nil
+}
+
+// ServeHTTP returns the buffered lines as JSON lines, oldest first.
+func (b *logBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	lines := append([][]byte{}, b.lines[:b.next]...)
+	if b.full {
+		lines = append(append([][]byte{}, b.lines[b.next:]...), lines...)
+	}
+	b.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	for _, line := range lines {
+		_, _ = w.Write(line)
+	}
+}
+
+// teeHandler sends records to two handlers. The primary handler decides which levels are enabled.
+type teeHandler struct {
+	primary   slog.Handler
+	secondary slog.Handler
+}
+
+func (h *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.primary.Enabled(ctx, level)
+}
+
+func (h *teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	return errors.Join(h.primary.Handle(ctx, r.Clone()), h.secondary.Handle(ctx, r))
+}
+
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &teeHandler{primary: h.primary.WithAttrs(attrs), secondary: h.secondary.WithAttrs(attrs)}
+}
+
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	return &teeHandler{primary: h.primary.WithGroup(name), secondary: h.secondary.WithGroup(name)}
+}
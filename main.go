@@ -2,36 +2,142 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
 	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/server"
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
 	cversion "github.com/prometheus/client_golang/prometheus/collectors/version"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
-	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"sigs.k8s.io/external-dns/endpoint"
 )
 
 var (
-	listenAddr        = kingpin.Flag("listen-address", "The address this plugin listens on").Default(":8888").Envar("NETCUP_LISTEN_ADDRESS").String()
-	metricsListenAddr = kingpin.Flag("metrics-listen-address", "The address this plugin provides metrics on").Default(":8889").Envar("NETCUP_METRICS_LISTEN_ADDRESS").String()
+	_                 = kingpin.Flag("config-dir", "Directory with one file per flag, named after its environment variable or the flag, e.g. a mounted Kubernetes Secret; the command line and environment take precedence").Default("").Envar("NETCUP_CONFIG_DIR").String()
+	_                 = kingpin.Flag("env-prefix", "Prefix of the environment variables read for flags, e.g. EDNS_NETCUP to read EDNS_NETCUP_API_KEY instead of NETCUP_API_KEY").Default(defaultEnvPrefix).String()
+	listenAddr        = kingpin.Flag("listen-address", "The address this plugin listens on; specify multiple times to listen on multiple addresses").Default(":8888").Envar("NETCUP_LISTEN_ADDRESS").Strings()
+	metricsListenAddr = kingpin.Flag("metrics-listen-address", "The address this plugin provides metrics on; specify multiple times to listen on multiple addresses, set to 'none' to disable the metrics server").Default(":8889").Envar("NETCUP_METRICS_LISTEN_ADDRESS").Strings()
 	tlsConfig         = kingpin.Flag("tls-config", "Path to TLS config file.").Envar("NETCUP_TLS_CONFIG").Default("").String()
+	disableMetrics    = kingpin.Flag("disable-metrics", "Do not start the metrics server").Default("false").Envar("NETCUP_DISABLE_METRICS").Bool()
+	singleListener    = kingpin.Flag("single-listener", "Serve metrics and the landing page on the webhook listener instead of a separate metrics listener").Default("false").Envar("NETCUP_SINGLE_LISTENER").Bool()
+	enableH2C         = kingpin.Flag("h2c", "Accept HTTP/2 without TLS (h2c) on the webhook listener; HTTP/2 over TLS is configured in the TLS config file").Default("true").Envar("NETCUP_H2C").Bool()
+	recordsETag       = kingpin.Flag("records-etag", "Send the records with an ETag and answer requests for unchanged records with 304 Not Modified, for clients other than external-dns, which never sends If-None-Match; the records are no longer streamed").Default("false").Envar("NETCUP_RECORDS_ETAG").Bool()
+	trustedProxyFlag  = kingpin.Flag("trusted-proxies", "Address or network in CIDR notation of a proxy in front of the webhook, e.g. a mesh sidecar, whose X-Forwarded-For header names the client in logs; specify multiple times for multiple proxies").Envar("NETCUP_TRUSTED_PROXIES").Strings()
+	compressResponses = kingpin.Flag("compress-responses", "Compress the responses of the webhook listener with zstd or gzip for clients accepting it").Default("true").Envar("NETCUP_COMPRESS_RESPONSES").Bool()
+	internalPrefix    = kingpin.Flag("internal-path-prefix", "Path prefix for metrics and the landing page on the webhook listener in single-listener mode").Default("/internal").Envar("NETCUP_INTERNAL_PATH_PREFIX").String()
 
-	domainFilter = kingpin.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains").Required().Envar("NETCUP_DOMAIN_FILTER").Strings()
-	dryRun       = kingpin.Flag("dry-run", "Run without connecting to Netcup's CCP API").Default("false").Envar("NETCUP_DRY_RUN").Bool()
-	customerID   = kingpin.Flag("netcup-customer-id", "The Netcup customer id").Required().Envar("NETCUP_CUSTOMER_ID").Int()
-	apiKey       = kingpin.Flag("netcup-api-key", "The api key to connect to Netcup's CCP API").Required().Envar("NETCUP_API_KEY").String()
-	apiPassword  = kingpin.Flag("netcup-api-password", "The api password to connect to Netcup's CCP API").Required().Envar("NETCUP_API_PASSWORD").String()
+	domainFilter        = kingpin.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains").Envar("NETCUP_DOMAIN_FILTER").Strings()
+	dryRun              = kingpin.Flag("dry-run", "Run without connecting to Netcup's CCP API").Default("false").Envar("NETCUP_DRY_RUN").Bool()
+	customerID          = kingpin.Flag("netcup-customer-id", "The Netcup customer id").Envar("NETCUP_CUSTOMER_ID").Int()
+	apiKey              = kingpin.Flag("netcup-api-key", "The api key to connect to Netcup's CCP API").Envar("NETCUP_API_KEY").String()
+	apiPassword         = kingpin.Flag("netcup-api-password", "The api password to connect to Netcup's CCP API").Envar("NETCUP_API_PASSWORD").String()
+	validateCredentials = kingpin.Flag("validate-credentials-on-startup", "Log in and out with all credentials at startup and exit if the Netcup API rejects them, instead of failing on the first poll of external-dns").Default("true").Envar("NETCUP_VALIDATE_CREDENTIALS_ON_STARTUP").Bool()
+	credentialsSource   = kingpin.Flag("credentials-source", "Secret holding the Netcup credentials (customerID, apiKey, apiPassword) not set by flags: file:///path, aws-secretsmanager://<name or ARN>[?region=<region>] or gcp-secretmanager://projects/<project>/secrets/<secret>[/versions/<version>]").Default("").Envar("NETCUP_CREDENTIALS_SOURCE").String()
+	zoneCredentialsFile = kingpin.Flag("zone-credentials-file", "Path to a YAML file mapping zones to separate Netcup credentials (customerID, apiKey, apiPassword)").Default("").Envar("NETCUP_ZONE_CREDENTIALS_FILE").String()
+
+	domainFilterFile         = kingpin.Flag("domain-filter-file", "Path to a file containing one zone per line, added to --domain-filter and watched for changes; external-dns picks up changes only when restarted").Default("").Envar("NETCUP_DOMAIN_FILTER_FILE").String()
+	domainFilterFileInterval = kingpin.Flag("domain-filter-file-interval", "Interval to check the domain filter file for changes").Default("30s").Envar("NETCUP_DOMAIN_FILTER_FILE_INTERVAL").Duration()
+	shardIndex               = kingpin.Flag("shard-index", "Index of the shard of zones served by this instance").Default("0").Envar("NETCUP_SHARD_INDEX").Int()
+	shardCount               = kingpin.Flag("shard-count", "Total number of shards to distribute the zones across").Default("1").Envar("NETCUP_SHARD_COUNT").Int()
+	changeHistorySize        = kingpin.Flag("change-history-size", "Number of applied change batches kept in memory and served at /debug/changes with --debug-token; 0 disables the history").Default("100").Envar("NETCUP_CHANGE_HISTORY_SIZE").Int()
+	encryptionKey            = kingpin.Flag("encryption-key", "Key to encrypt the change journal and the 'file' record cache with AES-256-GCM: file:///path, or a data key encrypted with aws-kms:///path[?region=<region>] or gcp-kms:///path?key=projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>").Default("").Envar("NETCUP_ENCRYPTION_KEY").String()
+	changeJournalFile        = kingpin.Flag("change-journal-file", "Path to an append-only JSON lines file persisting every applied change batch").Default("").Envar("NETCUP_CHANGE_JOURNAL_FILE").String()
+	changeJournalRetention   = kingpin.Flag("change-journal-retention", "Age after which entries are removed from the change journal; 0 keeps all entries").Default("720h").Envar("NETCUP_CHANGE_JOURNAL_RETENTION").Duration()
+	requireApproval          = kingpin.Flag("require-approval", "Park changes until an operator approves them via /admin/approvals").Default("false").Envar("NETCUP_REQUIRE_APPROVAL").Bool()
+	approvalTTL              = kingpin.Flag("approval-ttl", "Time after which unapproved changes expire").Default("1h").Envar("NETCUP_APPROVAL_TTL").Duration()
+	debugToken               = kingpin.Flag("debug-token", "Bearer token required to access /debug/plan, /debug/changes, /debug/logs, /debug/shadow and /debug/consistency, which are only served if set").Default("").Envar("NETCUP_DEBUG_TOKEN").String()
+	approvalToken            = kingpin.Flag("approval-token", "Bearer token required to access /admin/approvals").Default("").Envar("NETCUP_APPROVAL_TOKEN").String()
+	protectDeletes           = kingpin.Flag("protect-deletes", "Keep all records external-dns wants to delete or replace, logging and counting them instead; creates and updates adding targets are still applied").Default("false").Envar("NETCUP_PROTECT_DELETES").Bool()
+	companionRecordsFile     = kingpin.Flag("companion-records-file", "Path to a YAML file with rules creating companion records, e.g. a CAA record or a NAT64 AAAA record, along with the records they match").Default("").Envar("NETCUP_COMPANION_RECORDS_FILE").String()
+	targetHealthPort         = kingpin.Flag("target-health-port", "TCP port A and AAAA targets must accept connections on to be published; 0 disables the health check").Default("0").Envar("NETCUP_TARGET_HEALTH_PORT").Uint16()
+	targetHealthTimeout      = kingpin.Flag("target-health-timeout", "Timeout of the connection attempts of --target-health-port").Default("2s").Envar("NETCUP_TARGET_HEALTH_TIMEOUT").Duration()
+	missingZoneBackoff       = kingpin.Flag("missing-zone-backoff", "Time zones of the domain filter that are missing from the Netcup account are skipped for before they are queried again").Default("10m").Envar("NETCUP_MISSING_ZONE_BACKOFF").Duration()
+	zoneLock                 = kingpin.Flag("zone-lock", "Lock taken per zone while applying changes, to serialize the changes of instances sharing zones: 'none', 'file' in --zone-lock-dir, or a Kubernetes 'lease'").Default("none").Envar("NETCUP_ZONE_LOCK").Enum("none", "file", "lease")
+	zoneLockDir              = kingpin.Flag("zone-lock-dir", "Directory of the 'file' zone locks, e.g. on a volume shared by the instances").Default("").Envar("NETCUP_ZONE_LOCK_DIR").String()
+	zoneLockNamespace        = kingpin.Flag("zone-lock-namespace", "Namespace of the 'lease' zone locks; defaults to the namespace of the pod").Default("").Envar("NETCUP_ZONE_LOCK_NAMESPACE").String()
+	zoneLockHolder           = kingpin.Flag("zone-lock-holder", "Identity of this instance in the zone locks; defaults to the hostname").Default("").Envar("NETCUP_ZONE_LOCK_HOLDER").String()
+	zoneLockTTL              = kingpin.Flag("zone-lock-ttl", "Time after which a zone lock not renewed by its holder, e.g. because it crashed, is taken over").Default("30s").Envar("NETCUP_ZONE_LOCK_TTL").Duration()
+	zoneLockTimeout          = kingpin.Flag("zone-lock-timeout", "Maximum time to wait for a zone lock held by another instance before external-dns retries on its next run").Default("1m").Envar("NETCUP_ZONE_LOCK_TIMEOUT").Duration()
+	recordCache              = kingpin.Flag("record-cache", "Backend caching the records of zones between syncs: 'none', 'memory', or 'file' to share the cache with other replicas through --record-cache-dir").Default("none").Envar("NETCUP_RECORD_CACHE").Enum("none", "memory", "file")
+	recordCacheTTL           = kingpin.Flag("record-cache-ttl", "How long cached records are used before they are read from the Netcup API again").Default("1m").Envar("NETCUP_RECORD_CACHE_TTL").Duration()
+	recordCacheDir           = kingpin.Flag("record-cache-dir", "Directory of the 'file' record cache").Default("").Envar("NETCUP_RECORD_CACHE_DIR").String()
+	minZoneRefreshInterval   = kingpin.Flag("min-zone-refresh-interval", "Minimum interval between reads of a zone from the Netcup API; polls of external-dns in between are answered from memory. 0 reads zones on every poll").Default("0s").Envar("NETCUP_MIN_ZONE_REFRESH_INTERVAL").Duration()
+	recordCacheStaleStart    = kingpin.Flag("record-cache-stale-start", "Serve expired cached records once after a start while refreshing them in the background, so the first sync after a restart does not wait for the Netcup API").Default("false").Envar("NETCUP_RECORD_CACHE_STALE_START").Bool()
+	policyFile               = kingpin.Flag("policy-file", "Path to a YAML file with rules allowing or denying changes by hostname, record type and action").Default("").Envar("NETCUP_POLICY_FILE").String()
+	canaryZone               = kingpin.Flag("canary-zone", "Zone to apply changes to first; other zones are only changed if the changes to this zone could be verified").Default("").Envar("NETCUP_CANARY_ZONE").String()
+	shadowSource             = kingpin.Flag("shadow-source", "Run in shadow mode: never apply changes and compare the records with the JSON endpoint list at this URL or file path, reporting at /debug/shadow with --debug-token").Default("").Envar("NETCUP_SHADOW_SOURCE").String()
+	ownerID                  = kingpin.Flag("owner-id", "TXT owner ID of external-dns; updates and deletions of records owned by another ID are refused").Default("").Envar("NETCUP_OWNER_ID").String()
+	nameRewrites             = kingpin.Flag("name-rewrite", "Rewrite endpoint names for a Netcup zone named differently than in the cluster, e.g. '*.internal.example.com=internal-*.example.com'; records are mapped back when read. Specify multiple times for multiple rewrites, the first matching one applies").Envar("NETCUP_NAME_REWRITE").Strings()
+	includeOwners            = kingpin.Flag("include-owner", "Only show records owned by this TXT owner ID, or without a known owner, to external-dns; specify multiple times for multiple owners").Envar("NETCUP_INCLUDE_OWNER").Strings()
+	excludeOwners            = kingpin.Flag("exclude-owner", "Hide records owned by this TXT owner ID from external-dns; specify multiple times for multiple owners").Envar("NETCUP_EXCLUDE_OWNER").Strings()
+	managedRecordTypes       = kingpin.Flag("managed-record-types", "Record type to manage; specify multiple times for multiple types. TXT is always managed, all supported types if unset").Envar("NETCUP_MANAGED_RECORD_TYPES").Strings()
+	extraRecordTypes         = kingpin.Flag("extra-record-types", "Additional record type accepted by the Netcup API, e.g. HTTPS or SVCB, whose values are passed through unchanged; specify multiple times for multiple types").Envar("NETCUP_EXTRA_RECORD_TYPES").Strings()
+	detectDrift              = kingpin.Flag("detect-drift", "Report records changed outside of external-dns, e.g. in the CCP web interface, between two polls").Default("false").Envar("NETCUP_DETECT_DRIFT").Bool()
+	driftNotifyURL           = kingpin.Flag("drift-notify-url", "URL to post detected drift to as JSON; implies --detect-drift").Default("").Envar("NETCUP_DRIFT_NOTIFY_URL").String()
+	checkDelegation          = kingpin.Flag("check-delegation", "Warn at startup about managed zones whose NS records do not point to Netcup's nameservers").Default("true").Envar("NETCUP_CHECK_DELEGATION").Bool()
+	delegationNameserver     = kingpin.Flag("delegation-nameserver", "Nameserver to look up NS records for --check-delegation; defaults to the system resolver").Default("").Envar("NETCUP_DELEGATION_NAMESERVER").String()
+	consistencyInterval      = kingpin.Flag("consistency-check-interval", "Interval to compare a sample of records with the answers of Netcup's nameservers; 0 disables the periodic check").Default("0s").Envar("NETCUP_CONSISTENCY_CHECK_INTERVAL").Duration()
+	consistencySampleSize    = kingpin.Flag("consistency-sample-size", "Number of records resolved per consistency check; 0 checks all records").Default("20").Envar("NETCUP_CONSISTENCY_SAMPLE_SIZE").Int()
+	consistencyEndpoint      = kingpin.Flag("consistency-endpoint", "Serve on-demand consistency checks at /debug/consistency, authenticated with --debug-token").Default("false").Envar("NETCUP_CONSISTENCY_ENDPOINT").Bool()
+	consistencyNameserver    = kingpin.Flag("consistency-nameserver", "Nameserver queried by the consistency check").Default("root-dns.netcup.net").Envar("NETCUP_CONSISTENCY_NAMESERVER").String()
+	maxRequestBodySize       = kingpin.Flag("max-request-body-size", "Maximum size of request bodies accepted by the webhook API").Default("10MiB").Envar("NETCUP_MAX_REQUEST_BODY_SIZE").Bytes()
+	txtQuoting               = kingpin.Flag("txt-quoting", "TXT records whose surrounding quotes are stripped when reading and writing them: 'all', or only external-dns' 'heritage' records to keep other TXT values verbatim").Default(string(netcup.TXTQuotingAll)).Envar("NETCUP_TXT_QUOTING").Enum(string(netcup.TXTQuotingAll), string(netcup.TXTQuotingHeritage))
+	defaultTTL               = kingpin.Flag("default-ttl", "TTL in seconds assumed for the records of zones whose TTL cannot be parsed").Default("300").Envar("NETCUP_DEFAULT_TTL").Uint32()
+	defaultRecordTTL         = kingpin.Flag("default-record-ttl", "TTL in seconds reported for all records instead of the TTL of their zone; 0 reports the zone TTL").Default("0").Envar("NETCUP_DEFAULT_RECORD_TTL").Uint32()
+	maxRecordsPerRequest     = kingpin.Flag("max-records-per-request", "Maximum number of records sent to the Netcup API in a single request; 0 sends all records of a zone at once").Default("0").Envar("NETCUP_MAX_RECORDS_PER_REQUEST").Int()
+	maxChangesPerHour        = kingpin.Flag("max-changes-per-hour", "Maximum number of record changes applied within an hour; further changes are refused until older ones leave the window. 0 disables the limit").Default("0").Envar("NETCUP_MAX_CHANGES_PER_HOUR").Int()
+	sessionKeepAlive         = kingpin.Flag("session-keepalive-interval", "Reuse one Netcup API session across requests and keep it alive by polling the API at this interval; 0 logs in and out for every request").Default("0s").Envar("NETCUP_SESSION_KEEPALIVE_INTERVAL").Duration()
+	rateLimitCooldown        = kingpin.Flag("rate-limit-cooldown", "Time to pause Netcup API calls after being rate limited; up to a fifth is added as jitter").Default("1m").Envar("NETCUP_RATE_LIMIT_COOLDOWN").Duration()
+	httpMaxIdleConns         = kingpin.Flag("http-max-idle-conns", "Maximum number of idle connections to the Netcup API; 0 means no limit").Default("100").Envar("NETCUP_HTTP_MAX_IDLE_CONNS").Int()
+	httpMaxIdleConnsPerHost  = kingpin.Flag("http-max-idle-conns-per-host", "Maximum number of idle connections per host to the Netcup API").Default("2").Envar("NETCUP_HTTP_MAX_IDLE_CONNS_PER_HOST").Int()
+	httpIdleConnTimeout      = kingpin.Flag("http-idle-conn-timeout", "Time after which idle connections to the Netcup API are closed; 0 means no limit").Default("90s").Envar("NETCUP_HTTP_IDLE_CONN_TIMEOUT").Duration()
+	httpTLSHandshakeTimeout  = kingpin.Flag("http-tls-handshake-timeout", "Timeout of TLS handshakes with the Netcup API; 0 means no timeout").Default("10s").Envar("NETCUP_HTTP_TLS_HANDSHAKE_TIMEOUT").Duration()
+	httpDisableKeepAlives    = kingpin.Flag("http-disable-keep-alives", "Open a new connection for every request to the Netcup API").Default("false").Envar("NETCUP_HTTP_DISABLE_KEEP_ALIVES").Bool()
+	userAgentSuffix          = kingpin.Flag("user-agent-suffix", "Text appended to the User-Agent sent to the Netcup API, e.g. to identify the cluster").Default("").Envar("NETCUP_USER_AGENT_SUFFIX").String()
+	maxConcurrentApplies     = kingpin.Flag("max-concurrent-applies", "Maximum number of change sets applied concurrently").Default("1").Envar("NETCUP_MAX_CONCURRENT_APPLIES").Int()
+	applyConcurrency         = kingpin.Flag("apply-concurrency", "Maximum number of zones of a change set applied concurrently; the canary zone is always applied first").Default("1").Envar("NETCUP_APPLY_CONCURRENCY").Int()
+	maxRequestsPerSecond     = kingpin.Flag("max-requests-per-second", "Maximum average rate of requests for records and of change sets each accepted from external-dns, e.g. to protect the Netcup account from a too short --interval; further requests are rejected with 503. 0 disables the limit").Default("0").Envar("NETCUP_MAX_REQUESTS_PER_SECOND").Float64()
+	requestBurst             = kingpin.Flag("request-burst", "Number of requests for records and of change sets each accepted at once above --max-requests-per-second").Default("5").Envar("NETCUP_REQUEST_BURST").Int()
+	applyQueueSize           = kingpin.Flag("apply-queue-size", "Number of change sets waiting for --max-concurrent-applies; further change sets are rejected with 503").Default("1").Envar("NETCUP_APPLY_QUEUE_SIZE").Int()
+	logAPIPayloads           = kingpin.Flag("log-api-payloads", "Log the payloads of Netcup API requests and responses, without credentials and session IDs, at trace level (DEBUG-4)").Default("false").Envar("NETCUP_LOG_API_PAYLOADS").Bool()
+	logBufferSize            = kingpin.Flag("log-buffer-size", "Number of recent log lines kept in memory and served at /debug/logs with --debug-token; 0 disables the buffer").Default("0").Envar("NETCUP_LOG_BUFFER_SIZE").Int()
+	zoneRefreshInterval      = kingpin.Flag("zone-refresh-interval", "Interval to check which zones of the domain filter exist in the Netcup account; 0 disables the refresh").Default("0s").Envar("NETCUP_ZONE_REFRESH_INTERVAL").Duration()
+)
+
+// logs holds the recent log lines if --log-buffer-size is set.
+var logs *logBuffer
+
+// configs knows where the values of the flags came from.
+var configs *flagSources
+
+var (
+	serveCmd         = kingpin.Command("serve", "Run the webhook server").Default()
+	selftestCmd      = kingpin.Command("selftest", "Create, verify and remove a probe TXT record to prove credentials, permissions and propagation work")
+	supportBundleCmd = kingpin.Command("support-bundle", "Collect sanitized configuration, version, logs, metrics and change history of a running webhook into a tarball")
+	benchCmd         = kingpin.Command("bench", "Measure the latency of the webhook API under load against a fake Netcup API with synthetic zones")
+	createZoneCmd    = kingpin.Command("create-zone", "Set up the zone of a domain newly registered at Netcup for external-dns")
+	orphansCmd       = kingpin.Command("orphans", "List records created by external-dns for Kubernetes resources that no longer exist, for review before deleting them")
+	adoptCmd         = kingpin.Command("adopt", "Create the TXT ownership records of external-dns for existing records of a zone, so external-dns adopts them instead of fighting them")
+	configCmd        = kingpin.Command("config", "Inspect the configuration")
+	configExplainCmd = configCmd.Command("explain", "Print every setting with its value and where it came from: default, env, flag or config-dir")
 )
 
 func main() {
@@ -39,36 +145,258 @@ func main() {
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.Version(version.Info())
-	kingpin.Parse()
+	kingpin.CommandLine.Terminate(func(code int) {
+		if code != 0 {
+			code = exitConfigError
+		}
+		os.Exit(code)
+	})
+	configs = newFlagSources(kingpin.CommandLine, os.Args[1:])
+	command := kingpin.MustParse(configs.parse())
 
 	var logger *slog.Logger = promslog.New(promslogConfig)
+	if *logAPIPayloads {
+		logger = slog.New(&traceHandler{logger.Handler()})
+	}
+	if *logBufferSize > 0 {
+		logs = newLogBuffer(*logBufferSize)
+		logger = slog.New(&teeHandler{
+			primary:   logger.Handler(),
+			secondary: slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		})
+	}
 	logger.Info("starting external-dns Netcup webhook plugin", "version", version.Version, "revision", version.Revision)
-	logger.Debug("configuration", "customer-id", strconv.Itoa(*customerID), "api-key", strings.Repeat("*", len(*apiKey)), "api-password", strings.Repeat("*", len(*apiPassword)))
+	config := effectiveConfig()
+	configAttrs := make([]any, 0, 2*len(config))
+	for _, name := range slices.Sorted(maps.Keys(config)) {
+		configAttrs = append(configAttrs, name, config[name])
+	}
+	logger.Info("configuration", configAttrs...)
+	configureTransport(logger)
+
+	switch command {
+	case selftestCmd.FullCommand():
+		runSelfTest(logger)
+	case supportBundleCmd.FullCommand():
+		runSupportBundle(logger)
+	case benchCmd.FullCommand():
+		runBench(logger)
+	case createZoneCmd.FullCommand():
+		runCreateZone(logger)
+	case adoptCmd.FullCommand():
+		runAdopt(logger)
+	case orphansCmd.FullCommand():
+		runOrphans(logger)
+	case configExplainCmd.FullCommand():
+		runConfigExplain(logger)
+	case serveCmd.FullCommand():
+		runServer(logger)
+	}
+}
 
-	prometheus.DefaultRegisterer.MustRegister(cversion.NewCollector("external_dns_netcup"))
+// runServer runs the webhook and metrics servers until one of them fails.
+func runServer(logger *slog.Logger) {
+	prometheus.DefaultRegisterer.MustRegister(webhookCollectors()...)
 
-	metricsMux := buildMetricsServer(prometheus.DefaultGatherer, logger)
-	metricsServer := http.Server{
-		Handler:           metricsMux,
-		ReadHeaderTimeout: 5 * time.Second}
+	metricsEnabled := !*disableMetrics && !slices.Contains(*metricsListenAddr, "none")
 
+	var routePrefix string
+	if *singleListener {
+		routePrefix = strings.TrimSuffix(*internalPrefix, "/")
+		if !strings.HasPrefix(routePrefix, "/") {
+			logger.Error("internal path prefix must be a non-root absolute path", "prefix", *internalPrefix)
+			os.Exit(exitConfigError)
+		}
+	}
 	metricsFlags := web.FlagConfig{
-		WebListenAddresses: &[]string{*metricsListenAddr},
+		WebListenAddresses: metricsListenAddr,
 		WebSystemdSocket:   new(bool),
 		WebConfigFile:      tlsConfig,
 	}
 
-	webhookMux, err := buildWebhookServer(logger)
+	// State kept across configuration reloads
+	var sharedOptions []netcup.Option
+	var changeHistory *netcup.ChangeHistory
+	if *changeHistorySize > 0 {
+		changeHistory = netcup.NewChangeHistory(*changeHistorySize)
+		sharedOptions = append(sharedOptions, netcup.WithChangeHistory(changeHistory))
+	}
+	var encrypter *netcup.Encrypter
+	if *encryptionKey != "" {
+		var err error
+		encrypter, err = netcup.LoadEncrypter(context.Background(), *encryptionKey)
+		if err != nil {
+			logger.Error("Failed to load encryption key", "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+	}
+	if *changeJournalFile != "" {
+		changeJournal, err := netcup.OpenChangeJournal(*changeJournalFile, *changeJournalRetention, encrypter)
+		if err != nil {
+			logger.Error("Failed to open change journal", "path", *changeJournalFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		// Seed the in-memory history so it survives restarts as well
+		entries, err := changeJournal.Entries()
+		if err != nil {
+			logger.Error("Failed to read change journal", "path", *changeJournalFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		for _, entry := range entries {
+			changeHistory.Add(entry)
+		}
+		sharedOptions = append(sharedOptions, netcup.WithChangeJournal(changeJournal))
+	}
+
+	var shadowComparer *netcup.ShadowComparer
+	if *shadowSource != "" {
+		shadowComparer = netcup.NewShadowComparer(*shadowSource)
+		sharedOptions = append(sharedOptions, netcup.WithShadowComparer(shadowComparer))
+	}
+	if *detectDrift || *driftNotifyURL != "" {
+		sharedOptions = append(sharedOptions, netcup.WithDriftDetector(netcup.NewDriftDetector(*driftNotifyURL)))
+	}
+
+	if *maxChangesPerHour > 0 {
+		sharedOptions = append(sharedOptions, netcup.WithChangeRateLimiter(netcup.NewChangeRateLimiter(*maxChangesPerHour)))
+	}
+
+	if *zoneLock != "none" {
+		locker, err := newZoneLocker(logger)
+		if err != nil {
+			logger.Error("Failed to create zone locker", "lock", *zoneLock, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		sharedOptions = append(sharedOptions, netcup.WithZoneLocker(locker, *zoneLockTimeout))
+	}
+
+	switch *recordCache {
+	case "memory":
+		sharedOptions = append(sharedOptions, netcup.WithRecordCache(netcup.NewMemoryRecordCache(), *recordCacheTTL))
+	case "file":
+		if *recordCacheDir == "" {
+			logger.Error("--record-cache=file needs a --record-cache-dir")
+			os.Exit(exitConfigError)
+		}
+		cache, err := netcup.NewFileRecordCache(*recordCacheDir, encrypter, logger)
+		if err != nil {
+			logger.Error("Failed to create record cache", "path", *recordCacheDir, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		sharedOptions = append(sharedOptions, netcup.WithRecordCache(cache, *recordCacheTTL))
+	}
+
+	var approvalQueue *netcup.ApprovalQueue
+	if *requireApproval {
+		if *approvalToken == "" {
+			logger.Error("--require-approval needs an --approval-token")
+			os.Exit(exitConfigError)
+		}
+		approvalQueue = netcup.NewApprovalQueue(*approvalTTL)
+		sharedOptions = append(sharedOptions, netcup.WithApprovalQueue(approvalQueue))
+	}
+
+	// Stale records are only served by the provider built at startup, not after reloads
+	staleStart := *recordCacheStaleStart && *recordCache != "none"
+	providers, err := newReloadableProvider(func() (*netcup.NetcupProvider, error) {
+		options := sharedOptions
+		if staleStart {
+			options = append(slices.Clip(options), netcup.WithStaleStart())
+			staleStart = false
+		}
+		return newProvider(logger, options)
+	}, logger)
 	if err != nil {
 		logger.Error("Failed to create provider", "error", err.Error())
-		os.Exit(1)
+		os.Exit(exitConfigError)
+	}
+	if *validateCredentials && !*dryRun {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := providers.Load().ValidateCredentials(ctx)
+		cancel()
+		if err != nil {
+			logger.Error("Failed to validate credentials", "error", err.Error())
+			os.Exit(exitCodeForError(err))
+		}
+	}
+	if *consistencyEndpoint && *debugToken == "" {
+		logger.Error("--consistency-endpoint needs a --debug-token")
+		os.Exit(exitConfigError)
+	}
+	// Checks the provider current at the time of the check, so it survives reloads
+	consistencyChecker := netcup.NewConsistencyChecker(providers, *consistencyNameserver, *consistencySampleSize, logger)
+	if *maxConcurrentApplies < 1 || *applyQueueSize < 0 {
+		logger.Error("--max-concurrent-applies must be at least 1 and --apply-queue-size must not be negative")
+		os.Exit(exitConfigError)
+	}
+	proxies, err := server.ParseTrustedProxies(*trustedProxyFlag)
+	if err != nil {
+		logger.Error("Failed to parse trusted proxies", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	middlewares := []server.Middleware{server.AccessLog(proxies, logger), server.InstrumentRoute}
+	if *maxRequestsPerSecond > 0 {
+		if *requestBurst < 1 {
+			logger.Error("--request-burst must be at least 1")
+			os.Exit(exitConfigError)
+		}
+		middlewares = append(middlewares, server.NewRequestRateLimiter(*maxRequestsPerSecond, *requestBurst, proxies, logger).Limit)
+	}
+	metricsMux := server.NewMetricsMux(prometheus.DefaultGatherer, server.CapabilitiesHandler(providers, logger), server.WithLogger(logger), server.WithRoutePrefix(routePrefix))
+	metricsServer := http.Server{
+		Handler:           server.RecoverHandler(metricsMux, proxies, logger),
+		ReadHeaderTimeout: 5 * time.Second}
+	webhookMux := server.NewWebhookMux(providers,
+		server.WithLogger(logger),
+		server.WithMaxBodySize(int64(*maxRequestBodySize)),
+		server.WithApplyLimit(*maxConcurrentApplies, *applyQueueSize),
+		server.WithMetricsEnabled(metricsEnabled),
+		server.WithRecordsETag(*recordsETag),
+		server.WithTrustedProxies(proxies),
+		server.WithMiddlewares(middlewares...))
+	if *debugToken != "" {
+		if changeHistory != nil {
+			webhookMux.Handle("/debug/changes", requireToken(changeHistory, *debugToken, proxies, logger))
+		}
+		webhookMux.Handle("/debug/plan", planHandler(providers, *debugToken, int64(*maxRequestBodySize), proxies, logger))
+		if logs != nil {
+			webhookMux.Handle("/debug/logs", requireToken(logs, *debugToken, proxies, logger))
+		}
+		if shadowComparer != nil {
+			webhookMux.Handle("/debug/shadow", requireToken(shadowComparer, *debugToken, proxies, logger))
+		}
+		if *consistencyEndpoint {
+			webhookMux.Handle("/debug/consistency", requireToken(consistencyChecker, *debugToken, proxies, logger))
+		}
+	}
+	if approvalQueue != nil {
+		approvalsHandler := approvalQueue.Handler("/admin/approvals", *approvalToken)
+		webhookMux.Handle("/admin/approvals", approvalsHandler)
+		webhookMux.Handle("/admin/approvals/", approvalsHandler)
+	}
+	if *singleListener && metricsEnabled {
+		// Nest metrics and landing page below the prefix to avoid clashing with the negotiate root
+		webhookMux.Handle(routePrefix+"/", http.StripPrefix(routePrefix, metricsMux))
+	}
+	var webhookHandler http.Handler = webhookMux
+	if *compressResponses {
+		webhookHandler = server.CompressHandler(webhookHandler)
 	}
 	webhookServer := http.Server{
-		Handler:           webhookMux,
+		Handler:           server.RecoverHandler(webhookHandler, proxies, logger),
 		ReadHeaderTimeout: 5 * time.Second}
+	if *enableH2C {
+		// Registering the HTTP/2 server lets Shutdown close h2c connections as well
+		h2s := &http2.Server{}
+		if err := http2.ConfigureServer(&webhookServer, h2s); err != nil {
+			logger.Error("Failed to configure HTTP/2", "error", err.Error())
+			os.Exit(exitFailure)
+		}
+		webhookServer.Handler = h2c.NewHandler(webhookServer.Handler, h2s)
+	}
 
 	webhookFlags := web.FlagConfig{
-		WebListenAddresses: &[]string{*listenAddr},
+		WebListenAddresses: listenAddr,
 		WebSystemdSocket:   new(bool),
 		WebConfigFile:      tlsConfig,
 	}
@@ -76,9 +404,11 @@ func main() {
 	var g run.Group
 
 	// Run Metrics server
-	{
+	if !metricsEnabled {
+		logger.Info("metrics server disabled")
+	} else if !*singleListener {
 		g.Add(func() error {
-			logger.Info("Started external-dns-netcup-webhook metrics server", "address", metricsListenAddr)
+			logger.Info("Started external-dns-netcup-webhook metrics server", "addresses", strings.Join(*metricsListenAddr, ","))
 			return web.ListenAndServe(&metricsServer, &metricsFlags, logger)
 		}, func(error) {
 			ctxShutDown, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -89,7 +419,7 @@ func main() {
 	// Run webhook API server
 	{
 		g.Add(func() error {
-			logger.Info("Started external-dns-netcup-webhook webhook server", "address", listenAddr)
+			logger.Info("Started external-dns-netcup-webhook webhook server", "addresses", strings.Join(*listenAddr, ","))
 			return web.ListenAndServe(&webhookServer, &webhookFlags, logger)
 		}, func(error) {
 			ctxShutDown, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -98,76 +428,244 @@ func main() {
 		})
 	}
 
-	if err := g.Run(); err != nil {
-		logger.Error("run server group error", "error", err.Error())
-		os.Exit(1)
+	// Run background loops of the provider, restarted for every provider reloaded on SIGHUP
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			providers.Run(ctx, func(ctx context.Context, p *netcup.NetcupProvider) {
+				runProviderLoops(ctx, p, consistencyChecker, logger)
+			})
+			return nil
+		}, func(error) {
+			cancel()
+		})
 	}
 
-}
+	// Stop on SIGINT and SIGTERM
+	g.Add(run.SignalHandler(context.Background(), os.Interrupt, syscall.SIGTERM))
+	// Log out of the sessions still open once everything else has been interrupted
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			<-ctx.Done()
+			return nil
+		}, func(error) {
+			cancel()
+			providers.Load().Close()
+		})
+	}
 
-func buildMetricsServer(registry prometheus.Gatherer, logger *slog.Logger) *http.ServeMux {
-	mux := http.NewServeMux()
-
-	var metricsPath = "/metrics"
-	var rootPath = "/"
-
-	// Add metricsPath
-	mux.Handle(metricsPath, promhttp.HandlerFor(
-		registry,
-		promhttp.HandlerOpts{
-			EnableOpenMetrics: true,
-		}))
-
-	// Add index
-	landingConfig := web.LandingConfig{
-		Name:        "external-dns-netcup-webhook",
-		Description: "external-dns webhook provider for Netcup",
-		Version:     version.Info(),
-		Links: []web.LandingLinks{
-			{
-				Address: metricsPath,
-				Text:    "Metrics",
-			},
-		},
-	}
-	landingPage, err := web.NewLandingPage(landingConfig)
-	if err != nil {
-		logger.Error("failed to create landing page", "error", err.Error())
+	if err := g.Run(); err != nil {
+		var signalErr run.SignalError
+		if errors.As(err, &signalErr) {
+			logger.Info("shutting down", "signal", signalErr.Signal.String())
+			return
+		}
+		logger.Error("run server group error", "error", err.Error())
+		os.Exit(exitFailure)
 	}
-	mux.Handle(rootPath, landingPage)
 
-	return mux
 }
 
-func buildWebhookServer(logger *slog.Logger) (*http.ServeMux, error) {
-	mux := http.NewServeMux()
+// newProvider creates the provider from the flags and the files they point to. It is called again
+// for every configuration reload; options carry the state that survives reloads.
+func newProvider(logger *slog.Logger, options []netcup.Option) (*netcup.NetcupProvider, error) {
+	domains := append([]string{}, *domainFilter...)
+	if *domainFilterFile != "" {
+		fileDomains, err := netcup.ReadDomainFilterFile(*domainFilterFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read domain filter file: %w", err)
+		}
+		domains = append(domains, fileDomains...)
+	}
 
-	var rootPath = "/"
-	var healthzPath = "/healthz"
-	var recordsPath = "/records"
-	var adjustEndpointsPath = "/adjustendpoints"
+	providerOptions := append([]netcup.Option{netcup.WithSharding(*shardIndex, *shardCount), netcup.WithRateLimitCooldown(*rateLimitCooldown), netcup.WithDefaultTTL(endpoint.TTL(*defaultTTL)), netcup.WithTXTQuoting(netcup.TXTQuoting(*txtQuoting))}, options...)
+	if *zoneCredentialsFile != "" {
+		zoneCredentials, err := netcup.ReadZoneCredentialsFile(*zoneCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read zone credentials file: %w", err)
+		}
+		providerOptions = append(providerOptions, netcup.WithZoneCredentials(zoneCredentials))
+	}
+	if *canaryZone != "" {
+		providerOptions = append(providerOptions, netcup.WithCanaryZone(*canaryZone))
+	}
+	if *policyFile != "" {
+		policy, err := netcup.ReadPolicyFile(*policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read policy file: %w", err)
+		}
+		providerOptions = append(providerOptions, netcup.WithPolicy(policy))
+	}
+	if *companionRecordsFile != "" {
+		rules, err := netcup.ReadCompanionRulesFile(*companionRecordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read companion records file: %w", err)
+		}
+		providerOptions = append(providerOptions, netcup.WithCompanionRules(rules))
+	}
+	if *protectDeletes {
+		providerOptions = append(providerOptions, netcup.WithProtectDeletes())
+	}
+	if *targetHealthPort > 0 {
+		providerOptions = append(providerOptions, netcup.WithTargetHealthCheck(int(*targetHealthPort), *targetHealthTimeout))
+	}
+	providerOptions = append(providerOptions, netcup.WithMissingZoneBackoff(*missingZoneBackoff))
+	if *minZoneRefreshInterval > 0 {
+		providerOptions = append(providerOptions, netcup.WithMinZoneRefreshInterval(*minZoneRefreshInterval))
+	}
+	if *defaultRecordTTL > 0 {
+		providerOptions = append(providerOptions, netcup.WithRecordTTL(endpoint.TTL(*defaultRecordTTL)))
+	}
+	if *maxRecordsPerRequest > 0 {
+		providerOptions = append(providerOptions, netcup.WithMaxRecordsPerRequest(*maxRecordsPerRequest))
+	}
+	if *applyConcurrency > 1 {
+		providerOptions = append(providerOptions, netcup.WithApplyConcurrency(*applyConcurrency))
+	}
+	if *sessionKeepAlive > 0 {
+		providerOptions = append(providerOptions, netcup.WithSessionKeepAlive(*sessionKeepAlive))
+	}
+	if *ownerID != "" {
+		providerOptions = append(providerOptions, netcup.WithOwnerID(*ownerID))
+	}
+	if len(*nameRewrites) > 0 {
+		rewrites := make([]netcup.NameRewrite, 0, len(*nameRewrites))
+		for _, value := range *nameRewrites {
+			rewrite, err := netcup.ParseNameRewrite(value)
+			if err != nil {
+				return nil, err
+			}
+			rewrites = append(rewrites, rewrite)
+		}
+		providerOptions = append(providerOptions, netcup.WithNameRewrites(rewrites))
+	}
+	if len(*includeOwners) > 0 || len(*excludeOwners) > 0 {
+		providerOptions = append(providerOptions, netcup.WithOwnerFilter(*includeOwners, *excludeOwners))
+	}
+	if len(*extraRecordTypes) > 0 {
+		providerOptions = append(providerOptions, netcup.WithExtraRecordTypes(*extraRecordTypes))
+	}
+	if len(*managedRecordTypes) > 0 {
+		providerOptions = append(providerOptions, netcup.WithManagedRecordTypes(*managedRecordTypes))
+	}
 
-	ncProvider, err := netcup.NewNetcupProvider(domainFilter, *customerID, *apiKey, *apiPassword, *dryRun, logger)
+	credentials, err := defaultCredentials(context.Background())
 	if err != nil {
 		return nil, err
 	}
+	return netcup.NewNetcupProvider(&domains, credentials.CustomerID, credentials.APIKey, credentials.APIPassword, *dryRun, logger, providerOptions...)
+}
 
-	p := webhook.WebhookServer{
-		Provider: ncProvider,
+// defaultCredentials returns the credentials set by flags, with the unset ones read from
+// --credentials-source.
+func defaultCredentials(ctx context.Context) (netcup.Credentials, error) {
+	credentials := netcup.Credentials{CustomerID: *customerID, APIKey: *apiKey, APIPassword: *apiPassword}
+	if *credentialsSource == "" {
+		return credentials, nil
+	}
+	source, err := netcup.NewCredentialsSource(*credentialsSource)
+	if err != nil {
+		return netcup.Credentials{}, err
+	}
+	stored, err := source.Credentials(ctx)
+	if err != nil {
+		return netcup.Credentials{}, fmt.Errorf("unable to read credentials source: %w", err)
+	}
+	registerSecrets(stored.APIKey, stored.APIPassword)
+	if credentials.CustomerID == 0 {
+		credentials.CustomerID = stored.CustomerID
 	}
+	if credentials.APIKey == "" {
+		credentials.APIKey = stored.APIKey
+	}
+	if credentials.APIPassword == "" {
+		credentials.APIPassword = stored.APIPassword
+	}
+	return credentials, nil
+}
 
-	// Add healthzPath
-	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
-	})
+// runProviderLoops runs the background loops of p and the periodic consistency check until ctx is
+// cancelled.
+func runProviderLoops(ctx context.Context, p *netcup.NetcupProvider, consistencyChecker *netcup.ConsistencyChecker, logger *slog.Logger) {
+	var wg sync.WaitGroup
 
-	// Add negotiatePath
-	mux.HandleFunc(rootPath, p.NegotiateHandler)
-	// Add adjustEndpointsPath
-	mux.HandleFunc(adjustEndpointsPath, p.AdjustEndpointsHandler)
-	// Add recordsPath
-	mux.HandleFunc(recordsPath, p.RecordsHandler)
+	if *checkDelegation {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			p.CheckDelegation(ctx, *delegationNameserver)
+		}()
+	}
+	// Run domain filter file watcher
+	if *domainFilterFile != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("Started domain filter file watcher", "path", *domainFilterFile)
+			p.WatchDomainFilterFile(ctx, *domainFilterFile, *domainFilter, *domainFilterFileInterval)
+		}()
+	}
+	// Run zone refresher
+	if *zoneRefreshInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("Started zone refresher", "interval", zoneRefreshInterval.String())
+			p.RunZoneRefresher(ctx, *zoneRefreshInterval)
+		}()
+	}
+	// Run session keep-alive
+	if *sessionKeepAlive > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.RunSessionKeepAlive(ctx)
+		}()
+	}
+	// Run consistency checker
+	if *consistencyInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("Started consistency checker", "interval", consistencyInterval.String(), "nameserver", *consistencyNameserver)
+			consistencyChecker.Run(ctx, *consistencyInterval)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// newZoneLocker creates the zone locker selected by --zone-lock.
+func newZoneLocker(logger *slog.Logger) (netcup.ZoneLocker, error) {
+	if *zoneLockTTL < 3*time.Second {
+		return nil, fmt.Errorf("--zone-lock-ttl must be at least 3s")
+	}
+	holder := *zoneLockHolder
+	if holder == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		holder = hostname
+	}
+	if *zoneLock == "lease" {
+		return netcup.NewInClusterLeaseZoneLocker(*zoneLockNamespace, holder, *zoneLockTTL, logger)
+	}
+	if *zoneLockDir == "" {
+		return nil, fmt.Errorf("--zone-lock=file needs a --zone-lock-dir")
+	}
+	return netcup.NewFileZoneLocker(*zoneLockDir, holder, *zoneLockTTL, logger)
+}
 
-	return mux, nil
+// webhookCollectors returns the metrics of the webhook itself; the provider registers its own.
+func webhookCollectors() []prometheus.Collector {
+	return append([]prometheus.Collector{
+		cversion.NewCollector(metrics.Namespace),
+		configReloadsTotal,
+		configLastReloadSuccessful,
+		apiRequestsTotal,
+	}, server.Collectors()...)
 }
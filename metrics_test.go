@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/mrueg/external-dns-netcup-webhook/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMetadata(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(webhookCollectors()...)
+	// the provider registers its metrics with the default registry
+	gatherer := prometheus.Gatherers{registry, prometheus.DefaultGatherer}
+
+	// metrics of the Go runtime and of the external-dns packages are not ours to name
+	foreign := []string{"go_", "process_", "promhttp_", "external_dns_webhook_"}
+	var names []string
+	families, err := gatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		name := family.GetName()
+		if slices.ContainsFunc(foreign, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(name, metrics.Namespace+"_"), name)
+		assert.NotEmpty(t, family.GetHelp(), name)
+		names = append(names, name)
+	}
+	assert.Contains(t, names, metrics.Namespace+"_build_info")
+	problems, err := testutil.GatherAndLint(gatherer, names...)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	// counters expose their creation time to OpenMetrics scrapers
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("test") })
+	server.RecoverHandler(panicking, nil, logger).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	mux := server.NewMetricsMux(gatherer, http.NotFoundHandler(), server.WithLogger(logger))
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeOpenMetrics)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.Bytes()
+	assert.True(t, bytes.Contains(body, []byte("# TYPE external_dns_netcup_http_panics counter")), string(body))
+	assert.True(t, bytes.Contains(body, []byte("external_dns_netcup_http_panics_created ")))
+	assert.True(t, bytes.HasSuffix(body, []byte("# EOF\n")))
+}
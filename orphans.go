@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+)
+
+var (
+	orphansInventoryFile = orphansCmd.Flag("inventory-file", "File listing the existing resources as kind/namespace/name, one per line; the Kubernetes API of the cluster is asked if unset").Default("").String()
+	orphansRecordTypes   = orphansCmd.Flag("record-type", "List orphaned records of this type; specify multiple times for multiple types").Default("A", "AAAA").Strings()
+)
+
+// runOrphans writes the orphaned records of the zones of the domain filter as JSON to stdout and
+// exits non-zero on failure, with the exit code telling rejected credentials and an unavailable
+// Netcup API apart. Only the records of --owner-id are listed if it is set.
+func runOrphans(logger *slog.Logger) {
+	var inventory netcup.ResourceInventory
+	if *orphansInventoryFile != "" {
+		fileInventory, err := netcup.ReadInventoryFile(*orphansInventoryFile)
+		if err != nil {
+			logger.Error("Failed to read inventory file", "path", *orphansInventoryFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		inventory = fileInventory
+	} else {
+		kubernetesInventory, err := netcup.NewInClusterInventory()
+		if err != nil {
+			logger.Error("Failed to connect to the Kubernetes API, pass --inventory-file outside of a cluster", "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		inventory = kubernetesInventory
+	}
+
+	providerOptions := []netcup.Option{netcup.WithTXTQuoting(netcup.TXTQuoting(*txtQuoting))}
+	if *zoneCredentialsFile != "" {
+		zoneCredentials, err := netcup.ReadZoneCredentialsFile(*zoneCredentialsFile)
+		if err != nil {
+			logger.Error("Failed to read zone credentials file", "path", *zoneCredentialsFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		providerOptions = append(providerOptions, netcup.WithZoneCredentials(zoneCredentials))
+	}
+
+	credentials, err := defaultCredentials(context.Background())
+	if err != nil {
+		logger.Error("Failed to read credentials", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	domains := append([]string{}, *domainFilter...)
+	ncProvider, err := netcup.NewNetcupProvider(&domains, credentials.CustomerID, credentials.APIKey, credentials.APIPassword, *dryRun, logger, providerOptions...)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+
+	orphans, err := ncProvider.FindOrphans(context.Background(), inventory, *orphansRecordTypes, *ownerID)
+	if err != nil {
+		logger.Error("Failed to find orphaned records", "error", err.Error())
+		os.Exit(exitCodeForError(err))
+	}
+	if orphans == nil {
+		orphans = []netcup.Orphan{}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(orphans); err != nil {
+		logger.Error("Failed to write orphaned records", "error", err.Error())
+		os.Exit(exitFailure)
+	}
+	logger.Info("listed orphaned records", "records", len(orphans))
+}
@@ -0,0 +1,149 @@
+package netcup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// txtRecordTypeTemplate is replaced by the lowercase record type in the TXT prefix and suffix of
+// external-dns.
+const txtRecordTypeTemplate = "%{record_type}"
+
+// TXTRegistry mirrors the TXT registry flags of the external-dns instance adopting records.
+type TXTRegistry struct {
+	// OwnerID is the --txt-owner-id.
+	OwnerID string
+	// Prefix is the --txt-prefix.
+	Prefix string
+	// Suffix is the --txt-suffix.
+	Suffix string
+	// WildcardReplacement is the --txt-wildcard-replacement.
+	WildcardReplacement string
+}
+
+// AdoptSelector selects the records Adopt creates ownership records for. Empty fields match all.
+type AdoptSelector struct {
+	RecordTypes []string
+	// Hostnames are globs matched against the fully qualified names of the records.
+	Hostnames []string
+}
+
+func (s AdoptSelector) matches(ep *endpoint.Endpoint) bool {
+	if len(s.RecordTypes) > 0 && !slices.ContainsFunc(s.RecordTypes, func(t string) bool { return strings.EqualFold(t, ep.RecordType) }) {
+		return false
+	}
+	if len(s.Hostnames) > 0 && !slices.ContainsFunc(s.Hostnames, func(glob string) bool {
+		ok, _ := path.Match(strings.ToLower(glob), strings.ToLower(ep.DNSName))
+		return ok
+	}) {
+		return false
+	}
+	return true
+}
+
+// Adopt creates the heritage TXT records external-dns would have created along with the selected
+// existing records of zone, so external-dns treats them as its own instead of failing to create
+// them again. Records that already have a heritage record are skipped. It returns the ownership
+// records to create; in dry run mode they are logged but not created.
+func (p *NetcupProvider) Adopt(ctx context.Context, zone string, selector AdoptSelector, registry TXTRegistry) ([]*endpoint.Endpoint, error) {
+	if registry.OwnerID == "" {
+		return nil, fmt.Errorf("adopting records requires the TXT owner ID of external-dns")
+	}
+	for _, glob := range selector.Hostnames {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid hostname glob '%s': %v", glob, err)
+		}
+	}
+
+	sessions := p.newSessionSet()
+	defer sessions.close()
+	cached, err := p.readZone(sessions, zone)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := recordsToEndpoints(zone, p.parseZoneTTL(zone, cached.TTL), cached.Records, p.txtQuoting)
+
+	// owners of the existing heritage records by name
+	owners := map[string]string{}
+	for _, ep := range endpoints {
+		if owner := recordOwner(ep); ep.RecordType == endpoint.RecordTypeTXT && owner != "" {
+			owners[ep.DNSName] = owner
+		}
+	}
+
+	var created []*endpoint.Endpoint
+	for _, ep := range endpoints {
+		if ep.RecordType == endpoint.RecordTypeTXT || !selector.matches(ep) {
+			continue
+		}
+		records := registry.ownershipRecords(ep)
+		if i := slices.IndexFunc(records, func(txt *endpoint.Endpoint) bool { return owners[txt.DNSName] != "" }); i >= 0 {
+			owner := owners[records[i].DNSName]
+			if owner == registry.OwnerID {
+				p.logger.Debug("record already owned", "record", ep.DNSName, "type", ep.RecordType)
+			} else {
+				p.logger.Warn("record owned by another external-dns instance, not adopting it", "record", ep.DNSName, "type", ep.RecordType, "owner", owner)
+			}
+			continue
+		}
+		for _, txt := range records {
+			p.logger.Info("adopting record", "record", ep.DNSName, "type", ep.RecordType, "ownership_record", txt.DNSName)
+		}
+		created = append(created, records...)
+	}
+
+	if len(created) == 0 {
+		p.logger.Info("no records to adopt", "zone", zone)
+		return nil, nil
+	}
+	if p.dryRun {
+		p.logger.Info("dry run - not creating ownership records", "zone", zone, "records", len(created))
+		return created, nil
+	}
+	if err := p.ApplyChanges(ctx, &plan.Changes{Create: created}); err != nil {
+		return nil, fmt.Errorf("unable to create ownership records: %w", err)
+	}
+	p.logger.Info("created ownership records", "zone", zone, "records", len(created))
+	return created, nil
+}
+
+// ownershipRecords returns the heritage TXT records the TXT registry of external-dns creates along
+// with ep: one in the old format named like the record, except for AAAA records and if the record
+// type is part of the affix, and one in the new format with the record type in its name.
+func (r TXTRegistry) ownershipRecords(ep *endpoint.Endpoint) []*endpoint.Endpoint {
+	heritage := endpoint.Labels{endpoint.OwnerLabelKey: r.OwnerID}.SerializePlain(true)
+	prefix, suffix := strings.ToLower(r.Prefix), strings.ToLower(r.Suffix)
+	typeInAffix := strings.Contains(prefix, txtRecordTypeTemplate) || strings.Contains(suffix, txtRecordTypeTemplate)
+
+	var records []*endpoint.Endpoint
+	if !typeInAffix && ep.RecordType != endpoint.RecordTypeAAAA {
+		name := r.txtName(ep.DNSName, strings.ReplaceAll(prefix, txtRecordTypeTemplate, ""), strings.ReplaceAll(suffix, txtRecordTypeTemplate, ""), "")
+		records = append(records, endpoint.NewEndpoint(name, endpoint.RecordTypeTXT, heritage))
+	}
+	recordType := strings.ToLower(ep.RecordType)
+	typePrefix := recordType + "-"
+	if typeInAffix {
+		typePrefix = ""
+	}
+	name := r.txtName(ep.DNSName, strings.ReplaceAll(prefix, txtRecordTypeTemplate, recordType), strings.ReplaceAll(suffix, txtRecordTypeTemplate, recordType), typePrefix)
+	return append(records, endpoint.NewEndpoint(name, endpoint.RecordTypeTXT, heritage))
+}
+
+// txtName puts the affixes around the first label of dnsName, after replacing a wildcard.
+func (r TXTRegistry) txtName(dnsName, prefix, suffix, typePrefix string) string {
+	first, rest, found := strings.Cut(dnsName, ".")
+	if first == "*" && r.WildcardReplacement != "" {
+		first = strings.ToLower(r.WildcardReplacement)
+	}
+	name := prefix + typePrefix + first + suffix
+	if found {
+		name += "." + rest
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+package netcup
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// endpointNames returns the names of endpoints.
+func endpointNames(endpoints []*endpoint.Endpoint) []string {
+	names := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		names = append(names, ep.DNSName)
+	}
+	return names
+}
+
+func TestTXTRegistryOwnershipRecords(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		registry TXTRegistry
+		ep       *endpoint.Endpoint
+		expected []string
+	}{
+		{"default", TXTRegistry{}, endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"), []string{"www.example.com", "a-www.example.com"}},
+		{"AAAA has no old format", TXTRegistry{}, endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeAAAA, "::1"), []string{"aaaa-www.example.com"}},
+		{"prefix", TXTRegistry{Prefix: "Own."}, endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "app.example.com"), []string{"own.www.example.com", "own.cname-www.example.com"}},
+		{"record type in prefix", TXTRegistry{Prefix: "%{record_type}-own."}, endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"), []string{"a-own.www.example.com"}},
+		{"suffix", TXTRegistry{Suffix: "-own"}, endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"), []string{"www-own.example.com", "a-www-own.example.com"}},
+		{"wildcard", TXTRegistry{WildcardReplacement: "any"}, endpoint.NewEndpoint("*.example.com", endpoint.RecordTypeA, "1.1.1.1"), []string{"any.example.com", "a-any.example.com"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.registry.OwnerID = "cluster"
+			records := tc.registry.ownershipRecords(tc.ep)
+			assert.Equal(t, tc.expected, endpointNames(records))
+			for _, txt := range records {
+				assert.Equal(t, endpoint.Targets{`"heritage=external-dns,external-dns/owner=cluster"`}, txt.Targets)
+			}
+		})
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "v6", Type: "AAAA", Destination: "::1"},
+		nc.DnsRecord{Hostname: "@", Type: "MX", Destination: "mail.example.com", Priority: "10"},
+		nc.DnsRecord{Hostname: "api", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "a-api", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=other"`},
+	)
+	registry := TXTRegistry{OwnerID: "cluster"}
+	selector := AdoptSelector{RecordTypes: []string{"A", "AAAA", "CNAME"}}
+
+	// dry run only reports the ownership records
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	planned, err := p.Adopt(context.TODO(), "example.com", selector, registry)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"www.example.com", "a-www.example.com", "aaaa-v6.example.com"}, endpointNames(planned))
+	assert.Len(t, srv.Records("example.com"), 5)
+
+	// records of other owners are left alone
+	p, err = NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	created, err := p.Adopt(context.TODO(), "example.com", AdoptSelector{RecordTypes: []string{"A"}, Hostnames: []string{"www.*"}}, registry)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"www.example.com", "a-www.example.com"}, endpointNames(created))
+	records := srv.Records("example.com")
+	assert.Len(t, records, 7)
+	assert.True(t, slices.ContainsFunc(records, func(r nc.DnsRecord) bool {
+		return r.Hostname == "www" && r.Type == "TXT" && r.Destination == "heritage=external-dns,external-dns/owner=cluster"
+	}))
+
+	// adopted records are skipped
+	created, err = p.Adopt(context.TODO(), "example.com", selector, registry)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aaaa-v6.example.com"}, endpointNames(created))
+	created, err = p.Adopt(context.TODO(), "example.com", selector, registry)
+	assert.NoError(t, err)
+	assert.Empty(t, created)
+
+	_, err = p.Adopt(context.TODO(), "example.com", selector, TXTRegistry{})
+	assert.Error(t, err)
+	_, err = p.Adopt(context.TODO(), "example.com", AdoptSelector{Hostnames: []string{"["}}, registry)
+	assert.Error(t, err)
+}
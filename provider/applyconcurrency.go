@@ -0,0 +1,36 @@
+package netcup
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// WithApplyConcurrency applies the changes of up to n zones of a change set concurrently instead of
+// one zone after another. The canary zone is still applied first and on its own.
+func WithApplyConcurrency(n int) Option {
+	return func(p *NetcupProvider) {
+		p.applyConcurrency = n
+	}
+}
+
+// applyZones applies the changes of zones with up to applyConcurrency zones at a time. After the
+// first failure no further zones are started; zones already in progress are finished. Zones are not
+// started while the API rate limited the provider.
+func (p *NetcupProvider) applyZones(ctx context.Context, sessions *sessionSet, zones []string, changes map[string]*plan.Changes) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(p.applyConcurrency, 1))
+	for _, zone := range zones {
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			if err := p.rateLimited(); err != nil {
+				return err
+			}
+			return p.applyZone(ctx, sessions, zone, changes[zone])
+		})
+	}
+	return g.Wait()
+}
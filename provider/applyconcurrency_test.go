@@ -0,0 +1,43 @@
+package netcup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestApplyConcurrency(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+
+	var domainFilter []string
+	changes := &plan.Changes{}
+	for i := range 8 {
+		zone := fmt.Sprintf("zone%d.com", i)
+		srv.AddZone(zone, "300")
+		domainFilter = append(domainFilter, zone)
+		changes.Create = append(changes.Create, endpoint.NewEndpoint("www."+zone, endpoint.RecordTypeA, "1.1.1.1"))
+	}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithApplyConcurrency(3), WithCanaryZone("zone5.com"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	for _, zone := range domainFilter {
+		assert.Len(t, srv.Records(zone), 1, zone)
+	}
+	assert.Equal(t, 1, srv.Calls("login"), "zones share the session")
+
+	// a failing canary zone still stops all other zones
+	for _, ep := range changes.Create {
+		ep.Targets = endpoint.Targets{"2.2.2.2"}
+	}
+	srv.FailAction("updateDnsRecords", 4000)
+	assert.Error(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, 1, srv.Calls("updateDnsRecords")-len(domainFilter))
+}
@@ -0,0 +1,169 @@
+package netcup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// PendingChange is a change set awaiting approval by an operator.
+type PendingChange struct {
+	ID      string        `json:"id"`
+	Created time.Time     `json:"created"`
+	Expires time.Time     `json:"expires"`
+	Changes *plan.Changes `json:"changes"`
+
+	fingerprint string
+}
+
+// ApprovalQueue parks change sets until an operator approves them.
+type ApprovalQueue struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pending map[string]*PendingChange
+	apply   func(ctx context.Context, changes *plan.Changes) error
+}
+
+// NewApprovalQueue creates a queue whose pending changes expire after ttl.
+func NewApprovalQueue(ttl time.Duration) *ApprovalQueue {
+	return &ApprovalQueue{
+		ttl:     ttl,
+		pending: map[string]*PendingChange{},
+	}
+}
+
+// WithApprovalQueue only applies changes after they have been approved in queue.
+func WithApprovalQueue(queue *ApprovalQueue) Option {
+	return func(p *NetcupProvider) {
+		p.approvals = queue
+	}
+}
+
+// park adds changes to the queue unless an identical change set is already pending.
+func (q *ApprovalQueue) park(changes *plan.Changes) (*PendingChange, error) {
+	content, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireLocked()
+	for _, pc := range q.pending {
+		if pc.fingerprint == fingerprint {
+			return pc, nil
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	pc := &PendingChange{
+		ID:          hex.EncodeToString(id),
+		Created:     now,
+		Expires:     now.Add(q.ttl),
+		Changes:     changes,
+		fingerprint: fingerprint,
+	}
+	q.pending[pc.ID] = pc
+	pendingApprovals.Set(float64(len(q.pending)))
+	return pc, nil
+}
+
+// take removes the pending change with id from the queue.
+func (q *ApprovalQueue) take(id string) (*PendingChange, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireLocked()
+	pc, ok := q.pending[id]
+	delete(q.pending, id)
+	pendingApprovals.Set(float64(len(q.pending)))
+	return pc, ok
+}
+
+// Pending returns all changes awaiting approval.
+func (q *ApprovalQueue) Pending() []*PendingChange {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireLocked()
+	pending := make([]*PendingChange, 0, len(q.pending))
+	for _, pc := range q.pending {
+		pending = append(pending, pc)
+	}
+	return pending
+}
+
+func (q *ApprovalQueue) expireLocked() {
+	now := time.Now()
+	for id, pc := range q.pending {
+		if now.After(pc.Expires) {
+			delete(q.pending, id)
+			expiredApprovalsTotal.Inc()
+		}
+	}
+	pendingApprovals.Set(float64(len(q.pending)))
+}
+
+// Handler serves the approval API, authenticated with a bearer token:
+//
+//	GET  <prefix>               lists pending changes
+//	POST <prefix>/<id>/approve  applies a pending change
+//	POST <prefix>/<id>/reject   discards a pending change
+func (q *ApprovalQueue) Handler(prefix string, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if path == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(q.Pending())
+			return
+		}
+
+		id, action, ok := strings.Cut(path, "/")
+		if !ok || (action != "approve" && action != "reject") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		pc, ok := q.take(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if action == "approve" {
+			q.mu.Lock()
+			apply := q.apply
+			q.mu.Unlock()
+			if err := apply(r.Context(), pc.Changes); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
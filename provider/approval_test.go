@@ -0,0 +1,72 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestApprovalQueue(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	queue := NewApprovalQueue(time.Hour)
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithApprovalQueue(queue))
+	assert.NoError(t, err)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.2.3.4")},
+	}
+	// identical change sets are only parked once
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, queue.Pending(), 1)
+	assert.Empty(t, srv.Records("example.com"))
+
+	handler := queue.Handler("/admin/approvals", "secret")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/approvals", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/approvals", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var pending []PendingChange
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&pending))
+	assert.Len(t, pending, 1)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/admin/approvals/"+pending[0].ID+"/approve", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Len(t, srv.Records("example.com"), 1)
+	assert.Empty(t, queue.Pending())
+
+	// approving twice fails
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestApprovalQueueExpiry(t *testing.T) {
+	queue := NewApprovalQueue(-time.Second)
+	_, err := queue.park(&plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.2.3.4")}})
+	assert.NoError(t, err)
+	assert.Empty(t, queue.Pending())
+}
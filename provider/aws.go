@@ -0,0 +1,283 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// awsCredentials are the credentials of an AWS principal, temporary ones with a session token.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsAPI calls AWS APIs in a region as the workload. It signs in with the first of these found in
+// the environment, as set up by the AWS SDKs:
+//
+//   - static keys in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//   - EKS Pod Identity in AWS_CONTAINER_CREDENTIALS_FULL_URI and AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE
+//   - IAM roles for service accounts in AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE
+type awsAPI struct {
+	region      string
+	stsEndpoint string
+	client      *http.Client
+}
+
+// newAWSAPI returns the API of region, taken from the region parameter in params, the ARN of the
+// resource, AWS_REGION or AWS_DEFAULT_REGION, in this order.
+func newAWSAPI(params url.Values, arn string, client *http.Client) (awsAPI, error) {
+	region := params.Get("region")
+	if parts := strings.Split(arn, ":"); region == "" && len(parts) > 3 && parts[0] == "arn" {
+		region = parts[3]
+	}
+	for _, envar := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region == "" {
+			region = os.Getenv(envar)
+		}
+	}
+	if region == "" {
+		return awsAPI{}, fmt.Errorf("no AWS region, add ?region=<region> or set AWS_REGION")
+	}
+	return awsAPI{region: region, stsEndpoint: awsEndpoint("sts", "STS", region), client: client}, nil
+}
+
+// awsEndpoint returns the endpoint of service in region, or the one set in the environment variable
+// named after the service ID as for the AWS SDKs, e.g. AWS_ENDPOINT_URL_SECRETS_MANAGER.
+func awsEndpoint(service, serviceID, region string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_" + strings.ToUpper(strings.ReplaceAll(serviceID, " ", "_"))); endpoint != "" {
+		return endpoint
+	}
+	return "https://" + service + "." + region + ".amazonaws.com"
+}
+
+// call calls the action target of the JSON API of service at endpoint with in and decodes the
+// response into out.
+func (a awsAPI) call(ctx context.Context, service, endpoint, target string, in, out any) error {
+	creds, err := a.credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get AWS credentials: %w", err)
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signAWSRequest(req, body, creds, a.region, service, time.Now())
+	return doJSON(a.client, req, out)
+}
+
+// credentials returns the credentials of the workload from the environment.
+func (a awsAPI) credentials(ctx context.Context) (awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return a.containerCredentials(ctx, uri)
+	}
+	if tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && role != "" {
+		return a.webIdentityCredentials(ctx, tokenFile, role)
+	}
+	return awsCredentials{}, fmt.Errorf("none found in the environment, set up EKS Pod Identity, IAM roles for service accounts or AWS_ACCESS_KEY_ID")
+}
+
+// containerCredentials gets the credentials from the EKS Pod Identity agent at uri.
+func (a awsAPI) containerCredentials(ctx context.Context, uri string) (awsCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	var resp struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+	}
+	if err := doJSON(a.client, req, &resp); err != nil {
+		return awsCredentials{}, err
+	}
+	return awsCredentials{AccessKeyID: resp.AccessKeyID, SecretAccessKey: resp.SecretAccessKey, SessionToken: resp.Token}, nil
+}
+
+// webIdentityCredentials assumes role with the service account token in tokenFile.
+func (a awsAPI) webIdentityCredentials(ctx context.Context, tokenFile, role string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "external-dns-netcup-webhook"
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {role},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.stsEndpoint+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := doRequest(a.client, req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return awsCredentials{}, fmt.Errorf("unable to parse AssumeRoleWithWebIdentity response: %w", err)
+	}
+	return awsCredentials(resp.Credentials), nil
+}
+
+// awsSecretsManagerSource reads the credentials from a secret of AWS Secrets Manager.
+type awsSecretsManagerSource struct {
+	awsAPI
+	secretID string
+	endpoint string
+}
+
+// newAWSSecretsManagerSource returns the source for the secret name or ARN in location.
+func newAWSSecretsManagerSource(location string, client *http.Client) (*awsSecretsManagerSource, error) {
+	secretID, query, _ := strings.Cut(location, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWS Secrets Manager parameters '%s': %w", query, err)
+	}
+	api, err := newAWSAPI(params, secretID, client)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWS Secrets Manager secret '%s': %w", secretID, err)
+	}
+	return &awsSecretsManagerSource{awsAPI: api, secretID: secretID, endpoint: awsEndpoint("secretsmanager", "Secrets Manager", api.region)}, nil
+}
+
+// Credentials implements CredentialsSource.
+func (s *awsSecretsManagerSource) Credentials(ctx context.Context) (Credentials, error) {
+	var secret struct {
+		SecretString string
+		SecretBinary []byte
+	}
+	if err := s.call(ctx, "secretsmanager", s.endpoint, "secretsmanager.GetSecretValue", map[string]string{"SecretId": s.secretID}, &secret); err != nil {
+		return Credentials{}, fmt.Errorf("unable to get AWS Secrets Manager secret '%s': %w", s.secretID, err)
+	}
+	if secret.SecretString == "" {
+		return parseCredentials(secret.SecretBinary)
+	}
+	return parseCredentials([]byte(secret.SecretString))
+}
+
+// kmsDecrypt decrypts ciphertext with the AWS KMS key it was encrypted with.
+func (a awsAPI) kmsDecrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+	if err := a.call(ctx, "kms", awsEndpoint("kms", "KMS", a.region), "TrentService.Decrypt", map[string][]byte{"CiphertextBlob": ciphertext}, &resp); err != nil {
+		return nil, fmt.Errorf("unable to decrypt with AWS KMS: %w", err)
+	}
+	return resp.Plaintext, nil
+}
+
+// signAWSRequest signs req with body for service in region with Signature Version 4. It signs the
+// host, the content type and all X-Amz headers.
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var params []string
+	for _, key := range keys {
+		values := slices.Sorted(slices.Values(query[key]))
+		for _, value := range values {
+			params = append(params, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{req.Method, path, strings.Join(params, "&"), canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsEscape escapes s for a canonical query string, which encodes spaces as %20.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
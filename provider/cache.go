@@ -0,0 +1,210 @@
+package netcup
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// CachedZone is the state of a zone read from the Netcup API.
+type CachedZone struct {
+	TTL     string         `json:"ttl"`
+	Serial  string         `json:"serial"`
+	Records []nc.DnsRecord `json:"records"`
+	Fetched time.Time      `json:"fetched"`
+}
+
+// RecordCache stores the state of zones between reads, so external-dns syncs within the cache TTL do
+// not call the Netcup API. Implementations must be safe for concurrent use; a shared backend lets
+// multiple replicas use the same cache.
+type RecordCache interface {
+	// Get returns the cached state of zone, if any, regardless of its age.
+	Get(zone string) (CachedZone, bool)
+	// Set stores the state of zone.
+	Set(zone string, cached CachedZone)
+	// Invalidate removes zone, e.g. after changing its records.
+	Invalidate(zone string)
+}
+
+// WithRecordCache reads zones from cache while they are younger than ttl. Zones are invalidated when
+// changes are applied to them.
+func WithRecordCache(cache RecordCache, ttl time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.cache = cache
+		p.cacheTTL = ttl
+	}
+}
+
+// WithStaleStart serves every zone found in the cache once after a start even if its entry expired,
+// and refreshes it in the background. With a file cache this answers the first sync after a restart
+// without waiting for the Netcup API.
+func WithStaleStart() Option {
+	return func(p *NetcupProvider) {
+		p.staleStart = true
+	}
+}
+
+// cachedZone returns the cached state of zone if it is fresh.
+func (p *NetcupProvider) cachedZone(zone string) (CachedZone, bool) {
+	if p.cache == nil {
+		return CachedZone{}, false
+	}
+	cached, ok := p.cache.Get(zone)
+	if !ok || time.Since(cached.Fetched) >= p.refreshTTL() {
+		return CachedZone{}, false
+	}
+	return cached, true
+}
+
+// MemoryRecordCache keeps zones in memory.
+type MemoryRecordCache struct {
+	mu    sync.Mutex
+	zones map[string]CachedZone
+}
+
+// NewMemoryRecordCache returns an empty in-memory cache.
+func NewMemoryRecordCache() *MemoryRecordCache {
+	return &MemoryRecordCache{zones: map[string]CachedZone{}}
+}
+
+// Get implements RecordCache.
+func (c *MemoryRecordCache) Get(zone string) (CachedZone, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.zones[zone]
+	return cached, ok
+}
+
+// Set implements RecordCache.
+func (c *MemoryRecordCache) Set(zone string, cached CachedZone) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.zones[zone] = cached
+}
+
+// Invalidate implements RecordCache.
+func (c *MemoryRecordCache) Invalidate(zone string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.zones, zone)
+}
+
+// FileRecordCache keeps every zone in a JSON file of a directory, which replicas may share. Errors
+// are logged and treated as cache misses.
+type FileRecordCache struct {
+	dir       string
+	encrypter *Encrypter
+	logger    *slog.Logger
+}
+
+// NewFileRecordCache returns a cache storing zones in dir, creating it if necessary. The files are
+// encrypted with encrypter unless it is nil.
+func NewFileRecordCache(dir string, encrypter *Encrypter, logger *slog.Logger) (*FileRecordCache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileRecordCache{dir: dir, encrypter: encrypter, logger: logger}, nil
+}
+
+func (c *FileRecordCache) path(zone string) string {
+	return filepath.Join(c.dir, filepath.Base(zone)+".json")
+}
+
+// Get implements RecordCache.
+func (c *FileRecordCache) Get(zone string) (CachedZone, bool) {
+	content, err := os.ReadFile(c.path(zone))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logger.Warn("unable to read cached zone", "zone", zone, "error", err.Error())
+		}
+		return CachedZone{}, false
+	}
+	content, err = c.encrypter.open(content)
+	if err != nil {
+		c.logger.Warn("unable to decrypt cached zone", "zone", zone, "error", err.Error())
+		return CachedZone{}, false
+	}
+	var cached CachedZone
+	if err := json.Unmarshal(content, &cached); err != nil {
+		c.logger.Warn("unable to parse cached zone", "zone", zone, "error", err.Error())
+		return CachedZone{}, false
+	}
+	return cached, true
+}
+
+// Set implements RecordCache.
+func (c *FileRecordCache) Set(zone string, cached CachedZone) {
+	if err := c.write(zone, cached); err != nil {
+		c.logger.Warn("unable to cache zone", "zone", zone, "error", err.Error())
+	}
+}
+
+// write replaces the file of zone through a temporary file, so concurrent readers never see a
+// partially written zone.
+func (c *FileRecordCache) write(zone string, cached CachedZone) error {
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	content, err = c.encrypter.seal(content)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(c.path(zone))+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(zone))
+}
+
+// Invalidate implements RecordCache.
+func (c *FileRecordCache) Invalidate(zone string) {
+	if err := os.Remove(c.path(zone)); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("unable to invalidate cached zone", "zone", zone, "error", err.Error())
+	}
+}
+
+// staleZone returns the expired cached state of zone if it may be served while refreshing it. Each
+// zone is only served stale once.
+func (p *NetcupProvider) staleZone(zone string) (CachedZone, bool) {
+	if p.cache == nil || !p.staleStart {
+		return CachedZone{}, false
+	}
+	p.staleServedMu.Lock()
+	defer p.staleServedMu.Unlock()
+	if _, served := p.staleServed[zone]; served {
+		return CachedZone{}, false
+	}
+	cached, ok := p.cache.Get(zone)
+	if !ok {
+		return CachedZone{}, false
+	}
+	if p.staleServed == nil {
+		p.staleServed = map[string]struct{}{}
+	}
+	p.staleServed[zone] = struct{}{}
+	return cached, true
+}
+
+// refreshZone reads zone from the Netcup API into the cache.
+func (p *NetcupProvider) refreshZone(zone string) {
+	sessions := p.newSessionSet()
+	defer sessions.close()
+	if _, err := p.readZone(sessions, zone); err != nil {
+		p.logger.Warn("unable to refresh stale cached zone", "zone", zone, "error", err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package netcup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRecordCaches(t *testing.T) {
+	fileCache, err := NewFileRecordCache(filepath.Join(t.TempDir(), "cache"), nil, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+	for name, cache := range map[string]RecordCache{"memory": NewMemoryRecordCache(), "file": fileCache} {
+		_, ok := cache.Get("example.com")
+		assert.False(t, ok, name)
+
+		cached := CachedZone{
+			TTL:     "300",
+			Serial:  "2024010101",
+			Records: []nc.DnsRecord{{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1"}},
+			Fetched: time.Now().Round(0),
+		}
+		cache.Set("example.com", cached)
+		got, ok := cache.Get("example.com")
+		assert.True(t, ok, name)
+		assert.True(t, cached.Fetched.Equal(got.Fetched), name)
+		got.Fetched = cached.Fetched
+		assert.Equal(t, cached, got, name)
+
+		cache.Invalidate("example.com")
+		_, ok = cache.Get("example.com")
+		assert.False(t, ok, name)
+		// invalidating a missing zone is fine
+		cache.Invalidate("example.com")
+	}
+
+	// a corrupt file is a miss
+	assert.NoError(t, os.WriteFile(filepath.Join(fileCache.dir, "example.com.json"), []byte("{"), 0o600))
+	_, ok := fileCache.Get("example.com")
+	assert.False(t, ok)
+}
+
+func TestRecordCacheProvider(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithRecordCache(NewMemoryRecordCache(), time.Hour))
+	assert.NoError(t, err)
+
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 1, srv.Calls("infoDnsRecords"))
+
+	// served from the cache without logging in
+	logins := srv.Calls("login")
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 1, srv.Calls("infoDnsRecords"))
+	assert.Equal(t, logins, srv.Calls("login"))
+
+	// applying changes invalidates the zone
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+	}))
+	calls := srv.Calls("infoDnsRecords")
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, calls+1, srv.Calls("infoDnsRecords"))
+
+	// expired entries are read again
+	p.cacheTTL = 0
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, calls+2, srv.Calls("infoDnsRecords"))
+}
+
+func TestRecordCacheStaleStart(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "2.2.2.2"})
+
+	// the cache of a previous run
+	cache, err := NewFileRecordCache(t.TempDir(), nil, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+	cache.Set("example.com", CachedZone{
+		TTL:     "300",
+		Records: []nc.DnsRecord{{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1"}},
+		Fetched: time.Now().Add(-time.Hour),
+	})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithRecordCache(cache, time.Minute), WithStaleStart())
+	assert.NoError(t, err)
+
+	// the stale records are served once and refreshed in the background
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, endpoint.Targets{"1.1.1.1"}, records[0].Targets)
+	}
+	assert.Eventually(t, func() bool {
+		cached, ok := cache.Get("example.com")
+		return ok && time.Since(cached.Fetched) < time.Minute
+	}, 5*time.Second, 10*time.Millisecond)
+
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, endpoint.Targets{"2.2.2.2"}, records[0].Targets)
+	}
+	assert.Equal(t, 1, srv.Calls("infoDnsRecords"))
+
+	// expired entries are not served stale again
+	p.cacheTTL = 0
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Calls("infoDnsRecords"))
+}
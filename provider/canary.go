@@ -0,0 +1,61 @@
+package netcup
+
+import (
+	"fmt"
+	"slices"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// WithCanaryZone applies changes to zone first and only proceeds with the other zones if
+// the changes to zone could be verified.
+func WithCanaryZone(zone string) Option {
+	return func(p *NetcupProvider) {
+		p.canaryZone = zone
+	}
+}
+
+// applyOrder returns the zones in the order changes are applied: the canary zone first,
+// followed by the remaining zones sorted by name.
+func (p *NetcupProvider) applyOrder(zones []string) []string {
+	ordered := append([]string{}, zones...)
+	slices.Sort(ordered)
+	if idx := slices.Index(ordered, p.canaryZone); idx > 0 {
+		ordered = append(append([]string{p.canaryZone}, ordered[:idx]...), ordered[idx+1:]...)
+	}
+	return ordered
+}
+
+// verifyZoneChanges checks that the records of change are reflected in the zone:
+// created and updated records exist and deleted records are gone.
+func verifyZoneChanges(session *nc.NetcupSession, zoneName string, change *NetcupChange) error {
+	recs, err := fetchZoneRecords(session, zoneName)
+	if err != nil {
+		return fmt.Errorf("unable to get DNS records for verification: %v", err)
+	}
+
+	contains := func(list []nc.DnsRecord, rec nc.DnsRecord) bool {
+		return slices.ContainsFunc(list, func(r nc.DnsRecord) bool {
+			return r.Type == rec.Type && r.Hostname == rec.Hostname && r.Destination == rec.Destination
+		})
+	}
+
+	desired := append(append([]nc.DnsRecord{}, *change.Create...), *change.UpdateNew...)
+	for _, rec := range desired {
+		if !contains(recs, rec) {
+			return fmt.Errorf("record %s %s %s missing after apply", rec.Hostname, rec.Type, rec.Destination)
+		}
+	}
+	removed := append(append([]nc.DnsRecord{}, *change.Delete...), *change.UpdateOld...)
+	for _, rec := range removed {
+		if contains(recs, rec) && !contains(desired, rec) {
+			return fmt.Errorf("record %s %s %s still present after apply", rec.Hostname, rec.Type, rec.Destination)
+		}
+	}
+	return nil
+}
+
+// isNoRecordsResponse reports whether the last response of session indicates a zone without records.
+func isNoRecordsResponse(session *nc.NetcupSession) bool {
+	return session.LastResponse != nil && session.LastResponse.Status == string(nc.StatusError) && session.LastResponse.StatusCode == statusCodeNoRecords
+}
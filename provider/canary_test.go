@@ -0,0 +1,65 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestCanaryZone(t *testing.T) {
+	t.Run("ApplyOrder", testCanaryApplyOrder)
+	t.Run("Abort", testCanaryAbort)
+	t.Run("Invalid", testCanaryInvalid)
+}
+
+func testCanaryApplyOrder(t *testing.T) {
+	p := &NetcupProvider{canaryZone: "c.com"}
+	assert.Equal(t, []string{"c.com", "a.com", "b.com"}, p.applyOrder([]string{"b.com", "c.com", "a.com"}))
+
+	p = &NetcupProvider{}
+	assert.Equal(t, []string{"a.com", "b.com"}, p.applyOrder([]string{"b.com", "a.com"}))
+}
+
+func testCanaryAbort(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("canary.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com", "canary.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithCanaryZone("canary.com"))
+	assert.NoError(t, err)
+
+	changes := func(target string) *plan.Changes {
+		return &plan.Changes{
+			Create: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("api.canary.com", endpoint.RecordTypeA, target),
+				endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, target),
+			},
+		}
+	}
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes("2.2.2.2")))
+	assert.Len(t, srv.Records("canary.com"), 2)
+	assert.Len(t, srv.Records("example.com"), 2)
+
+	// updates to the canary zone fail, so the other zone must not be touched
+	srv.FailAction("updateDnsRecords", 4000)
+	assert.Error(t, p.ApplyChanges(context.TODO(), changes("3.3.3.3")))
+	srv.FailAction("updateDnsRecords", 0)
+	assert.Len(t, srv.Records("example.com"), 2)
+}
+
+func testCanaryInvalid(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	_, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger, WithCanaryZone("canary.com"))
+	assert.Error(t, err)
+}
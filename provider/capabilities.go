@@ -0,0 +1,29 @@
+package netcup
+
+// Capabilities describes the features enabled in a provider, so clients can detect them without
+// knowing the version of the webhook.
+type Capabilities struct {
+	RecordTypes []string `json:"recordTypes"`
+	// MultiTarget reports that endpoints may have several targets, each stored as a record.
+	MultiTarget    bool `json:"multiTarget"`
+	RecordCache    bool `json:"recordCache"`
+	DryRun         bool `json:"dryRun"`
+	Shadow         bool `json:"shadow"`
+	Approvals      bool `json:"approvals"`
+	ZoneLocks      bool `json:"zoneLocks"`
+	ProtectDeletes bool `json:"protectDeletes"`
+}
+
+// Capabilities returns the features enabled in the provider.
+func (p *NetcupProvider) Capabilities() Capabilities {
+	return Capabilities{
+		RecordTypes:    p.ManagedRecordTypes(),
+		MultiTarget:    true,
+		RecordCache:    p.refreshTTL() > 0,
+		DryRun:         p.dryRun,
+		Shadow:         p.shadow != nil,
+		Approvals:      p.approvals != nil,
+		ZoneLocks:      p.zoneLocker != nil,
+		ProtectDeletes: p.protectDeletes,
+	}
+}
@@ -0,0 +1,74 @@
+package netcup
+
+import (
+	"context"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ZonePlan holds the records that would be sent to the Netcup API for a zone.
+type ZonePlan struct {
+	Zone      string         `json:"zone"`
+	Create    []nc.DnsRecord `json:"create,omitempty"`
+	UpdateOld []nc.DnsRecord `json:"updateOld,omitempty"`
+	UpdateNew []nc.DnsRecord `json:"updateNew,omitempty"`
+	Delete    []nc.DnsRecord `json:"delete,omitempty"`
+	// Error is set if the records of the zone could not be planned.
+	Error string `json:"error,omitempty"`
+}
+
+// ChangePlan holds the records that applying a set of changes would send to the Netcup API.
+type ChangePlan struct {
+	Zones []ZonePlan `json:"zones"`
+	// Refused explains changes that would be refused, e.g. because another owner holds the record.
+	Refused string `json:"refused,omitempty"`
+}
+
+// PlanChanges passes changes through the same stages and conversion as ApplyChanges and returns the
+// records that would be sent to the Netcup API, without applying them or waiting for approval. The
+// current records of the affected zones are read to resolve record IDs.
+func (p *NetcupProvider) PlanChanges(ctx context.Context, changes *plan.Changes) (*ChangePlan, error) {
+	result := &ChangePlan{Zones: []ZonePlan{}}
+	changes = p.runChangeStages(ctx, changes, p.changeStages()...)
+	if changes == nil {
+		return result, nil
+	}
+	changes, ownershipErr := p.checkOwnership(changes)
+	if ownershipErr != nil {
+		result.Refused = ownershipErr.Error()
+	}
+
+	zones := p.managedZones()
+	perZoneChanges := p.splitChanges(changes, zones)
+	sessions := p.newSessionSet()
+	defer sessions.close()
+	for _, zoneName := range p.applyOrder(zones) {
+		c := perZoneChanges[zoneName]
+		if !c.HasChanges() {
+			continue
+		}
+		zonePlan := ZonePlan{Zone: zoneName}
+		if p.zoneMissing(zoneName) {
+			zonePlan.Error = "zone is missing from the Netcup account"
+			result.Zones = append(result.Zones, zonePlan)
+			continue
+		}
+		session, err := sessions.forZone(zoneName)
+		if err != nil {
+			return nil, err
+		}
+		change, err := p.convertZoneChanges(session, zoneName, c)
+		if err != nil {
+			sessions.invalidate(zoneName, err)
+			zonePlan.Error = err.Error()
+		} else {
+			zonePlan.Create = *change.Create
+			zonePlan.UpdateOld = *change.UpdateOld
+			zonePlan.UpdateNew = *change.UpdateNew
+			zonePlan.Delete = *change.Delete
+		}
+		result.Zones = append(result.Zones, zonePlan)
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestPlanChanges(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "old", Type: "A", Destination: "2.2.2.2"},
+	)
+
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	created := endpoint.NewEndpoint("txt.example.com", endpoint.RecordTypeTXT, "v=spf1 -all")
+	created.SetProviderSpecificProperty(requestedTTLProperty, "60")
+	result, err := p.PlanChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{created},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "3.3.3.3")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &ChangePlan{Zones: []ZonePlan{{
+		Zone:   "example.com",
+		Create: []nc.DnsRecord{{Hostname: "txt", Type: "TXT", Destination: "v=spf1 -all"}},
+		// the update is merged into a single record keeping its ID
+		UpdateOld: []nc.DnsRecord{},
+		UpdateNew: []nc.DnsRecord{{Id: "1", Hostname: "www", Type: "A", Destination: "3.3.3.3"}},
+		Delete:    []nc.DnsRecord{{Id: "2", Hostname: "old", Type: "A", Destination: "2.2.2.2", DeleteRecord: true}},
+	}}}, result)
+
+	// nothing was applied or remembered
+	assert.Len(t, srv.Records("example.com"), 2)
+	assert.Equal(t, 0, srv.Calls("updateDnsRecords"))
+	assert.Empty(t, p.requestedTTLs)
+}
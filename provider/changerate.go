@@ -0,0 +1,77 @@
+package netcup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// changeRateWindow is the rolling window in which the changes of a ChangeRateLimiter are counted.
+const changeRateWindow = time.Hour
+
+// ChangeRateLimiter stops applying changes once more than a maximum number of records changed within
+// the last hour, protecting the zones from reconciliation loops that keep flapping records.
+type ChangeRateLimiter struct {
+	max int
+	now func() time.Time
+
+	mu      sync.Mutex
+	batches []changeBatch
+}
+
+type changeBatch struct {
+	time    time.Time
+	records int
+}
+
+// NewChangeRateLimiter allows at most max record changes per hour.
+func NewChangeRateLimiter(max int) *ChangeRateLimiter {
+	return &ChangeRateLimiter{max: max, now: time.Now}
+}
+
+// WithChangeRateLimiter refuses changes that would exceed the rate allowed by limiter.
+func WithChangeRateLimiter(limiter *ChangeRateLimiter) Option {
+	return func(p *NetcupProvider) {
+		p.changeRate = limiter
+	}
+}
+
+// reserve counts n record changes unless they would exceed the limit within the window.
+// The changes of a zone are refused as a whole; external-dns retries them on a later run.
+func (l *ChangeRateLimiter) reserve(n int) error {
+	if l == nil || n == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	used := 0
+	kept := l.batches[:0]
+	for _, b := range l.batches {
+		if now.Sub(b.time) < changeRateWindow {
+			kept = append(kept, b)
+			used += b.records
+		}
+	}
+	l.batches = kept
+	changesInWindow.Set(float64(used))
+
+	if used+n > l.max {
+		changeRateExceeded.Set(1)
+		changeRateRejectionsTotal.Inc()
+		retryAfter := changeRateWindow
+		if len(l.batches) > 0 {
+			retryAfter = l.batches[0].time.Add(changeRateWindow).Sub(now)
+		}
+		return &APIError{
+			Kind:       ErrChangeRateExceeded,
+			Err:        fmt.Errorf("%d record changes would exceed the limit of %d per hour, %d already applied", n, l.max, used),
+			RetryAfter: retryAfter,
+		}
+	}
+	l.batches = append(l.batches, changeBatch{time: now, records: n})
+	changesInWindow.Set(float64(used + n))
+	changeRateExceeded.Set(0)
+	return nil
+}
@@ -0,0 +1,84 @@
+package netcup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+func TestChangeRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewChangeRateLimiter(5)
+	l.now = func() time.Time { return now }
+
+	assert.NoError(t, l.reserve(3))
+	now = now.Add(30 * time.Minute)
+	assert.NoError(t, l.reserve(2))
+	assert.Equal(t, float64(5), testutil.ToFloat64(changesInWindow))
+
+	err := l.reserve(1)
+	assert.ErrorIs(t, err, ErrChangeRateExceeded)
+	assert.ErrorIs(t, err, provider.SoftError)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 30*time.Minute, apiErr.RetryAfter)
+	assert.Equal(t, float64(1), testutil.ToFloat64(changeRateExceeded))
+
+	// the first batch leaves the window
+	now = now.Add(30 * time.Minute)
+	assert.NoError(t, l.reserve(3))
+	assert.Equal(t, float64(0), testutil.ToFloat64(changeRateExceeded))
+	assert.ErrorIs(t, l.reserve(1), ErrChangeRateExceeded)
+}
+
+func TestApplyChangesChangeRate(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithChangeRateLimiter(NewChangeRateLimiter(2)))
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+	}}))
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("c.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+	}})
+	assert.ErrorIs(t, err, ErrChangeRateExceeded)
+	assert.Len(t, srv.Records("example.com"), 2)
+}
+
+func TestApplyChangesChangeRateSkippedZones(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithChangeRateLimiter(NewChangeRateLimiter(2)), WithMissingZoneBackoff(time.Hour))
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// changes of a skipped zone do not count against the limit
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("a.example.org", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("b.example.org", endpoint.RecordTypeA, "1.1.1.1"),
+	}}))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+	}}))
+	assert.Len(t, srv.Records("example.com"), 2)
+}
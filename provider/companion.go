@@ -0,0 +1,157 @@
+package netcup
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/yaml"
+)
+
+// hostnamePlaceholder is replaced by the hostname of the matched record in companion hostnames.
+const hostnamePlaceholder = "{hostname}"
+
+// CompanionRecord is a record ensured to exist in the zone of a matched record. Hostname is
+// relative to the zone, '@' for the apex, and may contain {hostname}.
+type CompanionRecord struct {
+	Hostname    string `json:"hostname"`
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+	Priority    string `json:"priority"`
+}
+
+// CompanionRule matches created and updated records by hostname glob and record type, empty fields
+// match everything, and ensures either Record or, for A records, an AAAA record mapping the address
+// into the /96 NAT64Prefix exists.
+type CompanionRule struct {
+	Hostname    string           `json:"hostname"`
+	RecordTypes []string         `json:"recordTypes"`
+	Record      *CompanionRecord `json:"record"`
+	NAT64Prefix string           `json:"nat64Prefix"`
+
+	nat64Prefix netip.Prefix
+}
+
+// CompanionRules derive companion records from the records written by the provider. Companion
+// records are only created, never updated or deleted.
+type CompanionRules struct {
+	Rules []CompanionRule `json:"rules"`
+}
+
+// ReadCompanionRulesFile reads a YAML or JSON file of companion record rules.
+func ReadCompanionRulesFile(file string) (*CompanionRules, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rules := &CompanionRules{}
+	if err := yaml.UnmarshalStrict(content, rules); err != nil {
+		return nil, fmt.Errorf("unable to parse companion records file '%s': %v", file, err)
+	}
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("invalid companion records file '%s': %v", file, err)
+	}
+	return rules, nil
+}
+
+// WithCompanionRules creates the companion records of rules along with the records they match.
+func WithCompanionRules(rules *CompanionRules) Option {
+	return func(p *NetcupProvider) {
+		p.companions = rules
+	}
+}
+
+func (c *CompanionRules) validate() error {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if _, err := path.Match(rule.Hostname, ""); err != nil {
+			return fmt.Errorf("rule %d: invalid hostname glob '%s': %v", i, rule.Hostname, err)
+		}
+		switch {
+		case (rule.Record == nil) == (rule.NAT64Prefix == ""):
+			return fmt.Errorf("rule %d: exactly one of record and nat64Prefix must be set", i)
+		case rule.Record != nil:
+			if rule.Record.Hostname == "" || rule.Record.Type == "" || rule.Record.Destination == "" {
+				return fmt.Errorf("rule %d: record requires hostname, type and destination", i)
+			}
+		default:
+			prefix, err := netip.ParsePrefix(rule.NAT64Prefix)
+			if err != nil || !prefix.Addr().Is6() || prefix.Bits() != 96 {
+				return fmt.Errorf("rule %d: nat64Prefix must be an IPv6 /96 prefix, got '%s'", i, rule.NAT64Prefix)
+			}
+			rule.nat64Prefix = prefix.Masked()
+		}
+	}
+	return nil
+}
+
+func (r CompanionRule) matches(rec nc.DnsRecord, zoneName string) bool {
+	if r.Hostname != "" {
+		name := zoneName
+		if rec.Hostname != "@" {
+			name = rec.Hostname + "." + zoneName
+		}
+		if ok, _ := path.Match(strings.ToLower(r.Hostname), strings.ToLower(name)); !ok {
+			return false
+		}
+	}
+	return len(r.RecordTypes) == 0 || slices.ContainsFunc(r.RecordTypes, func(t string) bool { return strings.EqualFold(t, rec.Type) })
+}
+
+// companion returns the companion record of rec, if any.
+func (r CompanionRule) companion(rec nc.DnsRecord) (nc.DnsRecord, bool) {
+	if r.Record != nil {
+		return nc.DnsRecord{
+			Hostname:    strings.ReplaceAll(r.Record.Hostname, hostnamePlaceholder, rec.Hostname),
+			Type:        r.Record.Type,
+			Destination: r.Record.Destination,
+			Priority:    r.Record.Priority,
+		}, true
+	}
+	addr, err := netip.ParseAddr(rec.Destination)
+	if rec.Type != endpoint.RecordTypeA || err != nil || !addr.Is4() {
+		return nc.DnsRecord{}, false
+	}
+	mapped := r.nat64Prefix.Addr().As16()
+	v4 := addr.As4()
+	copy(mapped[12:], v4[:])
+	return nc.DnsRecord{Hostname: rec.Hostname, Type: endpoint.RecordTypeAAAA, Destination: netip.AddrFrom16(mapped).String()}, true
+}
+
+// add appends the companion records of the created and updated records of change to its creates,
+// unless the zone holding recs or change already has them.
+func (c *CompanionRules) add(change *NetcupChange, zoneName string, recs []nc.DnsRecord, quoting TXTQuoting, logger *slog.Logger) {
+	if c == nil {
+		return
+	}
+	create := *change.Create
+	exists := func(companion nc.DnsRecord) bool {
+		for _, rec := range slices.Concat(recs, create, *change.UpdateNew) {
+			if rec.Hostname == companion.Hostname && rec.Type == companion.Type && quoting.sameDestination(rec.Type, companion.Destination, rec.Destination) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rec := range slices.Concat(*change.Create, *change.UpdateNew) {
+		for _, rule := range c.Rules {
+			if !rule.matches(rec, zoneName) {
+				continue
+			}
+			companion, ok := rule.companion(rec)
+			if !ok || exists(companion) {
+				continue
+			}
+			logger.Info("adding companion record", "zone", zoneName, "record", describeRecord(&companion), "for", describeRecord(&rec))
+			create = append(create, companion)
+		}
+	}
+	change.Create = &create
+}
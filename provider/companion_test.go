@@ -0,0 +1,91 @@
+package netcup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestCompanionRules(t *testing.T) {
+	t.Run("ReadFile", testReadCompanionRulesFile)
+	t.Run("Apply", testApplyCompanionRules)
+}
+
+func testReadCompanionRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "companions.yaml")
+	content := "rules:\n- hostname: '*.apps.example.com'\n  recordTypes: [A]\n  record:\n    hostname: '@'\n    type: CAA\n    destination: '0 issue \"letsencrypt.org\"'\n- nat64Prefix: 64:ff9b::/96\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	rules, err := ReadCompanionRulesFile(path)
+	assert.NoError(t, err)
+	if assert.Len(t, rules.Rules, 2) {
+		assert.Equal(t, &CompanionRecord{Hostname: "@", Type: "CAA", Destination: `0 issue "letsencrypt.org"`}, rules.Rules[0].Record)
+		assert.Equal(t, "64:ff9b::/96", rules.Rules[1].nat64Prefix.String())
+	}
+
+	for _, invalid := range []string{
+		"rules:\n- hostname: '*.example.com'\n",
+		"rules:\n- nat64Prefix: 64:ff9b::/96\n  record:\n    hostname: '@'\n    type: CAA\n    destination: x\n",
+		"rules:\n- record:\n    hostname: '@'\n    type: CAA\n",
+		"rules:\n- nat64Prefix: 64:ff9b::/64\n",
+		"rules:\n- nat64Prefix: 10.0.0.0/8\n",
+		"rules:\n- hostname: '[a'\n  nat64Prefix: 64:ff9b::/96\n",
+		"rules:\n- nat64: 64:ff9b::/96\n",
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(invalid), 0o600))
+		_, err := ReadCompanionRulesFile(path)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func testApplyCompanionRules(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "old.apps", Type: "A", Destination: "192.0.2.1"})
+
+	rules := &CompanionRules{Rules: []CompanionRule{
+		{Hostname: "*.apps.example.com", RecordTypes: []string{"A"}, Record: &CompanionRecord{Hostname: "@", Type: "CAA", Destination: `0 issue "letsencrypt.org"`}},
+		{Hostname: "*.apps.example.com", Record: &CompanionRecord{Hostname: "_info.{hostname}", Type: "TXT", Destination: "managed"}},
+		{RecordTypes: []string{"A"}, NAT64Prefix: "64:ff9b::/96"},
+	}}
+	assert.NoError(t, rules.validate())
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithCompanionRules(rules))
+	assert.NoError(t, err)
+
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("web.apps.example.com", endpoint.RecordTypeA, "192.0.2.10"),
+		endpoint.NewEndpoint("api.apps.example.com", endpoint.RecordTypeA, "192.0.2.11"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "web.apps.example.com"),
+	}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+
+	var records []string
+	for _, rec := range srv.Records("example.com") {
+		records = append(records, describeRecord(&rec))
+	}
+	assert.ElementsMatch(t, []string{
+		"old.apps A 192.0.2.1",
+		"web.apps A 192.0.2.10",
+		"api.apps A 192.0.2.11",
+		"www CNAME web.apps.example.com",
+		`@ CAA 0 issue "letsencrypt.org"`,
+		"_info.web.apps TXT managed",
+		"_info.api.apps TXT managed",
+		"web.apps AAAA 64:ff9b::c000:20a",
+		"api.apps AAAA 64:ff9b::c000:20b",
+	}, records)
+
+	// existing companion records are not created again
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.apps.example.com", endpoint.RecordTypeCNAME, "web.apps.example.com")}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, srv.Records("example.com"), len(records)+2)
+}
@@ -0,0 +1,190 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ConsistencyReport lists the records whose DNS answers differ from the state reported by the API.
+type ConsistencyReport struct {
+	Time       time.Time             `json:"time"`
+	Nameserver string                `json:"nameserver"`
+	Checked    int                   `json:"checked"`
+	Mismatches []ConsistencyMismatch `json:"mismatches"`
+}
+
+// ConsistencyMismatch is a record answered differently by DNS than reported by the API.
+type ConsistencyMismatch struct {
+	DNSName    string   `json:"dnsName"`
+	RecordType string   `json:"recordType"`
+	API        []string `json:"api"`
+	DNS        []string `json:"dns"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// ConsistencyChecker resolves a sample of the managed records at Netcup's authoritative nameservers
+// and compares the answers with the API, catching zones whose changes are not published.
+type ConsistencyChecker struct {
+	records    RecordLister
+	logger     *slog.Logger
+	nameserver string
+	sampleSize int
+	resolver   *net.Resolver
+
+	mu   sync.Mutex
+	last *ConsistencyReport
+}
+
+// RecordLister lists the records of the managed zones, e.g. a NetcupProvider or a provider
+// replaced on configuration reloads.
+type RecordLister interface {
+	Records(ctx context.Context) ([]*endpoint.Endpoint, error)
+}
+
+// NewConsistencyChecker checks up to sampleSize randomly chosen records of records at nameserver.
+// A sample size of 0 checks all records, an empty nameserver uses Netcup's primary nameserver.
+func NewConsistencyChecker(records RecordLister, nameserver string, sampleSize int, logger *slog.Logger) *ConsistencyChecker {
+	if nameserver == "" {
+		nameserver = netcupNameservers[0]
+	}
+	return &ConsistencyChecker{
+		records:    records,
+		logger:     logger,
+		nameserver: nameserver,
+		sampleSize: sampleSize,
+		resolver:   newResolver(nameserver),
+	}
+}
+
+// Check compares a sample of the records with their DNS answers.
+func (c *ConsistencyChecker) Check(ctx context.Context) (*ConsistencyReport, error) {
+	records, err := c.records.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records = slices.DeleteFunc(records, func(ep *endpoint.Endpoint) bool {
+		return !slices.Contains([]string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT}, ep.RecordType)
+	})
+	if c.sampleSize > 0 && len(records) > c.sampleSize {
+		rand.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+		records = records[:c.sampleSize]
+	}
+
+	report := &ConsistencyReport{
+		Time:       time.Now(),
+		Nameserver: c.nameserver,
+		Checked:    len(records),
+		Mismatches: []ConsistencyMismatch{},
+	}
+	for _, ep := range records {
+		want := normalizeAnswers(ep.RecordType, ep.Targets)
+		got, err := c.lookup(ctx, ep)
+		if err != nil {
+			report.Mismatches = append(report.Mismatches, ConsistencyMismatch{DNSName: ep.DNSName, RecordType: ep.RecordType, API: want, Error: err.Error()})
+			continue
+		}
+		got = normalizeAnswers(ep.RecordType, got)
+		if !slices.Equal(want, got) {
+			report.Mismatches = append(report.Mismatches, ConsistencyMismatch{DNSName: ep.DNSName, RecordType: ep.RecordType, API: want, DNS: got})
+		}
+	}
+	consistencyMismatches.Set(float64(len(report.Mismatches)))
+
+	c.mu.Lock()
+	c.last = report
+	c.mu.Unlock()
+	return report, nil
+}
+
+// lookup resolves ep at the nameserver of the checker.
+func (c *ConsistencyChecker) lookup(ctx context.Context, ep *endpoint.Endpoint) ([]string, error) {
+	switch ep.RecordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		network := "ip4"
+		if ep.RecordType == endpoint.RecordTypeAAAA {
+			network = "ip6"
+		}
+		ips, err := c.resolver.LookupIP(ctx, network, ep.DNSName)
+		if err != nil {
+			return nil, err
+		}
+		answers := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+		return answers, nil
+	case endpoint.RecordTypeCNAME:
+		cname, err := c.resolver.LookupCNAME(ctx, ep.DNSName)
+		if err != nil {
+			return nil, err
+		}
+		return []string{cname}, nil
+	default:
+		return c.resolver.LookupTXT(ctx, ep.DNSName)
+	}
+}
+
+// normalizeAnswers brings targets and DNS answers of recordType into a comparable, sorted form.
+func normalizeAnswers(recordType string, answers []string) []string {
+	normalized := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		switch recordType {
+		case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+			if ip := net.ParseIP(answer); ip != nil {
+				answer = ip.String()
+			}
+		case endpoint.RecordTypeCNAME:
+			answer = strings.ToLower(strings.TrimSuffix(answer, "."))
+		case endpoint.RecordTypeTXT:
+			answer = unquoteTXT(answer)
+		}
+		normalized = append(normalized, answer)
+	}
+	slices.Sort(normalized)
+	return normalized
+}
+
+// Run checks the consistency every interval until ctx is cancelled.
+func (c *ConsistencyChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := c.Check(ctx)
+		if err != nil {
+			c.logger.Error("unable to check consistency of records with DNS", "error", err.Error())
+		} else if len(report.Mismatches) > 0 {
+			for _, m := range report.Mismatches {
+				c.logger.Warn("DNS answer differs from Netcup API", "name", m.DNSName, "type", m.RecordType, "api", strings.Join(m.API, ","), "dns", strings.Join(m.DNS, ","), "error", m.Error)
+			}
+		} else {
+			c.logger.Debug("records consistent with DNS", "checked", report.Checked)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP runs a check and returns its report as JSON.
+func (c *ConsistencyChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report, err := c.Check(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
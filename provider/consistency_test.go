@@ -0,0 +1,31 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestNormalizeAnswers(t *testing.T) {
+	assert.Equal(t, []string{"1.1.1.1", "2.2.2.2"}, normalizeAnswers(endpoint.RecordTypeA, []string{"2.2.2.2", "1.1.1.1"}))
+	assert.Equal(t, []string{"2001:db8::1"}, normalizeAnswers(endpoint.RecordTypeAAAA, []string{"2001:0db8:0000::0001"}))
+	assert.Equal(t, []string{"www.example.com"}, normalizeAnswers(endpoint.RecordTypeCNAME, []string{"WWW.example.com."}))
+	assert.Equal(t, []string{"heritage=external-dns"}, normalizeAnswers(endpoint.RecordTypeTXT, []string{"\"heritage=external-dns\""}))
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+
+	checker := NewConsistencyChecker(p, "", 10, p.logger)
+	report, err := checker.Check(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "root-dns.netcup.net", report.Nameserver)
+	assert.Equal(t, 0, report.Checked)
+	assert.Empty(t, report.Mismatches)
+}
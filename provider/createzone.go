@@ -0,0 +1,49 @@
+package netcup
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ZoneSettings are the settings of a zone that PrepareZone sets.
+type ZoneSettings struct {
+	// TTL is the default TTL of the records of the zone, in seconds.
+	TTL int64
+	// DNSSEC signs the zone.
+	DNSSEC bool
+}
+
+// PrepareZone applies settings to zone, which Netcup creates along with the registration of its
+// domain, so the zone is ready for external-dns. In dry run mode the zone is read, and the changes
+// are logged but not applied.
+func (p *NetcupProvider) PrepareZone(zone string, settings ZoneSettings) error {
+	sessions := p.newSessionSet()
+	defer sessions.close()
+
+	session, err := sessions.forZone(zone)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	current, err := session.InfoDnsZone(zone)
+	if err != nil {
+		return fmt.Errorf("unable to query zone '%s': %w", zone, p.apiError(err, false))
+	}
+
+	updated := *current
+	updated.Ttl = strconv.FormatInt(settings.TTL, 10)
+	updated.DnsSecStatus = settings.DNSSEC
+	if updated == *current {
+		p.logger.Info("zone already set up", "zone", zone, "ttl", current.Ttl, "dnssec", current.DnsSecStatus)
+		return nil
+	}
+	attrs := []any{"zone", zone, "ttl", current.Ttl + " -> " + updated.Ttl, "dnssec", fmt.Sprintf("%t -> %t", current.DnsSecStatus, updated.DnsSecStatus)}
+	if p.dryRun {
+		p.logger.Info("dry run - not updating zone settings", attrs...)
+		return nil
+	}
+	if _, err := session.UpdateDnsZone(zone, &updated); err != nil {
+		return fmt.Errorf("unable to update zone '%s': %w", zone, p.apiError(err, false))
+	}
+	p.logger.Info("updated zone settings", attrs...)
+	return nil
+}
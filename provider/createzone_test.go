@@ -0,0 +1,38 @@
+package netcup
+
+import (
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareZone(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "86400")
+	settings := ZoneSettings{TTL: 300, DNSSEC: true}
+
+	// dry run reads the zone only
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	assert.NoError(t, p.PrepareZone("example.com", settings))
+	assert.Equal(t, 0, srv.Calls("updateDnsZone"))
+	assert.Equal(t, "86400", srv.ZoneInfo("example.com").Ttl)
+
+	p, err = NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	assert.NoError(t, p.PrepareZone("example.com", settings))
+	info := srv.ZoneInfo("example.com")
+	assert.Equal(t, "300", info.Ttl)
+	assert.True(t, info.DnsSecStatus)
+
+	// zones set up already are left alone
+	assert.NoError(t, p.PrepareZone("example.com", settings))
+	assert.Equal(t, 1, srv.Calls("updateDnsZone"))
+
+	// the zone of the domain must exist
+	assert.ErrorIs(t, p.PrepareZone("example.org", settings), ErrZoneNotFound)
+}
@@ -0,0 +1,151 @@
+package netcup
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/yaml"
+)
+
+// Credentials are the CCP API credentials of a Netcup customer account.
+type Credentials struct {
+	CustomerID  int    `json:"customerID"`
+	APIKey      string `json:"apiKey"`
+	APIPassword string `json:"apiPassword"`
+}
+
+// validate checks that all fields of the credentials are set.
+func (c Credentials) validate() error {
+	if c.CustomerID == 0 {
+		return fmt.Errorf("netcup provider requires a customer ID")
+	}
+
+	if c.APIKey == "" {
+		return fmt.Errorf("netcup provider requires an API Key")
+	}
+
+	if c.APIPassword == "" {
+		return fmt.Errorf("netcup provider requires an API Password")
+	}
+	return nil
+}
+
+// ReadZoneCredentialsFile reads a YAML or JSON file mapping zone names to Credentials.
+func ReadZoneCredentialsFile(path string) (map[string]Credentials, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	zoneCredentials := map[string]Credentials{}
+	if err := yaml.UnmarshalStrict(content, &zoneCredentials); err != nil {
+		return nil, fmt.Errorf("unable to parse zone credentials file '%s': %v", path, err)
+	}
+	return zoneCredentials, nil
+}
+
+// WithZoneCredentials uses separate credentials for the given zones instead of the default ones.
+func WithZoneCredentials(zoneCredentials map[string]Credentials) Option {
+	return func(p *NetcupProvider) {
+		p.zoneCredentials = zoneCredentials
+	}
+}
+
+// clientForZone returns the client holding the credentials responsible for zone.
+func (p *NetcupProvider) clientForZone(zone string) *nc.NetcupDnsClient {
+	if client, ok := p.zoneClients[zone]; ok {
+		return client
+	}
+	return p.client
+}
+
+// CredentialsError is returned if a set of credentials could not log in. StatusCode and Message
+// are the CCP status code and long message of the rejection, if the Netcup API answered.
+type CredentialsError struct {
+	CustomerID int
+	// Zones lists the zones of the credentials, or is empty for the default credentials.
+	Zones      []string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *CredentialsError) Error() string {
+	credentials := "default credentials"
+	if len(e.Zones) > 0 {
+		credentials = "credentials of zones " + strings.Join(e.Zones, ", ")
+	}
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("login failed for customer %d (%s): %v", e.CustomerID, credentials, e.Err)
+	}
+	return fmt.Sprintf("Netcup API rejected the login of customer %d (%s) with status %d: %s", e.CustomerID, credentials, e.StatusCode, e.Message)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// newCredentialsError returns the CredentialsError of a failed login with err, taking the status
+// code and the message verbatim from the response of the Netcup API. The message is the long
+// message, or the short one if the long message is empty.
+func newCredentialsError(customerID int, zones []string, err error) *CredentialsError {
+	credErr := &CredentialsError{CustomerID: customerID, Zones: zones, Err: err}
+	if m := apiMessageRegexp.FindStringSubmatch(err.Error()); m != nil {
+		credErr.StatusCode, _ = strconv.Atoi(m[1])
+		credErr.Message = cmp.Or(m[3], m[2])
+	}
+	return credErr
+}
+
+// ValidateCredentials logs in and out once with every set of credentials, so rejected credentials
+// are found at startup instead of on the first poll of external-dns. It returns a CredentialsError
+// for every set that could not log in.
+func (p *NetcupProvider) ValidateCredentials(ctx context.Context) error {
+	type credentialsSet struct {
+		client     *nc.NetcupDnsClient
+		customerID int
+		zones      []string
+	}
+	sets := []*credentialsSet{{client: p.client, customerID: p.customerID}}
+	byCredentials := map[Credentials]*credentialsSet{}
+	zones := make([]string, 0, len(p.zoneCredentials))
+	for zone := range p.zoneCredentials {
+		zones = append(zones, zone)
+	}
+	slices.Sort(zones)
+	for _, zone := range zones {
+		c := p.zoneCredentials[zone]
+		set, ok := byCredentials[c]
+		if !ok {
+			set = &credentialsSet{client: p.clientForZone(endpoint.NewDomainFilter([]string{zone}).Filters[0]), customerID: c.CustomerID}
+			byCredentials[c] = set
+			sets = append(sets, set)
+		}
+		set.zones = append(set.zones, zone)
+	}
+
+	var errs []error
+	for _, set := range sets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		session, err := set.client.Login()
+		if err != nil {
+			err = p.apiError(err, true)
+			errs = append(errs, newCredentialsError(set.customerID, set.zones, err))
+			continue
+		}
+		if err := session.Logout(); err != nil {
+			p.logger.Debug("unable to log out from Netcup DNS API", "error", err.Error())
+		}
+		p.logger.Info("validated Netcup API credentials", "customer", set.customerID, "zones", strings.Join(set.zones, ","))
+	}
+	return errors.Join(errs...)
+}
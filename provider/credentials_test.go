@@ -0,0 +1,104 @@
+package netcup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneCredentials(t *testing.T) {
+	t.Run("ReadFile", testReadZoneCredentialsFile)
+	t.Run("Validate", testValidateZoneCredentials)
+	t.Run("Records", testZoneCredentialsRecords)
+	t.Run("ValidateCredentials", testValidateCredentials)
+}
+
+func testReadZoneCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	content := "reseller.example:\n  customerID: 20\n  apiKey: KEY2\n  apiPassword: PASSWORD2\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	zoneCredentials, err := ReadZoneCredentialsFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Credentials{
+		"reseller.example": {CustomerID: 20, APIKey: "KEY2", APIPassword: "PASSWORD2"},
+	}, zoneCredentials)
+
+	assert.NoError(t, os.WriteFile(path, []byte("reseller.example:\n  customer: 20\n"), 0o600))
+	_, err = ReadZoneCredentialsFile(path)
+	assert.Error(t, err)
+}
+
+func testValidateZoneCredentials(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	_, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger, WithZoneCredentials(map[string]Credentials{
+		"example.com": {CustomerID: 20, APIKey: "KEY2"},
+	}))
+	assert.Error(t, err)
+}
+
+func testZoneCredentialsRecords(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.2.3.4"})
+	srv.AddZone("reseller.example", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "5.6.7.8"})
+
+	domainFilter := []string{"example.com", "reseller.example"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithZoneCredentials(map[string]Credentials{
+		"Reseller.Example.": {CustomerID: 20, APIKey: "KEY2", APIPassword: "PASSWORD2"},
+	}))
+	assert.NoError(t, err)
+	assert.NotEqual(t, p.clientForZone("example.com"), p.clientForZone("reseller.example"))
+
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, 1, srv.Logins(10))
+	assert.Equal(t, 1, srv.Logins(20))
+}
+
+func testValidateCredentials(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+
+	domainFilter := []string{"example.com", "reseller.example", "other.example"}
+	logger := promslog.New(&promslog.Config{})
+	reseller := Credentials{CustomerID: 20, APIKey: "KEY2", APIPassword: "PASSWORD2"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithZoneCredentials(map[string]Credentials{
+		"reseller.example": reseller,
+		"other.example":    reseller,
+	}))
+	assert.NoError(t, err)
+
+	// one login per set of credentials
+	assert.NoError(t, p.ValidateCredentials(context.TODO()))
+	assert.Equal(t, 1, srv.Logins(10))
+	assert.Equal(t, 1, srv.Logins(20))
+	assert.Equal(t, 2, srv.Calls("logout"))
+
+	for code, message := range map[int]string{
+		2011: "The given customer number is invalid.",
+		4013: "Customer number, API key or password is wrong.",
+	} {
+		srv.FailActionWithMessage("login", code, message)
+		err := p.ValidateCredentials(context.TODO())
+		assert.ErrorIs(t, err, ErrAuth, message)
+		var credErr *CredentialsError
+		if assert.ErrorAs(t, err, &credErr, message) {
+			assert.Equal(t, code, credErr.StatusCode, message)
+			assert.Equal(t, message, credErr.Message)
+			assert.Equal(t, 10, credErr.CustomerID, message)
+		}
+		assert.Contains(t, err.Error(), message)
+		assert.Contains(t, err.Error(), "other.example, reseller.example", message)
+	}
+}
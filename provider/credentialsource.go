@@ -0,0 +1,124 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// secretRequestTimeout bounds the requests to secret stores and the identity services in front of them.
+const secretRequestTimeout = 30 * time.Second
+
+// secretTransport carries the requests to secret stores, KMS and identity services. It is cloned
+// before the webhook wraps http.DefaultTransport, so the credentials, tokens and keys in these
+// responses never pass the payload logging of --log-api-payloads.
+var secretTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// newSecretClient returns a client for secret stores, KMS and identity services.
+func newSecretClient() *http.Client {
+	return &http.Client{Timeout: secretRequestTimeout, Transport: secretTransport}
+}
+
+// A CredentialsSource loads the default Credentials from outside the flags, e.g. a secret store. It
+// is asked again on every configuration reload, so rotated credentials are picked up on SIGHUP.
+type CredentialsSource interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// NewCredentialsSource returns the source for uri, which is one of
+//
+//	file:///path/to/credentials.yaml
+//	aws-secretsmanager://<secret name or ARN>[?region=<region>]
+//	gcp-secretmanager://projects/<project>/secrets/<secret>[/versions/<version>]
+//
+// The file or secret holds the Credentials as YAML or JSON with the fields customerID, apiKey and
+// apiPassword, like an entry of the zone credentials file.
+func NewCredentialsSource(uri string) (CredentialsSource, error) {
+	scheme, location, ok := strings.Cut(uri, "://")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid credentials source '%s', expected <scheme>://<location>", uri)
+	}
+	client := newSecretClient()
+	switch scheme {
+	case "file":
+		return fileCredentialsSource(location), nil
+	case "aws-secretsmanager":
+		return newAWSSecretsManagerSource(location, client)
+	case "gcp-secretmanager":
+		return newGCPSecretManagerSource(location, client)
+	default:
+		return nil, fmt.Errorf("unsupported credentials source '%s', expected file, aws-secretsmanager or gcp-secretmanager", scheme)
+	}
+}
+
+// fileCredentialsSource reads the credentials from a file, e.g. one rendered by a secrets agent.
+type fileCredentialsSource string
+
+// Credentials implements CredentialsSource.
+func (f fileCredentialsSource) Credentials(_ context.Context) (Credentials, error) {
+	content, err := os.ReadFile(string(f))
+	if err != nil {
+		return Credentials{}, err
+	}
+	return parseCredentials(content)
+}
+
+// parseCredentials parses a secret holding Credentials. The customer ID may be quoted, as secret
+// stores offering key/value secrets keep all values as strings.
+func parseCredentials(content []byte) (Credentials, error) {
+	var secret struct {
+		CustomerID  json.Number `json:"customerID"`
+		APIKey      string      `json:"apiKey"`
+		APIPassword string      `json:"apiPassword"`
+	}
+	if err := yaml.UnmarshalStrict(content, &secret); err != nil {
+		return Credentials{}, fmt.Errorf("unable to parse credentials: %v", err)
+	}
+	c := Credentials{APIKey: secret.APIKey, APIPassword: secret.APIPassword}
+	if secret.CustomerID != "" {
+		id, err := strconv.Atoi(secret.CustomerID.String())
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid customer ID '%s'", secret.CustomerID)
+		}
+		c.CustomerID = id
+	}
+	return c, nil
+}
+
+// doRequest sends req and returns the body of the response. Error responses are returned with their
+// body, which the cloud APIs fill with the reason, e.g. a missing permission.
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: status %d: %s", req.Method, req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// doJSON sends req and decodes the JSON response into out.
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	body, err := doRequest(client, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s %s: unable to parse response: %w", req.Method, req.URL.Host, err)
+	}
+	return nil
+}
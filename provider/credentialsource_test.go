@@ -0,0 +1,150 @@
+package netcup
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCredentialsSecret = `{"customerID":"12345","apiKey":"KEY","apiPassword":"PASSWORD"}`
+
+func TestParseCredentials(t *testing.T) {
+	expected := Credentials{CustomerID: 12345, APIKey: "KEY", APIPassword: "PASSWORD"}
+	c, err := parseCredentials([]byte(testCredentialsSecret))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, c)
+
+	c, err = parseCredentials([]byte("customerID: 12345\napiKey: KEY\napiPassword: PASSWORD\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, c)
+
+	_, err = parseCredentials([]byte(`{"customerID":"abc"}`))
+	assert.Error(t, err)
+	_, err = parseCredentials([]byte(`{"apiToken":"KEY"}`))
+	assert.Error(t, err)
+}
+
+func TestNewCredentialsSource(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(testCredentialsSecret), 0o600))
+	source, err := NewCredentialsSource("file://" + path)
+	assert.NoError(t, err)
+	c, err := source.Credentials(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 12345, c.CustomerID)
+
+	source, err = NewCredentialsSource("aws-secretsmanager://arn:aws:secretsmanager:eu-central-1:123456789012:secret:netcup-AbCdEf")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-central-1", source.(*awsSecretsManagerSource).region)
+	assert.Equal(t, "https://secretsmanager.eu-central-1.amazonaws.com", source.(*awsSecretsManagerSource).endpoint)
+	source, err = NewCredentialsSource("aws-secretsmanager://netcup?region=eu-west-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "netcup", source.(*awsSecretsManagerSource).secretID)
+	assert.Equal(t, "eu-west-1", source.(*awsSecretsManagerSource).region)
+	_, err = NewCredentialsSource("aws-secretsmanager://netcup")
+	assert.Error(t, err)
+
+	source, err = NewCredentialsSource("gcp-secretmanager://projects/p/secrets/netcup")
+	assert.NoError(t, err)
+	assert.Equal(t, "projects/p/secrets/netcup/versions/latest", source.(*gcpSecretManagerSource).name)
+	_, err = NewCredentialsSource("gcp-secretmanager://p/netcup")
+	assert.Error(t, err)
+
+	_, err = NewCredentialsSource("vault://netcup")
+	assert.Error(t, err)
+	_, err = NewCredentialsSource("/etc/netcup")
+	assert.Error(t, err)
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// get-vanilla of the Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	assert.NoError(t, err)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signAWSRequest(req, nil, awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}, "us-east-1", "service", now)
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
+
+func TestAWSSecretsManagerSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		if r.Form.Get("Action") == "AssumeRoleWithWebIdentity" {
+			assert.Equal(t, "arn:aws:iam::123456789012:role/netcup", r.Form.Get("RoleArn"))
+			assert.Equal(t, "SERVICE-ACCOUNT-TOKEN", r.Form.Get("WebIdentityToken"))
+			_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIAEXAMPLE</AccessKeyId><SecretAccessKey>SECRET</SecretAccessKey><SessionToken>SESSION</SessionToken>
+</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+			return
+		}
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "SESSION", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-central-1/secretsmanager/aws4_request")
+		_ = json.NewEncoder(w).Encode(map[string]string{"SecretString": testCredentialsSecret})
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("SERVICE-ACCOUNT-TOKEN\n"), 0o600))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/netcup")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	source, err := NewCredentialsSource("aws-secretsmanager://netcup?region=eu-central-1")
+	assert.NoError(t, err)
+	c, err := source.Credentials(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{CustomerID: 12345, APIKey: "KEY", APIPassword: "PASSWORD"}, c)
+
+	// without workload identity there is nothing to sign in with
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	_, err = source.Credentials(context.TODO())
+	assert.ErrorContains(t, err, "unable to get AWS credentials")
+}
+
+func TestGCPSecretManagerSource(t *testing.T) {
+	checksum := crc32.Checksum([]byte(testCredentialsSecret), crc32.MakeTable(crc32.Castagnoli))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token" {
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token":"ACCESS-TOKEN","expires_in":3599,"token_type":"Bearer"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ACCESS-TOKEN" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/v1/projects/p/secrets/netcup/versions/3:access", r.URL.Path)
+		_ = json.NewEncoder(w).Encode(map[string]any{"payload": map[string]string{
+			"data":       base64.StdEncoding.EncodeToString([]byte(testCredentialsSecret)),
+			"dataCrc32c": strconv.FormatUint(uint64(checksum), 10),
+		}})
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	source, err := NewCredentialsSource("gcp-secretmanager://projects/p/secrets/netcup/versions/3")
+	assert.NoError(t, err)
+	source.(*gcpSecretManagerSource).endpoint = server.URL
+	c, err := source.Credentials(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{CustomerID: 12345, APIKey: "KEY", APIPassword: "PASSWORD"}, c)
+}
@@ -0,0 +1,47 @@
+package netcup
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+)
+
+// netcupNameservers are the authoritative nameservers of zones hosted at Netcup.
+var netcupNameservers = []string{"root-dns.netcup.net", "second-dns.netcup.net", "third-dns.netcup.net"}
+
+// CheckDelegation looks up the NS records of every managed zone via nameserver, or the system
+// resolver if empty, and warns about zones that are not delegated to Netcup's nameservers.
+// Records in such zones can be managed but will never be served. It returns those zones.
+func (p *NetcupProvider) CheckDelegation(ctx context.Context, nameserver string) []string {
+	return p.checkDelegation(ctx, newResolver(nameserver).LookupNS)
+}
+
+func (p *NetcupProvider) checkDelegation(ctx context.Context, lookupNS func(context.Context, string) ([]*net.NS, error)) []string {
+	var undelegated []string
+	for _, zone := range p.managedZones() {
+		nss, err := lookupNS(ctx, zone)
+		if err != nil {
+			p.logger.Warn("unable to verify delegation of zone", "zone", zone, "error", err.Error())
+			continue
+		}
+		var hosts []string
+		delegated := false
+		for _, ns := range nss {
+			host := strings.ToLower(strings.TrimSuffix(ns.Host, "."))
+			hosts = append(hosts, host)
+			if slices.Contains(netcupNameservers, host) {
+				delegated = true
+			}
+		}
+		if delegated {
+			zoneDelegated.WithLabelValues(zone).Set(1)
+			p.logger.Debug("zone is delegated to Netcup", "zone", zone, "nameservers", strings.Join(hosts, ","))
+			continue
+		}
+		zoneDelegated.WithLabelValues(zone).Set(0)
+		p.logger.Warn("zone is NOT delegated to Netcup's nameservers, records managed here will not be served", "zone", zone, "nameservers", strings.Join(hosts, ","), "expected", strings.Join(netcupNameservers, ","))
+		undelegated = append(undelegated, zone)
+	}
+	return undelegated
+}
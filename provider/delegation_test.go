@@ -0,0 +1,30 @@
+package netcup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDelegation(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org", "example.net"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+
+	lookupNS := func(_ context.Context, name string) ([]*net.NS, error) {
+		switch name {
+		case "example.com":
+			return []*net.NS{{Host: "root-dns.netcup.net."}, {Host: "second-dns.netcup.net."}}, nil
+		case "example.org":
+			return []*net.NS{{Host: "ns1.example-dns.org."}}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+	assert.Equal(t, []string{"example.org"}, p.checkDelegation(context.TODO(), lookupNS))
+}
@@ -0,0 +1,80 @@
+package netcup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ReadDomainFilterFile reads a list of zones from a file containing one zone per line.
+// Empty lines and lines starting with '#' are ignored.
+func ReadDomainFilterFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseDomainFilter(content), nil
+}
+
+func parseDomainFilter(content []byte) []string {
+	domains := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains
+}
+
+// DomainFilterRestartWarning is logged when the domain filter changes at runtime: external-dns asks
+// for it only when it starts, so it neither plans records of added zones nor stops planning
+// records of removed ones until it is restarted.
+const DomainFilterRestartWarning = "domain filter changed, restart external-dns to apply it, as it reads the domain filter of the webhook only when it starts"
+
+// WatchDomainFilterFile polls path every interval and updates the domain filter to the union of
+// static and the zones listed in the file whenever the file content changes. Polling is used
+// instead of inotify as mounted ConfigMaps are updated by swapping symlinks.
+// The webhook serves the new zones at once, but external-dns only picks them up when restarted.
+func (p *NetcupProvider) WatchDomainFilterFile(ctx context.Context, path string, static []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			p.logger.Error("unable to read domain filter file", "path", path, "error", err.Error())
+			continue
+		}
+		if bytes.Equal(content, last) {
+			continue
+		}
+
+		domains := append(append([]string{}, static...), parseDomainFilter(content)...)
+		if slices.Equal(endpoint.NewDomainFilter(domains).Filters, p.currentDomainFilter().Filters) {
+			last = content
+			continue
+		}
+		if err := p.SetDomainFilter(domains); err != nil {
+			p.logger.Error("unable to update domain filter", "path", path, "error", err.Error())
+			continue
+		}
+		p.logger.Warn(DomainFilterRestartWarning, "path", path)
+		last = content
+	}
+}
@@ -0,0 +1,48 @@
+package netcup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainFilterFile(t *testing.T) {
+	t.Run("Parse", testParseDomainFilter)
+	t.Run("Watch", testWatchDomainFilterFile)
+}
+
+func testParseDomainFilter(t *testing.T) {
+	content := []byte("example.com\n\n  # comment\n example.org \n")
+	assert.Equal(t, []string{"example.com", "example.org"}, parseDomainFilter(content))
+	assert.Equal(t, []string{}, parseDomainFilter([]byte("")))
+}
+
+func testWatchDomainFilterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains")
+	assert.NoError(t, os.WriteFile(path, []byte("example.com\n"), 0o600))
+
+	domains, err := ReadDomainFilterFile(path)
+	assert.NoError(t, err)
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domains, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.WatchDomainFilterFile(ctx, path, []string{"static.com"}, 10*time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(path, []byte("example.com\nexample.org\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return len(p.managedZones()) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"static.com", "example.com", "example.org"}, p.currentDomainFilter().Filters)
+
+	// an empty domain filter must not unset the current one
+	assert.Error(t, p.SetDomainFilter([]string{}))
+	assert.Len(t, p.managedZones(), 3)
+}
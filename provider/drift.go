@@ -0,0 +1,208 @@
+package netcup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// DriftEvent describes a record that changed without the provider applying a change, e.g. because
+// it was edited in the CCP web interface. Events of kind "serial" and "ttl" describe a zone whose
+// serial advanced or whose TTL changed without an apply instead, which points to an edit even if no
+// record differs, e.g. of a record type the provider does not manage.
+type DriftEvent struct {
+	Time   time.Time     `json:"time"`
+	Zone   string        `json:"zone"`
+	Kind   string        `json:"kind"`
+	Old    *nc.DnsRecord `json:"old,omitempty"`
+	Record *nc.DnsRecord `json:"record,omitempty"`
+	// OldValue and Value are the serials or TTLs of the zone for events of kind "serial" and "ttl".
+	OldValue string `json:"oldValue,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// DriftDetector remembers the records of each zone between polls and reports the ones that changed
+// out-of-band. Changes applied by the provider are announced beforehand and not reported.
+type DriftDetector struct {
+	notifyURL string
+	client    *http.Client
+
+	mu    sync.Mutex
+	zones map[string]*zoneRecords
+}
+
+// zoneRecords is the last known state of a zone and the changes expected until the next poll.
+type zoneRecords struct {
+	serial  string
+	ttl     string
+	records map[string]nc.DnsRecord
+	removed map[string]bool
+	added   map[string]int
+	// applied is set if changes were applied since the last poll, which advance the serial
+	applied bool
+}
+
+// NewDriftDetector creates a drift detector. If notifyURL is not empty, detected drift is posted to it as JSON.
+func NewDriftDetector(notifyURL string) *DriftDetector {
+	return &DriftDetector{
+		notifyURL: notifyURL,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		zones:     map[string]*zoneRecords{},
+	}
+}
+
+// WithDriftDetector reports records changed outside of the provider using detector.
+func WithDriftDetector(detector *DriftDetector) Option {
+	return func(p *NetcupProvider) {
+		p.drift = detector
+	}
+}
+
+// recordFingerprint identifies the content of a record independent of its ID. The priority is left
+// out since the provider never sets it and the API reports it as "0" for such records.
+func recordFingerprint(rec nc.DnsRecord) string {
+	return fmt.Sprintf("%s|%s|%s", rec.Hostname, rec.Type, rec.Destination)
+}
+
+// expect announces a change about to be applied to zone so that it is not reported as drift.
+func (d *DriftDetector) expect(zone string, change *NetcupChange) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	z, ok := d.zones[zone]
+	if !ok {
+		// no baseline yet, the next poll establishes it
+		return
+	}
+	z.applied = true
+	for _, recs := range []*[]nc.DnsRecord{change.UpdateOld, change.Delete} {
+		for _, rec := range *recs {
+			if rec.Id != "" {
+				z.removed[rec.Id] = true
+			}
+		}
+	}
+	for _, recs := range []*[]nc.DnsRecord{change.Create, change.UpdateNew} {
+		for _, rec := range *recs {
+			if _, known := z.records[rec.Id]; known {
+				// modified in place
+				z.records[rec.Id] = rec
+				continue
+			}
+			z.added[recordFingerprint(rec)]++
+		}
+	}
+}
+
+// observe compares the current state of zone, read as cached, with the last known state and returns
+// the drift found. The first observation of a zone only establishes the baseline.
+func (d *DriftDetector) observe(zone string, cached CachedZone, logger *slog.Logger) []DriftEvent {
+	if d == nil {
+		return nil
+	}
+	now := time.Now()
+	current := make(map[string]nc.DnsRecord, len(cached.Records))
+	for _, rec := range cached.Records {
+		current[rec.Id] = rec
+	}
+
+	d.mu.Lock()
+	z, ok := d.zones[zone]
+	d.zones[zone] = &zoneRecords{serial: cached.Serial, ttl: cached.TTL, records: current, removed: map[string]bool{}, added: map[string]int{}}
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	var events []DriftEvent
+	if cached.Serial != z.serial && !z.applied {
+		events = append(events, DriftEvent{Time: now, Zone: zone, Kind: "serial", OldValue: z.serial, Value: cached.Serial})
+	}
+	if cached.TTL != z.ttl && !z.applied {
+		events = append(events, DriftEvent{Time: now, Zone: zone, Kind: "ttl", OldValue: z.ttl, Value: cached.TTL})
+	}
+	for id, old := range z.records {
+		rec, found := current[id]
+		switch {
+		case !found && !z.removed[id]:
+			events = append(events, DriftEvent{Time: now, Zone: zone, Kind: "removed", Old: &old})
+		case found && (recordFingerprint(rec) != recordFingerprint(old) || rec.Priority != old.Priority):
+			events = append(events, DriftEvent{Time: now, Zone: zone, Kind: "modified", Old: &old, Record: &rec})
+		}
+	}
+	for id, rec := range current {
+		if _, known := z.records[id]; known {
+			continue
+		}
+		if fp := recordFingerprint(rec); z.added[fp] > 0 {
+			z.added[fp]--
+			continue
+		}
+		events = append(events, DriftEvent{Time: now, Zone: zone, Kind: "added", Record: &rec})
+	}
+
+	for _, event := range events {
+		driftDetectedTotal.WithLabelValues(event.Zone, event.Kind).Inc()
+		if event.Value != "" {
+			logger.Warn("zone changed outside of external-dns", "zone", event.Zone, "kind", event.Kind, "old", event.OldValue, "new", event.Value)
+			continue
+		}
+		logger.Warn("record changed outside of external-dns", "zone", event.Zone, "kind", event.Kind, "old", describeRecord(event.Old), "record", describeRecord(event.Record))
+	}
+	if len(events) > 0 && d.notifyURL != "" {
+		go d.notify(events, logger)
+	}
+	return events
+}
+
+// forget drops the state of all zones not in zones, e.g. after the domain filter changed.
+func (d *DriftDetector) forget(zones []string) {
+	if d == nil {
+		return
+	}
+	keep := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		keep[zone] = true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for zone := range d.zones {
+		if !keep[zone] {
+			delete(d.zones, zone)
+		}
+	}
+}
+
+// notify posts events to the notification URL.
+func (d *DriftDetector) notify(events []DriftEvent, logger *slog.Logger) {
+	body, err := json.Marshal(events)
+	if err != nil {
+		logger.Error("unable to encode drift notification", "error", err.Error())
+		return
+	}
+	resp, err := d.client.Post(d.notifyURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Error("unable to send drift notification", "error", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger.Error("unable to send drift notification", "error", fmt.Sprintf("unexpected status code %d", resp.StatusCode))
+	}
+}
+
+// describeRecord formats rec for logging.
+func describeRecord(rec *nc.DnsRecord) string {
+	if rec == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s %s", rec.Hostname, rec.Type, rec.Destination)
+}
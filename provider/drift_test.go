@@ -0,0 +1,85 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDriftDetector(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "api", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "old", Type: "A", Destination: "3.3.3.3"},
+	)
+
+	notifications := make(chan []DriftEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []DriftEvent
+		_ = json.NewDecoder(r.Body).Decode(&events)
+		notifications <- events
+	}))
+	defer receiver.Close()
+
+	detector := NewDriftDetector(receiver.URL)
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithDriftDetector(detector))
+	assert.NoError(t, err)
+
+	// the first poll establishes the baseline
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// changes applied by the provider are not drift
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "4.4.4.4")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "3.3.3.3")},
+	})
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	// manual changes are, the IDs are assigned by the fake server in order
+	srv.UpdateRecords("example.com",
+		nc.DnsRecord{Id: "1", Hostname: "www", Type: "A", Destination: "9.9.9.9"},
+		nc.DnsRecord{Id: "2", DeleteRecord: true},
+		nc.DnsRecord{Hostname: "manual", Type: "CNAME", Destination: "www.example.com"},
+	)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	select {
+	case events := <-notifications:
+		kinds := map[string]string{}
+		for _, event := range events {
+			if event.Kind == "serial" {
+				// the serial advanced with the manual changes
+				assert.NotEqual(t, event.OldValue, event.Value)
+				assert.Equal(t, srv.ZoneInfo("example.com").Serial, event.Value)
+				kinds["@serial"] = event.Kind
+				continue
+			}
+			rec := event.Record
+			if rec == nil {
+				rec = event.Old
+			}
+			kinds[rec.Hostname] = event.Kind
+		}
+		assert.Equal(t, map[string]string{"www": "modified", "api": "removed", "manual": "added", "@serial": "serial"}, kinds)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no drift notification received")
+	}
+}
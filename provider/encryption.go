@@ -0,0 +1,144 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks data sealed by an Encrypter, so data written before encryption was enabled
+// stays readable and is encrypted when it is next written.
+const encryptedPrefix = "enc:v1:"
+
+// errNoEncryptionKey is returned when reading encrypted data without a key.
+var errNoEncryptionKey = errors.New("data is encrypted, but no encryption key is configured")
+
+// Encrypter encrypts the data written to disk, i.e. cached zones and the change journal, with
+// AES-256-GCM, as zone dumps and change logs reveal the layout of the infrastructure. A nil
+// Encrypter leaves data unencrypted.
+type Encrypter struct {
+	aead cipher.AEAD
+}
+
+// NewEncrypter returns an Encrypter for a 32 byte key.
+func NewEncrypter(key []byte) (*Encrypter, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must have 32 bytes for AES-256, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Encrypter{aead: aead}, nil
+}
+
+// LoadEncrypter returns an Encrypter with the key of uri, which is one of
+//
+//	file:///path/to/key
+//	aws-kms:///path/to/wrapped-key[?region=<region>]
+//	gcp-kms:///path/to/wrapped-key?key=projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+//
+// The file holds the key, or with KMS the key encrypted by the KMS key, raw or base64 encoded. The
+// AWS KMS key is identified by the encrypted key itself, e.g. the CiphertextBlob of
+// aws kms generate-data-key --key-spec AES_256.
+func LoadEncrypter(ctx context.Context, uri string) (*Encrypter, error) {
+	key, err := loadEncryptionKey(ctx, uri, newSecretClient(), "https://cloudkms.googleapis.com")
+	if err != nil {
+		return nil, err
+	}
+	return NewEncrypter(key)
+}
+
+// loadEncryptionKey reads the key of uri, decrypting it with the Cloud KMS at gcpEndpoint for gcp-kms.
+func loadEncryptionKey(ctx context.Context, uri string, client *http.Client, gcpEndpoint string) ([]byte, error) {
+	scheme, location, ok := strings.Cut(uri, "://")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid encryption key '%s', expected <scheme>://<path>", uri)
+	}
+	path, query, _ := strings.Cut(location, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key parameters '%s': %w", query, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key: %w", err)
+	}
+	content = bytes.TrimSpace(content)
+	if decoded, err := base64.StdEncoding.DecodeString(string(content)); err == nil {
+		content = decoded
+	}
+
+	switch scheme {
+	case "file":
+		return content, nil
+	case "aws-kms":
+		api, err := newAWSAPI(params, "", client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AWS KMS encryption key: %w", err)
+		}
+		return api.kmsDecrypt(ctx, content)
+	case "gcp-kms":
+		name := params.Get("key")
+		if parts := strings.Split(name, "/"); len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+			return nil, fmt.Errorf("invalid GCP Cloud KMS key '%s', expected projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>", name)
+		}
+		return newGCPAPI(client).kmsDecrypt(ctx, gcpEndpoint+"/v1/"+name, content)
+	default:
+		return nil, fmt.Errorf("unsupported encryption key '%s', expected file, aws-kms or gcp-kms", scheme)
+	}
+}
+
+// seal encrypts plaintext into a single line of text.
+func (e *Encrypter) seal(plaintext []byte) ([]byte, error) {
+	if e == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, e.aead.NonceSize(), e.aead.NonceSize()+len(plaintext)+e.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := e.aead.Seal(nonce, nonce, plaintext, nil)
+	out := make([]byte, len(encryptedPrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, encryptedPrefix)
+	base64.StdEncoding.Encode(out[len(encryptedPrefix):], sealed)
+	return out, nil
+}
+
+// open decrypts data sealed by seal. Unencrypted data is returned as is.
+func (e *Encrypter) open(data []byte) ([]byte, error) {
+	encoded, ok := bytes.CutPrefix(data, []byte(encryptedPrefix))
+	if !ok {
+		return data, nil
+	}
+	if e == nil {
+		return nil, errNoEncryptionKey
+	}
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(sealed, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %w", err)
+	}
+	sealed = sealed[:n]
+	if len(sealed) < e.aead.NonceSize() {
+		return nil, errors.New("invalid encrypted data: too short")
+	}
+	plaintext, err := e.aead.Open(nil, sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data, the encryption key may be wrong: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,157 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestEncrypter(t *testing.T) {
+	e, err := NewEncrypter(testEncryptionKey)
+	assert.NoError(t, err)
+	sealed, err := e.seal([]byte(`{"zone":"example.com"}`))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(sealed, []byte(encryptedPrefix)))
+	assert.NotContains(t, string(sealed), "example.com")
+	assert.NotContains(t, string(sealed), "\n")
+	plaintext, err := e.open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"zone":"example.com"}`, string(plaintext))
+
+	// data written before encryption was enabled stays readable
+	plaintext, err = e.open([]byte(`{"zone":"example.org"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"zone":"example.org"}`, string(plaintext))
+
+	other, err := NewEncrypter(bytes.Repeat([]byte{0x23}, 32))
+	assert.NoError(t, err)
+	_, err = other.open(sealed)
+	assert.ErrorContains(t, err, "encryption key may be wrong")
+	var none *Encrypter
+	_, err = none.open(sealed)
+	assert.ErrorIs(t, err, errNoEncryptionKey)
+
+	_, err = NewEncrypter([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	e, err := NewEncrypter(testEncryptionKey)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	plain, _ := json.Marshal(ChangeEntry{Time: time.Now(), Zone: "plain.example"})
+	assert.NoError(t, os.WriteFile(path, append(plain, '\n'), 0o600))
+	j, err := OpenChangeJournal(path, 0, e)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append(ChangeEntry{Time: time.Now(), Zone: "secret.example"}))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "secret.example")
+	entries, err := j.Entries()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "plain.example", entries[0].Zone)
+		assert.Equal(t, "secret.example", entries[1].Zone)
+	}
+	_, err = OpenChangeJournal(path, time.Hour, nil)
+	assert.ErrorIs(t, err, errNoEncryptionKey)
+
+	dir := t.TempDir()
+	cache, err := NewFileRecordCache(dir, e, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+	cache.Set("example.com", CachedZone{TTL: "300", Records: []nc.DnsRecord{{Id: "1", Hostname: "internal-db", Type: "A", Destination: "10.0.0.1"}}})
+	content, err = os.ReadFile(filepath.Join(dir, "example.com.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "internal-db")
+	cached, ok := cache.Get("example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", cached.Records[0].Destination)
+
+	// without the key the cache misses instead of failing
+	unencrypted, err := NewFileRecordCache(dir, nil, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+	_, ok = unencrypted.Get("example.com")
+	assert.False(t, ok)
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(testEncryptionKey)+"\n"), 0o600))
+	wrappedFile := filepath.Join(dir, "wrapped")
+	assert.NoError(t, os.WriteFile(wrappedFile, []byte("WRAPPED"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string][]byte
+		switch {
+		case r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token":
+			_, _ = w.Write([]byte(`{"access_token":"ACCESS-TOKEN"}`))
+		case r.Header.Get("X-Amz-Target") == "TrentService.Decrypt":
+			assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "WRAPPED", string(req["CiphertextBlob"]))
+			_ = json.NewEncoder(w).Encode(map[string][]byte{"Plaintext": testEncryptionKey})
+		case r.URL.Path == "/v1/projects/p/locations/global/keyRings/r/cryptoKeys/k:decrypt":
+			assert.Equal(t, "Bearer ACCESS-TOKEN", r.Header.Get("Authorization"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "WRAPPED", string(req["ciphertext"]))
+			_ = json.NewEncoder(w).Encode(map[string][]byte{"plaintext": testEncryptionKey})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_ENDPOINT_URL_KMS", server.URL)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	for _, uri := range []string{
+		"file://" + keyFile,
+		"aws-kms://" + wrappedFile + "?region=eu-central-1",
+		"gcp-kms://" + wrappedFile + "?key=projects/p/locations/global/keyRings/r/cryptoKeys/k",
+	} {
+		key, err := loadEncryptionKey(context.TODO(), uri, server.Client(), server.URL)
+		assert.NoError(t, err, uri)
+		assert.Equal(t, testEncryptionKey, key, uri)
+	}
+
+	// the decrypted key never passes http.DefaultTransport, which logs payloads with --log-api-payloads
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("request to %s sent with the default transport", r.URL)
+		return defaultTransport.RoundTrip(r)
+	})
+	e, err := LoadEncrypter(context.TODO(), "aws-kms://"+wrappedFile+"?region=eu-central-1")
+	http.DefaultTransport = defaultTransport
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+
+	_, err = loadEncryptionKey(context.TODO(), "gcp-kms://"+wrappedFile+"?key=k", server.Client(), server.URL)
+	assert.Error(t, err)
+	_, err = loadEncryptionKey(context.TODO(), "vault://"+keyFile, server.Client(), server.URL)
+	assert.Error(t, err)
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,122 @@
+package netcup
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// Kinds of errors returned by the provider. Use errors.Is to check the kind of an error.
+var (
+	// ErrAuth is returned if the credentials or the session were rejected.
+	ErrAuth = errors.New("authentication failed")
+	// ErrRateLimited is returned if the Netcup API throttled the request.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrZoneNotFound is returned if a zone does not exist in the Netcup account.
+	ErrZoneNotFound = errors.New("zone not found")
+	// ErrValidation is returned if the Netcup API rejected the request as invalid.
+	ErrValidation = errors.New("validation failed")
+	// ErrBackendUnavailable is returned if the Netcup API could not be reached or failed internally.
+	ErrBackendUnavailable = errors.New("backend unavailable")
+	// ErrChangeRateExceeded is returned if applying changes would exceed the maximum change rate.
+	ErrChangeRateExceeded = errors.New("change rate exceeded")
+	// ErrZoneLocked is returned if the lock of a zone could not be taken in time.
+	ErrZoneLocked = errors.New("zone locked")
+	// ErrMaintenance is returned if the Netcup API is in maintenance, or changes are refused during it.
+	// It is a kind of ErrBackendUnavailable.
+	ErrMaintenance = errors.New("backend in maintenance")
+)
+
+// Netcup API status codes, see https://ccp.netcup.net/run/webservice/servers/endpoint.php
+const (
+	statusCodeValidation  = 4013
+	statusCodeZoneUnknown = 5028
+	statusCodeNoRecords   = 5029
+)
+
+// APIError is an error of the Netcup API, or a refusal to call it, classified by its kind.
+// Rate limits and unavailable backends are soft errors that external-dns retries on its next run.
+type APIError struct {
+	Kind       error
+	StatusCode int
+	Err        error
+	// RetryAfter is the time to wait before calling the API again, if known.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *APIError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Is makes retryable kinds match provider.SoftError, and maintenances ErrBackendUnavailable.
+func (e *APIError) Is(target error) bool {
+	if target == ErrBackendUnavailable && e.Kind == ErrMaintenance {
+		return true
+	}
+	return target == provider.SoftError && (e.Kind == ErrRateLimited || e.Kind == ErrBackendUnavailable || e.Kind == ErrChangeRateExceeded || e.Kind == ErrZoneLocked || e.Kind == ErrMaintenance)
+}
+
+var (
+	apiStatusCodeRegexp = regexp.MustCompile(`failed: \((\d+)\)`)
+	// apiMessageRegexp matches the status code, short and long message of a failed API call.
+	apiMessageRegexp     = regexp.MustCompile(`failed: \((\d+)\) '[^']*' '([^']*)' '(.*)'$`)
+	httpStatusCodeRegexp = regexp.MustCompile(`^unexpected error code: (\d+)`)
+	// maintenanceRegexp matches the messages of the CCP during maintenance, in English or German.
+	maintenanceRegexp = regexp.MustCompile(`(?i)maintenance|wartung`)
+)
+
+// classifyError wraps err of a Netcup API call into an APIError. Errors of a login are
+// authentication errors unless the API could not be reached or is in maintenance.
+func classifyError(err error, login bool) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	if m := apiStatusCodeRegexp.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		kind := ErrBackendUnavailable
+		switch {
+		case maintenanceRegexp.MatchString(err.Error()):
+			kind = ErrMaintenance
+		case login:
+			kind = ErrAuth
+		case code == statusCodeValidation:
+			kind = ErrValidation
+		case code == statusCodeZoneUnknown:
+			kind = ErrZoneNotFound
+		case code >= 4000 && code < 5000:
+			kind = ErrValidation
+		}
+		return &APIError{Kind: kind, StatusCode: code, Err: err}
+	}
+	if m := httpStatusCodeRegexp.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		kind := ErrBackendUnavailable
+		switch {
+		case code == 429:
+			kind = ErrRateLimited
+		case code == 503 || maintenanceRegexp.MatchString(err.Error()):
+			kind = ErrMaintenance
+		case code == 401 || code == 403:
+			kind = ErrAuth
+		}
+		return &APIError{Kind: kind, StatusCode: code, Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &APIError{Kind: ErrBackendUnavailable, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package netcup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert.NoError(t, classifyError(nil, false))
+	assert.ErrorIs(t, classifyError(errors.New("Login failed: (4001) 'error' 'Login failed' ''"), true), ErrAuth)
+	assert.ErrorIs(t, classifyError(errors.New("UpdateDnsRecords failed: (4013) 'error' 'Validation Error.' ''"), false), ErrValidation)
+	assert.ErrorIs(t, classifyError(errors.New("InfoDnsZone failed: (5028) 'error' 'zone not found' ''"), false), ErrZoneNotFound)
+	assert.ErrorIs(t, classifyError(errors.New("unexpected error code: 429"), false), ErrRateLimited)
+	assert.ErrorIs(t, classifyError(errors.New("unexpected error code: 503, response: maintenance"), false), ErrBackendUnavailable)
+	assert.ErrorIs(t, classifyError(errors.New("unexpected error code: 503, response: maintenance"), false), ErrMaintenance)
+	assert.ErrorIs(t, classifyError(errors.New("Login failed: (4001) 'error' 'Wartungsarbeiten' ''"), true), ErrMaintenance)
+
+	unclassified := errors.New("something else")
+	assert.Equal(t, unclassified, classifyError(unclassified, false))
+
+	assert.ErrorIs(t, classifyError(errors.New("unexpected error code: 429"), false), provider.SoftError)
+	assert.NotErrorIs(t, classifyError(errors.New("Login failed: (4001) 'error' 'Login failed' ''"), true), provider.SoftError)
+}
+
+func TestRecordsErrorKinds(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// missing zones are skipped and reported as failing
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Contains(t, p.FailingZones()["example.org"], ErrZoneNotFound.Error())
+
+	srv.FailAction("login", 4001)
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrAuth)
+
+	srv.Close()
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrBackendUnavailable)
+	assert.ErrorIs(t, err, provider.SoftError)
+}
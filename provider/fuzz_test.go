@@ -0,0 +1,112 @@
+package netcup
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// quoteTXT renders text as quoted character strings of at most 255 bytes, as DNS servers return
+// long TXT records.
+func quoteTXT(text string) string {
+	var segments []string
+	for {
+		n := min(len(text), 255)
+		segment := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text[:n])
+		segments = append(segments, `"`+segment+`"`)
+		text = text[n:]
+		if text == "" {
+			return strings.Join(segments, " ")
+		}
+	}
+}
+
+func FuzzUnquoteTXT(f *testing.F) {
+	for _, seed := range []string{
+		"hello world",
+		`"v=spf1 " "-all"`,
+		`"say \"hi\" \\ bye"`,
+		`"unterminated`,
+		`"trailing escape\`,
+		`"heritage=external-dns,external-dns/owner=default"`,
+		"🦄 unicorn \U0001F600",
+		strings.Repeat("x", 4096),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		text := unquoteTXT(value)
+		if !strings.HasPrefix(strings.TrimSpace(value), `"`) && text != value {
+			t.Errorf("unquoted value %q changed to %q", value, text)
+		}
+		if len(text) > len(value) {
+			t.Errorf("unquoting %q grew it to %q", value, text)
+		}
+		if got := unquoteTXT(quoteTXT(value)); got != value {
+			t.Errorf("quoted %q unquoted to %q", value, got)
+		}
+	})
+}
+
+func FuzzConvertToNetcupRecord(f *testing.F) {
+	for _, seed := range []struct {
+		hostname   string
+		recordType uint8
+		target     string
+		heritage   bool
+	}{
+		{"www", 0, "1.1.1.1", false},
+		{"@", 3, "target.example.com.", false},
+		{"🦄", 4, "v=spf1 -all", false},
+		{"_dmarc", 4, `"v=DMARC1; p=none"`, true},
+		{"txt", 4, `"say \"hi\""`, false},
+		{"txt", 4, `"heritage=external-dns,external-dns/owner=default"`, true},
+		{"long", 4, strings.Repeat("0123456789abcdef", 256), false},
+		{"xn--ls8h", 5, "10 mail.example.com", false},
+	} {
+		f.Add(seed.hostname, seed.recordType, seed.target, seed.heritage)
+	}
+	types := append(slices.Clone(supportedRecordTypes), "HTTPS")
+	f.Fuzz(func(t *testing.T, hostname string, recordType uint8, target string, heritage bool) {
+		const zone = "example.com"
+		quoting := TXTQuotingAll
+		if heritage {
+			quoting = TXTQuotingHeritage
+		}
+		name := zone
+		if hostname != "@" {
+			name = hostname + "." + zone
+		}
+		ep := endpoint.NewEndpoint(name, types[int(recordType)%len(types)], target)
+		if ep == nil {
+			t.Skip("invalid DNS name")
+		}
+		if ep.RecordType != endpoint.RecordTypeTXT && strings.HasSuffix(ep.Targets[0], "..") {
+			// only one trailing dot is insignificant, and an empty label is invalid anyway
+			t.Skip("invalid target")
+		}
+
+		written := *convertToNetcupRecord(newRecordIDs(nil, quoting), []*endpoint.Endpoint{ep}, zone, false, quoting)
+		if len(written) != 1 {
+			t.Fatalf("converted %v to %d records", ep, len(written))
+		}
+		rec := written[0]
+		rec.Id = "1"
+		existing := []nc.DnsRecord{rec}
+
+		// the endpoint finds the record written for it, so it can be updated and deleted
+		if id := newRecordIDs(existing, quoting).id(rec.Hostname, ep.Targets[0], ep.RecordType); id != "1" {
+			t.Errorf("record %+v written for %v not found", rec, ep)
+		}
+
+		// reading the record and writing it back does not change it
+		read := recordsToEndpoints(zone, 300, existing, quoting)
+		rewritten := *convertToNetcupRecord(newRecordIDs(existing, quoting), read, zone, false, quoting)
+		if len(rewritten) != 1 || rewritten[0].Id != "1" || rewritten[0].Hostname != rec.Hostname || !quoting.sameDestination(rec.Type, rewritten[0].Destination, rec.Destination) {
+			t.Errorf("record %+v read as %v written back as %+v", rec, read[0], rewritten)
+		}
+	})
+}
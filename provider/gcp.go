@@ -0,0 +1,207 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gcpCloudPlatformScope is the OAuth scope of the Google Cloud APIs.
+const gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpAPI calls Google Cloud APIs as the workload. It signs in with the workload identity federation
+// configuration in GOOGLE_APPLICATION_CREDENTIALS if set, or else with the service account of the
+// metadata server, which GKE Workload Identity provides to pods.
+type gcpAPI struct {
+	metadataHost string
+	client       *http.Client
+}
+
+// newGCPAPI returns the API using the metadata server of GCE_METADATA_HOST, if set.
+func newGCPAPI(client *http.Client) gcpAPI {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	return gcpAPI{metadataHost: host, client: client}
+}
+
+// call sends a request with in as JSON body, or without body if in is nil, to url and decodes the
+// response into out.
+func (g gcpAPI) call(ctx context.Context, method, url string, in, out any) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get GCP access token: %w", err)
+	}
+	var body io.Reader
+	if in != nil {
+		content, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doJSON(g.client, req, out)
+}
+
+// kmsDecrypt decrypts ciphertext with the Cloud KMS key of endpoint, e.g.
+// https://cloudkms.googleapis.com/v1/projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>.
+func (g gcpAPI) kmsDecrypt(ctx context.Context, endpoint string, ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := g.call(ctx, http.MethodPost, endpoint+":decrypt", map[string][]byte{"ciphertext": ciphertext}, &resp); err != nil {
+		return nil, fmt.Errorf("unable to decrypt with GCP Cloud KMS: %w", err)
+	}
+	return resp.Plaintext, nil
+}
+
+// gcpSecretManagerSource reads the credentials from a secret version of Google Cloud Secret Manager.
+type gcpSecretManagerSource struct {
+	gcpAPI
+	name     string
+	endpoint string
+}
+
+// newGCPSecretManagerSource returns the source for the secret in location, which defaults to its
+// latest version.
+func newGCPSecretManagerSource(location string, client *http.Client) (*gcpSecretManagerSource, error) {
+	parts := strings.Split(location, "/")
+	if (len(parts) != 4 && len(parts) != 6) || parts[0] != "projects" || parts[2] != "secrets" || (len(parts) == 6 && parts[4] != "versions") {
+		return nil, fmt.Errorf("invalid GCP Secret Manager secret '%s', expected projects/<project>/secrets/<secret>[/versions/<version>]", location)
+	}
+	if len(parts) == 4 {
+		location += "/versions/latest"
+	}
+	return &gcpSecretManagerSource{gcpAPI: newGCPAPI(client), name: location, endpoint: "https://secretmanager.googleapis.com"}, nil
+}
+
+// Credentials implements CredentialsSource.
+func (s *gcpSecretManagerSource) Credentials(ctx context.Context) (Credentials, error) {
+	var version struct {
+		Payload struct {
+			Data       []byte `json:"data"`
+			DataCrc32c string `json:"dataCrc32c"`
+		} `json:"payload"`
+	}
+	if err := s.call(ctx, http.MethodGet, s.endpoint+"/v1/"+s.name+":access", nil, &version); err != nil {
+		return Credentials{}, fmt.Errorf("unable to access GCP Secret Manager secret '%s': %w", s.name, err)
+	}
+	content := version.Payload.Data
+	if version.Payload.DataCrc32c != "" {
+		checksum := strconv.FormatUint(uint64(crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))), 10)
+		if checksum != version.Payload.DataCrc32c {
+			return Credentials{}, fmt.Errorf("corrupted payload of GCP Secret Manager secret '%s': checksum %s, expected %s", s.name, checksum, version.Payload.DataCrc32c)
+		}
+	}
+	return parseCredentials(content)
+}
+
+// gcpExternalAccount is the subset of a workload identity federation configuration file used to
+// exchange a token of the workload, e.g. of a Kubernetes service account, for a Google access token.
+type gcpExternalAccount struct {
+	Type             string `json:"type"`
+	Audience         string `json:"audience"`
+	SubjectTokenType string `json:"subject_token_type"`
+	TokenURL         string `json:"token_url"`
+	CredentialSource struct {
+		File string `json:"file"`
+	} `json:"credential_source"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+}
+
+// accessToken returns an access token of the workload.
+func (g gcpAPI) accessToken(ctx context.Context) (string, error) {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		var account gcpExternalAccount
+		if err := json.Unmarshal(content, &account); err != nil {
+			return "", fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		if account.Type != "external_account" || account.CredentialSource.File == "" {
+			return "", fmt.Errorf("%s is not a workload identity federation configuration with a credential source file", path)
+		}
+		return g.exchangeToken(ctx, account)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+g.metadataHost+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(g.client, req, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// exchangeToken exchanges the workload token of account at the Security Token Service and, if
+// configured, for an access token of the impersonated service account.
+func (g gcpAPI) exchangeToken(ctx context.Context, account gcpExternalAccount) (string, error) {
+	subjectToken, err := os.ReadFile(account.CredentialSource.File)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"audience":             {account.Audience},
+		"scope":                {gcpCloudPlatformScope},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token_type":   {account.SubjectTokenType},
+		"subject_token":        {strings.TrimSpace(string(subjectToken))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var federated struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(g.client, req, &federated); err != nil {
+		return "", err
+	}
+	if account.ServiceAccountImpersonationURL == "" {
+		return federated.AccessToken, nil
+	}
+
+	body, err := json.Marshal(map[string][]string{"scope": {gcpCloudPlatformScope}})
+	if err != nil {
+		return "", err
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, account.ServiceAccountImpersonationURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+federated.AccessToken)
+	var impersonated struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := doJSON(g.client, req, &impersonated); err != nil {
+		return "", err
+	}
+	return impersonated.AccessToken, nil
+}
@@ -0,0 +1,82 @@
+package netcup
+
+import (
+	"slices"
+	"time"
+)
+
+// APIHealth describes the state of the connection to the Netcup API.
+type APIHealth struct {
+	// LastSuccess is the time of the last successful call, zero if there was none yet.
+	LastSuccess time.Time
+	// LastError is the error of the last failed call and LastErrorTime its time.
+	LastError     string
+	LastErrorTime time.Time
+	// RateLimitedUntil is the end of the cooldown after being rate limited, zero if not rate limited.
+	RateLimitedUntil time.Time
+}
+
+// recordAPISuccess remembers a successful series of API calls.
+func (p *NetcupProvider) recordAPISuccess() {
+	p.apiHealthMu.Lock()
+	defer p.apiHealthMu.Unlock()
+	p.apiHealth.LastSuccess = time.Now()
+}
+
+// recordAPIError remembers the error of a failed API call.
+func (p *NetcupProvider) recordAPIError(err error) {
+	p.apiHealthMu.Lock()
+	defer p.apiHealthMu.Unlock()
+	p.apiHealth.LastError = err.Error()
+	p.apiHealth.LastErrorTime = time.Now()
+}
+
+// APIHealth returns the state of the connection to the Netcup API.
+func (p *NetcupProvider) APIHealth() APIHealth {
+	p.apiHealthMu.Lock()
+	health := p.apiHealth
+	p.apiHealthMu.Unlock()
+
+	p.rateLimitMu.Lock()
+	if time.Now().Before(p.rateLimitedUntil) {
+		health.RateLimitedUntil = p.rateLimitedUntil
+	}
+	p.rateLimitMu.Unlock()
+	return health
+}
+
+// recordApplyResult remembers whether the last apply of changes to zone failed.
+func (p *NetcupProvider) recordApplyResult(zone string, err error) {
+	p.applyErrorsMu.Lock()
+	defer p.applyErrorsMu.Unlock()
+	if err == nil {
+		delete(p.applyErrors, zone)
+		zoneApplyErrors.WithLabelValues(zone).Set(0)
+		return
+	}
+	if p.applyErrors == nil {
+		p.applyErrors = map[string]string{}
+	}
+	p.applyErrors[zone] = err.Error()
+	zoneApplyErrors.WithLabelValues(zone).Set(1)
+}
+
+// FailingZones returns the managed zones whose last apply of changes failed or that are missing from
+// the Netcup account, with the error.
+func (p *NetcupProvider) FailingZones() map[string]string {
+	zones := p.managedZones()
+	failing := map[string]string{}
+	for zone, err := range p.missingZoneErrors() {
+		if slices.Contains(zones, zone) {
+			failing[zone] = err
+		}
+	}
+	p.applyErrorsMu.Lock()
+	defer p.applyErrorsMu.Unlock()
+	for zone, err := range p.applyErrors {
+		if slices.Contains(zones, zone) {
+			failing[zone] = err
+		}
+	}
+	return failing
+}
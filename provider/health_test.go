@@ -0,0 +1,62 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestFailingZones(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+	zoneApplyErrors.Reset()
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")}}
+
+	srv.FailAction("updateDnsRecords", 5000)
+	assert.Error(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Contains(t, p.FailingZones(), "example.com")
+	assert.Equal(t, float64(1), testutil.ToFloat64(zoneApplyErrors.WithLabelValues("example.com")))
+
+	srv.FailAction("updateDnsRecords", 0)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Empty(t, p.FailingZones())
+	assert.Equal(t, float64(0), testutil.ToFloat64(zoneApplyErrors.WithLabelValues("example.com")))
+
+	forgetZoneMetrics([]string{"example.com"}, nil)
+	assert.Equal(t, 0, testutil.CollectAndCount(zoneApplyErrors))
+}
+
+func TestAPIHealth(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, APIHealth{}, p.APIHealth())
+
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	health := p.APIHealth()
+	assert.False(t, health.LastSuccess.IsZero())
+	assert.Empty(t, health.LastError)
+
+	srv.FailAction("infoDnsRecords", 5000)
+	_, err = p.Records(context.TODO())
+	assert.Error(t, err)
+	health = p.APIHealth()
+	assert.NotEmpty(t, health.LastError)
+	assert.True(t, health.LastErrorTime.After(health.LastSuccess))
+}
@@ -0,0 +1,127 @@
+package netcup
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// ChangeEntry describes a change batch applied to a single zone.
+type ChangeEntry struct {
+	Time            time.Time      `json:"time"`
+	Zone            string         `json:"zone"`
+	Create          []nc.DnsRecord `json:"create,omitempty"`
+	UpdateOld       []nc.DnsRecord `json:"updateOld,omitempty"`
+	UpdateNew       []nc.DnsRecord `json:"updateNew,omitempty"`
+	Delete          []nc.DnsRecord `json:"delete,omitempty"`
+	Outcome         string         `json:"outcome"`
+	Error           string         `json:"error,omitempty"`
+	DurationSeconds float64        `json:"durationSeconds"`
+}
+
+// ChangeHistory is a ring buffer of the most recently applied change batches.
+type ChangeHistory struct {
+	mu      sync.Mutex
+	entries []ChangeEntry
+	next    int
+	full    bool
+}
+
+// NewChangeHistory creates a change history keeping the last size entries.
+func NewChangeHistory(size int) *ChangeHistory {
+	return &ChangeHistory{
+		entries: make([]ChangeEntry, size),
+	}
+}
+
+// WithChangeHistory records every applied change batch in history.
+func WithChangeHistory(history *ChangeHistory) Option {
+	return func(p *NetcupProvider) {
+		p.history = history
+	}
+}
+
+// Add appends an entry, overwriting the oldest one if the buffer is full.
+func (h *ChangeHistory) Add(entry ChangeEntry) {
+	if h == nil || len(h.entries) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Entries returns the recorded entries, oldest first.
+func (h *ChangeHistory) Entries() []ChangeEntry {
+	if h == nil {
+		return []ChangeEntry{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]ChangeEntry{}, h.entries[:h.next]...)
+	}
+	return append(append([]ChangeEntry{}, h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+// ServeHTTP returns the recorded entries as JSON.
+func (h *ChangeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Entries())
+}
+
+// newChangeEntry describes the outcome of applying change to zone.
+func newChangeEntry(zone string, change *NetcupChange, start time.Time, err error) ChangeEntry {
+	entry := ChangeEntry{
+		Time:            start,
+		Zone:            zone,
+		Outcome:         "success",
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if change != nil {
+		entry.Create = *change.Create
+		entry.UpdateOld = *change.UpdateOld
+		entry.UpdateNew = *change.UpdateNew
+		entry.Delete = *change.Delete
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// recordChange adds entry to the change history and journal, if configured.
+func (p *NetcupProvider) recordChange(entry ChangeEntry) {
+	p.history.Add(entry)
+	if err := p.journal.Append(entry); err != nil {
+		p.logger.Error("unable to write change journal", "zone", entry.Zone, "error", err.Error())
+	}
+}
+
+// logZoneSummary logs the outcome of applying changes to a zone, the counts of the changed
+// records, the API calls it took and whether the changes were verified.
+func (p *NetcupProvider) logZoneSummary(entry ChangeEntry, calls int, verification string) {
+	attrs := []any{
+		"zone", entry.Zone,
+		"outcome", entry.Outcome,
+		"created", len(entry.Create),
+		"updated", len(entry.UpdateNew),
+		"deleted", len(entry.Delete),
+		"duration", time.Duration(entry.DurationSeconds * float64(time.Second)).String(),
+		"api_calls", calls,
+		"verification", verification,
+	}
+	if entry.Error != "" {
+		p.logger.Warn("failed to apply changes to zone", append(attrs, "error", entry.Error)...)
+		return
+	}
+	p.logger.Info("applied changes to zone", attrs...)
+}
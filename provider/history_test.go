@@ -0,0 +1,111 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestChangeHistory(t *testing.T) {
+	t.Run("RingBuffer", testChangeHistoryRingBuffer)
+	t.Run("ApplyChanges", testChangeHistoryApplyChanges)
+	t.Run("SummaryLog", testZoneSummaryLog)
+}
+
+func testChangeHistoryRingBuffer(t *testing.T) {
+	h := NewChangeHistory(2)
+	assert.Empty(t, h.Entries())
+
+	h.Add(ChangeEntry{Zone: "a"})
+	h.Add(ChangeEntry{Zone: "b"})
+	h.Add(ChangeEntry{Zone: "c"})
+	entries := h.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "b", entries[0].Zone)
+	assert.Equal(t, "c", entries[1].Zone)
+
+	var nilHistory *ChangeHistory
+	nilHistory.Add(ChangeEntry{Zone: "a"})
+	assert.Empty(t, nilHistory.Entries())
+}
+
+func testChangeHistoryApplyChanges(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+	srv.AddZone("example.org", "300")
+
+	h := NewChangeHistory(10)
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithChangeHistory(h))
+	assert.NoError(t, err)
+
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.2.3.4")},
+	})
+	assert.NoError(t, err)
+
+	// zones without changes are not recorded
+	entries := h.Entries()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "example.com", entries[0].Zone)
+	assert.Equal(t, "success", entries[0].Outcome)
+	assert.Len(t, entries[0].Create, 1)
+	assert.Equal(t, "www", entries[0].Create[0].Hostname)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/changes", nil))
+	var served []ChangeEntry
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.Len(t, served, 1)
+}
+
+func testZoneSummaryLog(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	var logs bytes.Buffer
+	domainFilter := []string{"example.com"}
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithCanaryZone("example.com"))
+	assert.NoError(t, err)
+
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.2.3.4"),
+			endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "1.2.3.5"),
+		},
+	})
+	assert.NoError(t, err)
+
+	var summary map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(line, &record))
+		if record["msg"] == "applied changes to zone" {
+			summary = record
+		}
+	}
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, "INFO", summary["level"])
+		assert.Equal(t, "example.com", summary["zone"])
+		assert.Equal(t, "success", summary["outcome"])
+		assert.Equal(t, float64(2), summary["created"])
+		assert.Equal(t, float64(0), summary["updated"])
+		assert.Equal(t, float64(0), summary["deleted"])
+		// reading the zone, creating the records and verifying them
+		assert.Equal(t, float64(3), summary["api_calls"])
+		assert.Equal(t, "passed", summary["verification"])
+	}
+}
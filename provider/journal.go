@@ -0,0 +1,172 @@
+package netcup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeJournal persists applied change batches to an append-only JSON lines file.
+type ChangeJournal struct {
+	mu          sync.Mutex
+	path        string
+	retention   time.Duration
+	encrypter   *Encrypter
+	lastCompact time.Time
+}
+
+// OpenChangeJournal opens the journal at path, creating it if necessary. Entries older than
+// retention are removed when the journal is opened and once per hour afterwards; a retention
+// of 0 keeps all entries. Entries are encrypted with encrypter unless it is nil.
+func OpenChangeJournal(path string, retention time.Duration, encrypter *Encrypter) (*ChangeJournal, error) {
+	j := &ChangeJournal{
+		path:      path,
+		retention: retention,
+		encrypter: encrypter,
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// WithChangeJournal persists every applied change batch to journal.
+func WithChangeJournal(journal *ChangeJournal) Option {
+	return func(p *NetcupProvider) {
+		p.journal = journal
+	}
+}
+
+// Append writes entry to the journal.
+func (j *ChangeJournal) Append(entry ChangeEntry) error {
+	if j == nil {
+		return nil
+	}
+	line, err := j.marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.retention > 0 && time.Since(j.lastCompact) > time.Hour {
+		if err := j.compactLocked(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns all entries of the journal, oldest first.
+func (j *ChangeJournal) Entries() ([]ChangeEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.readLocked()
+}
+
+func (j *ChangeJournal) compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.compactLocked()
+}
+
+// compactLocked rewrites the journal without the entries that exceed the retention.
+func (j *ChangeJournal) compactLocked() error {
+	j.lastCompact = time.Now()
+	if j.retention <= 0 {
+		return nil
+	}
+	entries, err := j.readLocked()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-j.retention)
+	var buf bytes.Buffer
+	kept := 0
+	for _, entry := range entries {
+		if entry.Time.Before(cutoff) {
+			continue
+		}
+		line, err := j.marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+		kept++
+	}
+	if kept == len(entries) {
+		return nil
+	}
+
+	// Write to a temporary file first so a crash cannot truncate the journal
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+func (j *ChangeJournal) readLocked() ([]ChangeEntry, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []ChangeEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		content, err := j.encrypter.open(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read change journal '%s' line %d: %w", j.path, line, err)
+		}
+		var entry ChangeEntry
+		if err := json.Unmarshal(content, &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse change journal '%s' line %d: %v", j.path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// marshal returns the line of entry, encrypted if the journal is.
+func (j *ChangeJournal) marshal(entry ChangeEntry) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return j.encrypter.seal(line)
+}
@@ -0,0 +1,61 @@
+package netcup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeJournal(t *testing.T) {
+	t.Run("Append", testChangeJournalAppend)
+	t.Run("Retention", testChangeJournalRetention)
+}
+
+func testChangeJournalAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := OpenChangeJournal(path, 0, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, j.Append(ChangeEntry{Time: time.Now(), Zone: "example.com", Outcome: "success"}))
+	assert.NoError(t, j.Append(ChangeEntry{Time: time.Now(), Zone: "example.org", Outcome: "error", Error: "failed"}))
+
+	// reopening keeps the entries
+	j, err = OpenChangeJournal(path, 0, nil)
+	assert.NoError(t, err)
+	entries, err := j.Entries()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "example.com", entries[0].Zone)
+	assert.Equal(t, "failed", entries[1].Error)
+
+	var nilJournal *ChangeJournal
+	assert.NoError(t, nilJournal.Append(ChangeEntry{}))
+}
+
+func testChangeJournalRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	var content []byte
+	for _, entry := range []ChangeEntry{
+		{Time: time.Now().Add(-48 * time.Hour), Zone: "old.example"},
+		{Time: time.Now(), Zone: "new.example"},
+	} {
+		line, _ := json.Marshal(entry)
+		content = append(append(content, line...), '\n')
+	}
+	assert.NoError(t, os.WriteFile(path, content, 0o600))
+
+	j, err := OpenChangeJournal(path, 24*time.Hour, nil)
+	assert.NoError(t, err)
+	entries, err := j.Entries()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "new.example", entries[0].Zone)
+
+	assert.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o600))
+	_, err = OpenChangeJournal(path, 24*time.Hour, nil)
+	assert.Error(t, err)
+}
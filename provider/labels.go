@@ -0,0 +1,59 @@
+package netcup
+
+import (
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// preservedLabels are the labels of the TXT registry that are reattached to the endpoints they describe.
+var preservedLabels = []string{endpoint.OwnerLabelKey, endpoint.ResourceLabelKey}
+
+// attachLabels reattaches the labels recorded in heritage TXT records to the endpoints of a zone.
+// A heritage record describes the endpoint of its name in the old registry format, or of the
+// name without the lowercase record type prefix, e.g. "cname-www.example.com", in the new one.
+// Labels already set on an endpoint are kept.
+func attachLabels(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	type labelKey struct {
+		recordType, dnsName string
+	}
+	byKey := map[labelKey]endpoint.Labels{}
+	byName := map[string]endpoint.Labels{}
+	for _, ep := range endpoints {
+		if ep.RecordType != endpoint.RecordTypeTXT || len(ep.Targets) == 0 {
+			continue
+		}
+		labels, err := endpoint.NewLabelsFromStringPlain(unquoteTXT(ep.Targets[0]))
+		if err != nil {
+			continue
+		}
+		byName[ep.DNSName] = labels
+		if prefix, name, ok := strings.Cut(ep.DNSName, "-"); ok {
+			byKey[labelKey{strings.ToUpper(prefix), name}] = labels
+		}
+	}
+	if len(byName) == 0 {
+		return endpoints
+	}
+
+	for _, ep := range endpoints {
+		if ep.RecordType == endpoint.RecordTypeTXT {
+			continue
+		}
+		labels, ok := byKey[labelKey{ep.RecordType, ep.DNSName}]
+		if !ok {
+			if labels, ok = byName[ep.DNSName]; !ok {
+				continue
+			}
+		}
+		if ep.Labels == nil {
+			ep.Labels = endpoint.NewLabels()
+		}
+		for _, key := range preservedLabels {
+			if value := labels[key]; value != "" && ep.Labels[key] == "" {
+				ep.Labels[key] = value
+			}
+		}
+	}
+	return endpoints
+}
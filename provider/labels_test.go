@@ -0,0 +1,42 @@
+package netcup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestAttachLabels(t *testing.T) {
+	heritage := func(name, owner, resource string) *endpoint.Endpoint {
+		return endpoint.NewEndpoint(name, endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner="+owner+",external-dns/resource="+resource+"\"")
+	}
+	withLabels := func(ep *endpoint.Endpoint, owner, resource string) *endpoint.Endpoint {
+		ep.Labels[endpoint.OwnerLabelKey] = owner
+		ep.Labels[endpoint.ResourceLabelKey] = resource
+		return ep
+	}
+
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeAAAA, "::1"),
+		endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeCNAME, "www.example.com"),
+		endpoint.NewEndpoint("manual.example.com", endpoint.RecordTypeA, "2.2.2.2"),
+		withLabels(endpoint.NewEndpoint("set.example.com", endpoint.RecordTypeA, "3.3.3.3"), "other", "service/default/set"),
+		heritage("a-www.example.com", "default", "ingress/default/www"),
+		heritage("old.example.com", "default", "service/default/old"),
+		heritage("a-set.example.com", "default", "service/default/changed"),
+		endpoint.NewEndpoint("txt.example.com", endpoint.RecordTypeTXT, "hello"),
+	}
+	attachLabels(endpoints)
+
+	assert.Equal(t, "default", endpoints[0].Labels[endpoint.OwnerLabelKey])
+	assert.Equal(t, "ingress/default/www", endpoints[0].Labels[endpoint.ResourceLabelKey])
+	assert.Empty(t, endpoints[1].Labels, "AAAA record has no heritage record")
+	assert.Equal(t, "default", endpoints[2].Labels[endpoint.OwnerLabelKey])
+	assert.Equal(t, "service/default/old", endpoints[2].Labels[endpoint.ResourceLabelKey])
+	assert.Empty(t, endpoints[3].Labels)
+	assert.Equal(t, "other", endpoints[4].Labels[endpoint.OwnerLabelKey])
+	assert.Equal(t, "service/default/set", endpoints[4].Labels[endpoint.ResourceLabelKey])
+	assert.Empty(t, endpoints[5].Labels, "heritage records are not labelled")
+}
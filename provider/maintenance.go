@@ -0,0 +1,54 @@
+package netcup
+
+import (
+	"errors"
+	"time"
+)
+
+// maintenanceWarningInterval is the interval in which a maintenance of the Netcup API is logged
+// again while it lasts.
+const maintenanceWarningInterval = 10 * time.Minute
+
+// errChangesDuringMaintenance is returned for changes while the Netcup API is in maintenance.
+var errChangesDuringMaintenance = &APIError{Kind: ErrMaintenance, Err: errors.New("refusing changes while the Netcup API is in maintenance")}
+
+// maintenance is the state of a maintenance of the Netcup API.
+type maintenance struct {
+	since       time.Time
+	lastWarning time.Time
+}
+
+// enterMaintenance remembers that the Netcup API is in maintenance. The first error is logged as
+// a warning, further ones at most once per maintenanceWarningInterval.
+func (p *NetcupProvider) enterMaintenance(err error) {
+	p.maintenanceMu.Lock()
+	defer p.maintenanceMu.Unlock()
+	now := time.Now()
+	if p.maintenance == nil {
+		p.maintenance = &maintenance{since: now}
+	} else if now.Sub(p.maintenance.lastWarning) < maintenanceWarningInterval {
+		p.logger.Debug("Netcup API still in maintenance", "error", err.Error())
+		return
+	}
+	p.maintenance.lastWarning = now
+	p.logger.Warn("Netcup API in maintenance, serving cached records and refusing changes", "since", p.maintenance.since, "error", err.Error())
+	backendMaintenance.Set(1)
+}
+
+// leaveMaintenance ends a maintenance of the Netcup API after a successful call.
+func (p *NetcupProvider) leaveMaintenance() {
+	p.maintenanceMu.Lock()
+	defer p.maintenanceMu.Unlock()
+	if p.maintenance != nil {
+		p.logger.Info("Netcup API maintenance ended", "duration", time.Since(p.maintenance.since).String())
+		p.maintenance = nil
+	}
+	backendMaintenance.Set(0)
+}
+
+// inMaintenance reports whether the Netcup API is in maintenance.
+func (p *NetcupProvider) inMaintenance() bool {
+	p.maintenanceMu.Lock()
+	defer p.maintenanceMu.Unlock()
+	return p.maintenance != nil
+}
@@ -0,0 +1,49 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+func TestMaintenance(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	// the last records read are served during a maintenance
+	srv.FailActionWithMessage("infoDnsZone", 4001, "Das CCP befindet sich in Wartung")
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(backendMaintenance))
+
+	// and changes are refused until it ended
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")}}
+	err = p.ApplyChanges(context.TODO(), changes)
+	assert.ErrorIs(t, err, ErrMaintenance)
+	assert.ErrorIs(t, err, provider.SoftError)
+	assert.Len(t, srv.Records("example.com"), 1)
+
+	srv.FailActionWithMessage("infoDnsZone", 0, "")
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(backendMaintenance))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, srv.Records("example.com"), 2)
+}
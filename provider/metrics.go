@@ -0,0 +1,169 @@
+package netcup
+
+import (
+	"slices"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	managedZones = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "managed_zones",
+		Help: "Number of zones currently managed by the provider.",
+	})
+	zonesDiscoveredTotal = metrics.NewCounter(prometheus.CounterOpts{
+		Name: "zones_discovered_total",
+		Help: "Total number of zones added to management by the zone refresher.",
+	})
+	zoneShardAssignment = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_shard",
+		Help: "Index of the shard each zone of the domain filter is assigned to.",
+	}, []string{"zone"})
+	shardIndex = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "shard_index",
+		Help: "Index of the shard served by this instance.",
+	})
+	shardCount = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "shards",
+		Help: "Total number of shards the zones are distributed across.",
+	})
+	pendingApprovals = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "pending_approvals",
+		Help: "Number of change sets awaiting approval.",
+	})
+	expiredApprovalsTotal = metrics.NewCounter(prometheus.CounterOpts{
+		Name: "expired_approvals_total",
+		Help: "Total number of change sets that expired before being approved.",
+	})
+	policyDeniedChangesTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "policy_denied_changes_total",
+		Help: "Total number of changes skipped because the policy denied them.",
+	}, []string{"action", "record_type"})
+	protectedDeletesTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "protected_deletes_total",
+		Help: "Total number of deletes and removed targets of updates skipped because deletes are protected.",
+	}, []string{"action", "record_type"})
+	unhealthyTargetsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "unhealthy_targets_total",
+		Help: "Total number of targets not published because they failed the health check.",
+	}, []string{"record_type"})
+	staleZoneReadsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "stale_zone_reads_total",
+		Help: "Total number of zones served from an expired record cache entry after a start while they were refreshed.",
+	}, []string{"zone"})
+	canaryFailuresTotal = metrics.NewCounter(prometheus.CounterOpts{
+		Name: "canary_failures_total",
+		Help: "Total number of change sets aborted because the canary zone failed verification.",
+	})
+	shadowDiscrepancies = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "shadow_discrepancies",
+		Help: "Number of records differing from the shadow reference export by kind (missing, extra, mismatched).",
+	}, []string{"kind"})
+	driftDetectedTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "drift_detected_total",
+		Help: "Total number of records and zones changed outside of external-dns by zone and kind (added, modified, removed, serial, ttl).",
+	}, []string{"zone", "kind"})
+	ownershipConflictsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "ownership_conflicts_total",
+		Help: "Total number of changes refused because the record is owned by another external-dns instance.",
+	}, []string{"action", "record_type"})
+	zoneSerial = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_serial",
+		Help: "SOA serial of each managed zone as last reported by the Netcup API.",
+	}, []string{"zone"})
+	zoneTTL = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_ttl_seconds",
+		Help: "TTL in seconds of each managed zone as last reported by the Netcup API.",
+	}, []string{"zone"})
+	zoneDelegated = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_delegated",
+		Help: "Whether each managed zone is delegated to Netcup's nameservers (1) or not (0).",
+	}, []string{"zone"})
+	consistencyMismatches = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "consistency_mismatches",
+		Help: "Number of records whose DNS answers differed from the Netcup API in the last consistency check.",
+	})
+	rateLimited = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "rate_limited",
+		Help: "Whether API calls are paused because the Netcup API rate limited the provider (1) or not (0).",
+	})
+	zonesRemovedTotal = metrics.NewCounter(prometheus.CounterOpts{
+		Name: "zones_removed_total",
+		Help: "Total number of zones removed from management by the zone refresher.",
+	})
+	changesInWindow = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "changes_last_hour",
+		Help: "Number of record changes applied within the last hour, counted if --max-changes-per-hour is set.",
+	})
+	changeRateExceeded = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "change_rate_exceeded",
+		Help: "Whether the last change set was refused because it exceeded the maximum changes per hour (1) or not (0).",
+	})
+	changeRateRejectionsTotal = metrics.NewCounter(prometheus.CounterOpts{
+		Name: "change_rate_rejections_total",
+		Help: "Total number of change sets refused because they exceeded the maximum changes per hour.",
+	})
+	zoneMissingGauge = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_missing",
+		Help: "Whether each managed zone was missing from the Netcup account when last queried (1) or not (0).",
+	}, []string{"zone"})
+	zoneApplyErrors = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zone_apply_errors",
+		Help: "Whether the last apply of changes to a zone failed (1) or succeeded (0).",
+	}, []string{"zone"})
+	unsupportedEndpointsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "unsupported_endpoints_dropped_total",
+		Help: "Total number of desired endpoints dropped because Netcup does not support their record type.",
+	}, []string{"record_type"})
+	backendMaintenance = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "backend_maintenance",
+		Help: "Whether the Netcup API is in maintenance, during which cached records are served and changes refused.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		managedZones,
+		zonesDiscoveredTotal,
+		zonesRemovedTotal,
+		zoneShardAssignment,
+		shardIndex,
+		shardCount,
+		pendingApprovals,
+		expiredApprovalsTotal,
+		policyDeniedChangesTotal,
+		protectedDeletesTotal,
+		unhealthyTargetsTotal,
+		staleZoneReadsTotal,
+		canaryFailuresTotal,
+		shadowDiscrepancies,
+		driftDetectedTotal,
+		ownershipConflictsTotal,
+		zoneSerial,
+		zoneTTL,
+		zoneDelegated,
+		consistencyMismatches,
+		rateLimited,
+		zoneApplyErrors,
+		zoneMissingGauge,
+		changesInWindow,
+		changeRateExceeded,
+		changeRateRejectionsTotal,
+		unsupportedEndpointsTotal,
+		backendMaintenance,
+	)
+}
+
+// forgetZoneMetrics removes the per-zone series of zones that are no longer managed.
+func forgetZoneMetrics(old, current []string) {
+	for _, zone := range old {
+		if !slices.Contains(current, zone) {
+			zoneSerial.DeleteLabelValues(zone)
+			zoneTTL.DeleteLabelValues(zone)
+			zoneDelegated.DeleteLabelValues(zone)
+			zoneApplyErrors.DeleteLabelValues(zone)
+			zoneMissingGauge.DeleteLabelValues(zone)
+		}
+	}
+}
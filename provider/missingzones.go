@@ -0,0 +1,62 @@
+package netcup
+
+import "time"
+
+// defaultMissingZoneBackoff is the time zones missing from the Netcup account are skipped for.
+const defaultMissingZoneBackoff = 10 * time.Minute
+
+// WithMissingZoneBackoff sets the time zones of the domain filter that are missing from the Netcup
+// account, e.g. after a transfer, are skipped for before they are queried again.
+func WithMissingZoneBackoff(backoff time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.missingZoneBackoff = backoff
+	}
+}
+
+// missingZone remembers the time a zone was found missing and the error of the API.
+type missingZone struct {
+	since time.Time
+	err   string
+}
+
+// zoneMissing reports whether zone was found missing within the backoff.
+func (p *NetcupProvider) zoneMissing(zone string) bool {
+	p.missingZonesMu.Lock()
+	defer p.missingZonesMu.Unlock()
+	missing, ok := p.missingZones[zone]
+	return ok && time.Since(missing.since) < p.missingZoneBackoff
+}
+
+// recordZoneMissing skips zone for the backoff.
+func (p *NetcupProvider) recordZoneMissing(zone string, err error) {
+	p.logger.Warn("zone is missing from the Netcup account, skipping it", "zone", zone, "backoff", p.missingZoneBackoff, "error", err.Error())
+	p.missingZonesMu.Lock()
+	defer p.missingZonesMu.Unlock()
+	if p.missingZones == nil {
+		p.missingZones = map[string]missingZone{}
+	}
+	p.missingZones[zone] = missingZone{since: time.Now(), err: err.Error()}
+	zoneMissingGauge.WithLabelValues(zone).Set(1)
+}
+
+// recordZoneFound clears zone from the missing zones.
+func (p *NetcupProvider) recordZoneFound(zone string) {
+	p.missingZonesMu.Lock()
+	defer p.missingZonesMu.Unlock()
+	if _, ok := p.missingZones[zone]; ok {
+		p.logger.Info("zone is back in the Netcup account", "zone", zone)
+		delete(p.missingZones, zone)
+	}
+	zoneMissingGauge.WithLabelValues(zone).Set(0)
+}
+
+// missingZoneErrors returns the errors of the missing zones.
+func (p *NetcupProvider) missingZoneErrors() map[string]string {
+	p.missingZonesMu.Lock()
+	defer p.missingZonesMu.Unlock()
+	errs := make(map[string]string, len(p.missingZones))
+	for zone, missing := range p.missingZones {
+		errs[zone] = missing.err
+	}
+	return errs
+}
@@ -0,0 +1,54 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestMissingZones(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithMissingZoneBackoff(time.Hour))
+	assert.NoError(t, err)
+
+	// the missing zone is skipped, the other one still served
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Contains(t, p.FailingZones(), "example.org")
+	assert.Equal(t, 1.0, testutil.ToFloat64(zoneMissingGauge.WithLabelValues("example.org")))
+
+	// and not queried again within the backoff
+	srv.AddZone("example.org", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "2.2.2.2"})
+	calls := srv.Calls("infoDnsZone")
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, calls+1, srv.Calls("infoDnsZone"))
+
+	// nor changed
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.org", endpoint.RecordTypeA, "3.3.3.3")},
+	}))
+	assert.Len(t, srv.Records("example.org"), 1)
+
+	// once the backoff passed, the zone is back
+	p.missingZoneBackoff = 0
+	records, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.NotContains(t, p.FailingZones(), "example.org")
+	assert.Equal(t, 0.0, testutil.ToFloat64(zoneMissingGauge.WithLabelValues("example.org")))
+}
@@ -1,13 +1,19 @@
 package netcup
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"golang.org/x/sync/singleflight"
 
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
@@ -17,11 +23,114 @@ import (
 // NetcupProvider is an implementation of Provider for Netcup DNS.
 type NetcupProvider struct {
 	provider.BaseProvider
-	client       *nc.NetcupDnsClient
-	session      *nc.NetcupSession
+	client      *nc.NetcupDnsClient
+	customerID  int
+	dryRun      bool
+	logger      *slog.Logger
+	apiEndpoint string
+
+	zoneCredentials map[string]Credentials
+	zoneClients     map[string]*nc.NetcupDnsClient
+	clientCustomers map[*nc.NetcupDnsClient]int
+
+	shardIndex int
+	shardCount int
+
+	applyConcurrency int
+
+	history        *ChangeHistory
+	journal        *ChangeJournal
+	approvals      *ApprovalQueue
+	policy         *Policy
+	protectDeletes bool
+	companions     *CompanionRules
+
+	healthCheckPort    int
+	healthCheckTimeout time.Duration
+
+	zoneLocker      ZoneLocker
+	zoneLockTimeout time.Duration
+
+	cache              RecordCache
+	cacheTTL           time.Duration
+	minRefreshInterval time.Duration
+	zoneReads          singleflight.Group
+	staleStart         bool
+	staleServedMu      sync.Mutex
+	staleServed        map[string]struct{}
+
+	canaryZone string
+	shadow     *ShadowComparer
+	drift      *DriftDetector
+	ownerID    string
+
+	includeOwners []string
+	excludeOwners []string
+	nameRewrites  []NameRewrite
+
+	maxRecordsPerRequest int
+	changeRate           *ChangeRateLimiter
+
+	recordTypes        []string
+	extraRecordTypes   []string
+	txtQuoting         TXTQuoting
+	unsupportedTypesMu sync.Mutex
+	unsupportedTypes   map[string]struct{}
+
+	defaultTTL endpoint.TTL
+	recordTTL  endpoint.TTL
+
+	requestedTTLsMu sync.Mutex
+	requestedTTLs   map[string]string
+	badTTLZonesMu   sync.Mutex
+	badTTLZones     map[string]struct{}
+
+	rateLimitCooldown time.Duration
+	rateLimitMu       sync.Mutex
+	rateLimitedUntil  time.Time
+
+	sessionSetsMu sync.Mutex
+	sessionSets   map[*sessionSet]struct{}
+
+	applyErrorsMu sync.Mutex
+	applyErrors   map[string]string
+
+	missingZoneBackoff time.Duration
+	missingZonesMu     sync.Mutex
+	missingZones       map[string]missingZone
+	apiHealthMu        sync.Mutex
+	apiHealth          APIHealth
+	maintenanceMu      sync.Mutex
+	maintenance        *maintenance
+
+	keepAlive        time.Duration
+	sharedSessionsMu sync.Mutex
+	sharedSessions   map[*nc.NetcupDnsClient]*nc.NetcupSession
+	// retired is set once a reload replaced the provider, so it no longer logs in shared sessions
+	retired bool
+
+	// zonesMu guards the domain filter and the zones derived from it, which may be swapped at runtime
+	zonesMu      sync.RWMutex
 	domainFilter endpoint.DomainFilter
-	dryRun       bool
-	logger       *slog.Logger
+	zones        []string
+}
+
+// Option configures optional behaviour of the NetcupProvider.
+type Option func(*NetcupProvider)
+
+// WithAPIEndpoint overrides the URL of Netcup's CCP API, e.g. for testing.
+func WithAPIEndpoint(url string) Option {
+	return func(p *NetcupProvider) {
+		p.apiEndpoint = url
+	}
+}
+
+// WithMaxRecordsPerRequest splits the records of a zone into requests of at most n records.
+// A value of 0 sends all records of a kind in a single request.
+func WithMaxRecordsPerRequest(n int) Option {
+	return func(p *NetcupProvider) {
+		p.maxRecordsPerRequest = n
+	}
 }
 
 // NetcupChange includes the changesets that need to be applied to the Netcup CCP API
@@ -33,84 +142,327 @@ type NetcupChange struct {
 }
 
 // NewNetcupProvider creates a new provider including the netcup CCP API client
-func NewNetcupProvider(domainFilterList *[]string, customerID int, apiKey string, apiPassword string, dryRun bool, logger *slog.Logger) (*NetcupProvider, error) {
+func NewNetcupProvider(domainFilterList *[]string, customerID int, apiKey string, apiPassword string, dryRun bool, logger *slog.Logger, opts ...Option) (*NetcupProvider, error) {
 	domainFilter := endpoint.NewDomainFilter(*domainFilterList)
 
 	if !domainFilter.IsConfigured() {
 		return nil, fmt.Errorf("netcup provider requires at least one configured domain in the domainFilter")
 	}
 
-	if customerID == 0 {
-		return nil, fmt.Errorf("netcup provider requires a customer ID")
+	if err := (Credentials{CustomerID: customerID, APIKey: apiKey, APIPassword: apiPassword}).validate(); err != nil {
+		return nil, err
 	}
 
-	if apiKey == "" {
-		return nil, fmt.Errorf("netcup provider requires an API Key")
+	p := &NetcupProvider{
+		domainFilter:       domainFilter,
+		dryRun:             dryRun,
+		logger:             logger,
+		shardCount:         1,
+		rateLimitCooldown:  defaultRateLimitCooldown,
+		defaultTTL:         defaultZoneTTL,
+		requestedTTLs:      map[string]string{},
+		txtQuoting:         TXTQuotingAll,
+		missingZoneBackoff: defaultMissingZoneBackoff,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
-	if apiPassword == "" {
-		return nil, fmt.Errorf("netcup provider requires an API Password")
+	if err := p.validateSharding(); err != nil {
+		return nil, err
+	}
+	if err := p.validateRecordTypes(); err != nil {
+		return nil, err
 	}
+	if err := p.txtQuoting.validate(); err != nil {
+		return nil, err
+	}
+	if p.canaryZone != "" {
+		p.canaryZone = endpoint.NewDomainFilter([]string{p.canaryZone}).Filters[0]
+		if !slices.Contains(domainFilter.Filters, p.canaryZone) {
+			return nil, fmt.Errorf("canary zone '%s' is not part of the domainFilter", p.canaryZone)
+		}
+	}
+	// the last read of each zone is kept to be served during a maintenance of the Netcup API
+	if p.cache == nil {
+		p.cache = NewMemoryRecordCache()
+	}
+	p.zones = p.shardZones(domainFilter.Filters)
+	shardIndex.Set(float64(p.shardIndex))
+	shardCount.Set(float64(p.shardCount))
 
-	client := nc.NewNetcupDnsClient(customerID, apiKey, apiPassword)
+	clientOptions := &nc.NetcupDnsClientOptions{
+		ApiEndpoint: p.apiEndpoint,
+	}
+	p.client = nc.NewNetcupDnsClientWithOptions(customerID, apiKey, apiPassword, clientOptions)
+	p.customerID = customerID
+
+	p.zoneClients = map[string]*nc.NetcupDnsClient{}
+	p.clientCustomers = map[*nc.NetcupDnsClient]int{p.client: customerID}
+	for zone, c := range p.zoneCredentials {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("invalid credentials for zone '%s': %v", zone, err)
+		}
+		client := nc.NewNetcupDnsClientWithOptions(c.CustomerID, c.APIKey, c.APIPassword, clientOptions)
+		p.zoneClients[endpoint.NewDomainFilter([]string{zone}).Filters[0]] = client
+		p.clientCustomers[client] = c.CustomerID
+	}
+	managedZones.Set(float64(len(p.zones)))
+
+	if p.approvals != nil {
+		// a queue shared across configuration reloads applies with the latest provider
+		p.approvals.mu.Lock()
+		p.approvals.apply = p.applyChanges
+		p.approvals.mu.Unlock()
+	}
 
-	return &NetcupProvider{
-		client:       client,
-		domainFilter: domainFilter,
-		dryRun:       dryRun,
-		logger:       logger,
-	}, nil
+	return p, nil
+}
+
+// managedZones returns the zones currently managed by the provider.
+func (p *NetcupProvider) managedZones() []string {
+	p.zonesMu.RLock()
+	defer p.zonesMu.RUnlock()
+	return append([]string(nil), p.zones...)
+}
+
+// currentDomainFilter returns the domain filter the provider is currently configured with.
+func (p *NetcupProvider) currentDomainFilter() endpoint.DomainFilter {
+	p.zonesMu.RLock()
+	defer p.zonesMu.RUnlock()
+	return p.domainFilter
+}
+
+// GetDomainFilter returns the domain filter of the zones assigned to this shard, so external-dns
+// only plans changes for endpoints the provider applies. The filter is serialized in full by the
+// negotiation of the webhook.
+func (p *NetcupProvider) GetDomainFilter() endpoint.DomainFilterInterface {
+	filter := p.currentDomainFilter()
+	if p.shardCount <= 1 && len(p.nameRewrites) == 0 {
+		return filter
+	}
+	zones := make([]string, 0, len(filter.Filters))
+	for _, zone := range filter.Filters {
+		if zoneShard(zone, p.shardCount) == p.shardIndex {
+			zones = append(zones, zone)
+		}
+	}
+	return endpoint.NewDomainFilter(append(zones, p.rewriteDomains()...))
+}
+
+// SetDomainFilter replaces the domain filter at runtime. All zones of the new filter assigned to this shard become managed zones.
+func (p *NetcupProvider) SetDomainFilter(domains []string) error {
+	domainFilter := endpoint.NewDomainFilter(domains)
+	if !domainFilter.IsConfigured() {
+		return fmt.Errorf("netcup provider requires at least one configured domain in the domainFilter")
+	}
+
+	zones := p.shardZones(domainFilter.Filters)
+	p.zonesMu.Lock()
+	forgetZoneMetrics(p.zones, zones)
+	p.domainFilter = domainFilter
+	p.zones = zones
+	p.zonesMu.Unlock()
+
+	managedZones.Set(float64(len(zones)))
+	p.logger.Info("domain filter updated", "domains", strings.Join(domainFilter.Filters, ","))
+	return nil
+}
+
+// setManagedZones atomically replaces the zones managed by the provider.
+func (p *NetcupProvider) setManagedZones(zones []string) {
+	p.zonesMu.Lock()
+	defer p.zonesMu.Unlock()
+	forgetZoneMetrics(p.zones, zones)
+	p.zones = zones
 }
 
 // Records delivers the list of Endpoint records for all zones.
 func (p *NetcupProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	endpoints := make([]*endpoint.Endpoint, 0)
+	err := p.StreamRecords(ctx, func(zoneEndpoints []*endpoint.Endpoint) error {
+		endpoints = append(endpoints, zoneEndpoints...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// StreamRecords passes the endpoints of each zone to fn as soon as the zone has been read, so the
+// records of large accounts need not be held in memory at once. It stops at the first error.
+func (p *NetcupProvider) StreamRecords(ctx context.Context, fn func([]*endpoint.Endpoint) error) error {
+	// the shadow comparison needs all endpoints
+	var endpoints []*endpoint.Endpoint
 
 	if p.dryRun {
 		p.logger.Debug("dry run - skipping login")
 	} else {
-		err := p.ensureLogin()
-		if err != nil {
-			return nil, err
-		}
-
-		defer p.session.Logout() //nolint:errcheck
-
-		for _, domain := range p.domainFilter.Filters {
-			// some information is on DNS zone itself, query it first
-			zone, err := p.session.InfoDnsZone(domain)
-			if err != nil {
-				return nil, fmt.Errorf("unable to query DNS zone info for domain '%v': %v", domain, err)
+		sessions := p.newSessionSet()
+		defer sessions.close()
+
+		zones := p.managedZones()
+		p.drift.forget(zones)
+		for _, domain := range zones {
+			if p.zoneMissing(domain) {
+				p.logger.Debug("skipping zone missing from the Netcup account", "domain", domain)
+				continue
 			}
-			ttl, err := strconv.ParseUint(zone.Ttl, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("unexpected error: unable to convert '%s' to uint64", zone.Ttl)
+			zone, err := p.readZone(sessions, domain)
+			if errors.Is(err, ErrZoneNotFound) {
+				// keep serving the other zones, the zone may have been transferred away
+				p.recordZoneMissing(domain, err)
+				continue
 			}
-			// query the records of the domain
-			recs, err := p.session.InfoDnsRecords(domain)
 			if err != nil {
-				if p.session.LastResponse != nil && p.session.LastResponse.Status == string(nc.StatusError) && p.session.LastResponse.StatusCode == 5029 {
-					p.logger.Debug("no records exist", "domain", domain, "error", err.Error())
-				} else {
-					return nil, fmt.Errorf("unable to get DNS records for domain '%v': %v", domain, err)
-				}
+				return err
+			}
+			p.recordZoneFound(domain)
+			ttl := p.parseZoneTTL(domain, zone.TTL)
+			zoneTTL.WithLabelValues(domain).Set(float64(ttl))
+			if p.recordTTL > 0 {
+				ttl = p.recordTTL
 			}
+			if serial, err := strconv.ParseUint(zone.Serial, 10, 64); err == nil {
+				zoneSerial.WithLabelValues(domain).Set(float64(serial))
+			}
+			p.drift.observe(domain, zone, p.logger)
 			p.logger.Info("got DNS records for domain", "domain", domain)
-			for _, rec := range *recs {
-				name := fmt.Sprintf("%s.%s", rec.Hostname, domain)
-				if rec.Hostname == "@" {
-					name = domain
+			zoneEndpoints := runEndpointStages(recordsToEndpoints(domain, ttl, zone.Records, p.txtQuoting), p.recordStages()...)
+			if p.logger.Enabled(ctx, slog.LevelDebug) {
+				for _, endpointItem := range zoneEndpoints {
+					p.logger.Debug("endpoints collected", "endpoints", endpointItem.String())
 				}
-
-				ep := endpoint.NewEndpointWithTTL(name, rec.Type, endpoint.TTL(ttl), rec.Destination)
-				endpoints = append(endpoints, ep)
+			}
+			if err := fn(zoneEndpoints); err != nil {
+				return err
+			}
+			if p.shadow != nil {
+				endpoints = append(endpoints, zoneEndpoints...)
 			}
 		}
+		p.recordAPISuccess()
 	}
-	for _, endpointItem := range endpoints {
-		p.logger.Debug("endpoints collected", "endpoints", endpointItem.String())
+	if p.shadow != nil {
+		report, err := p.shadow.compare(ctx, endpoints)
+		if err != nil {
+			p.logger.Error("unable to compare records with shadow reference", "error", err.Error())
+		} else {
+			p.logger.Info("compared records with shadow reference", "missing", len(report.Missing), "extra", len(report.Extra), "mismatched", len(report.Mismatched))
+		}
 	}
-	return endpoints, nil
+	return nil
+}
+
+// readZone returns the state of domain from the record cache, or queries the Netcup API and caches it.
+func (p *NetcupProvider) readZone(sessions *sessionSet, domain string) (CachedZone, error) {
+	if cached, ok := p.cachedZone(domain); ok {
+		p.logger.Debug("using cached DNS records for domain", "domain", domain, "fetched", cached.Fetched)
+		return cached, nil
+	}
+	if stale, ok := p.staleZone(domain); ok {
+		p.logger.Warn("using stale cached DNS records for domain while refreshing them", "domain", domain, "fetched", stale.Fetched)
+		staleZoneReadsTotal.WithLabelValues(domain).Inc()
+		go p.refreshZone(domain)
+		return stale, nil
+	}
+	// concurrent polls share a single read of the zone
+	fetched, err, _ := p.zoneReads.Do(domain, func() (any, error) {
+		return p.fetchZone(sessions, domain)
+	})
+	if err != nil {
+		if cached, ok := p.cache.Get(domain); ok && errors.Is(err, ErrMaintenance) {
+			p.logger.Debug("using cached DNS records for domain during maintenance", "domain", domain, "fetched", cached.Fetched)
+			return cached, nil
+		}
+		return CachedZone{}, err
+	}
+	return fetched.(CachedZone), nil
+}
+
+// fetchZone reads the state of domain from the Netcup API and caches it.
+func (p *NetcupProvider) fetchZone(sessions *sessionSet, domain string) (CachedZone, error) {
+	session, err := sessions.forZone(domain)
+	if err != nil {
+		return CachedZone{}, err
+	}
+	// some information is on DNS zone itself, query it first
+	zone, err := session.InfoDnsZone(domain)
+	if err != nil {
+		err = p.apiError(err, false)
+		sessions.invalidate(domain, err)
+		return CachedZone{}, fmt.Errorf("unable to query DNS zone info for domain '%v': %w", domain, err)
+	}
+	// query the records of the domain
+	recs, err := fetchZoneRecords(session, domain)
+	if err != nil {
+		err = p.apiError(err, false)
+		sessions.invalidate(domain, err)
+		return CachedZone{}, fmt.Errorf("unable to get DNS records for domain '%v': %w", domain, err)
+	}
+	p.leaveMaintenance()
+	fetched := CachedZone{TTL: zone.Ttl, Serial: zone.Serial, Records: recs, Fetched: time.Now()}
+	p.cache.Set(domain, fetched)
+	return fetched, nil
+}
+
+// recordsToEndpoints groups the records of domain by hostname and type into endpoints, as external-dns
+// expects a single endpoint with all targets per name and type.
+func recordsToEndpoints(domain string, ttl endpoint.TTL, recs []nc.DnsRecord, quoting TXTQuoting) []*endpoint.Endpoint {
+	type recordKey struct {
+		recordType, hostname string
+	}
+	endpoints := make([]*endpoint.Endpoint, 0, len(recs))
+	byKey := make(map[recordKey]*endpoint.Endpoint, len(recs))
+	for _, rec := range recs {
+		key := recordKey{rec.Type, rec.Hostname}
+		target := strings.TrimSuffix(rec.Destination, ".")
+		switch {
+		case rec.Type == endpoint.RecordTypeTXT:
+			target = quoting.text(rec.Destination)
+		case opaqueRecordType(rec.Type):
+			target = rec.Destination
+		}
+		if ep, ok := byKey[key]; ok {
+			ep.Targets = append(ep.Targets, target)
+			continue
+		}
+		name := domain
+		if rec.Hostname != "@" {
+			name = rec.Hostname + "." + domain
+		}
+		ep := &endpoint.Endpoint{
+			DNSName:    name,
+			Targets:    endpoint.Targets{target},
+			RecordType: rec.Type,
+			RecordTTL:  ttl,
+			Labels:     endpoint.NewLabels(),
+		}
+		byKey[key] = ep
+		endpoints = append(endpoints, ep)
+	}
+	sortEndpoints(endpoints)
+	return endpoints
+}
+
+// sortEndpoints orders endpoints by type and name and their targets, so the records served do not
+// depend on the order the Netcup API returns them in.
+func sortEndpoints(endpoints []*endpoint.Endpoint) {
+	for _, ep := range endpoints {
+		slices.Sort(ep.Targets)
+	}
+	slices.SortFunc(endpoints, func(a, b *endpoint.Endpoint) int {
+		return cmp.Or(cmp.Compare(a.RecordType, b.RecordType), cmp.Compare(a.DNSName, b.DNSName))
+	})
+}
+
+// sortRecords orders records by type, hostname and destination, so logs and requests do not depend
+// on the order of the changes.
+func sortRecords(recs []nc.DnsRecord) {
+	slices.SortStableFunc(recs, func(a, b nc.DnsRecord) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Hostname, b.Hostname), cmp.Compare(a.Destination, b.Destination))
+	})
 }
 
 // ApplyChanges applies a given set of changes in a given zone.
@@ -120,25 +472,148 @@ func (p *NetcupProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 		return nil
 	}
 
-	if p.dryRun {
-		p.logger.Debug("dry run - skipping login")
-	} else {
-		err := p.ensureLogin()
+	p.rememberRequestedTTLs(changes)
+	changes = p.runChangeStages(ctx, changes, p.changeStages()...)
+	if changes == nil {
+		return nil
+	}
+
+	// conflicting changes are reported after applying the remaining ones
+	changes, ownershipErr := p.checkOwnership(changes)
+	if !changes.HasChanges() {
+		return ownershipErr
+	}
+
+	if p.approvals != nil {
+		pc, err := p.approvals.park(changes)
 		if err != nil {
 			return err
 		}
-		defer p.session.Logout() //nolint:errcheck
+		p.logger.Info("changes awaiting approval", "id", pc.ID, "expires", pc.Expires)
+		return ownershipErr
+	}
+
+	if err := p.applyChanges(ctx, changes); err != nil {
+		return err
 	}
+	return ownershipErr
+}
+
+// applyChanges applies the changes without waiting for approval.
+func (p *NetcupProvider) applyChanges(ctx context.Context, changes *plan.Changes) error {
+
+	zones := p.managedZones()
+	perZoneChanges := p.splitChanges(changes, zones)
+
+	if p.dryRun {
+		p.reportSimulation(ctx, SimulationDryRun, perZoneChanges)
+		return nil
+	}
+	if p.inMaintenance() {
+		return errChangesDuringMaintenance
+	}
+
+	if p.shadow != nil {
+		p.reportSimulation(ctx, SimulationShadow, perZoneChanges)
+	}
+
+	sessions := p.newSessionSet()
+	defer sessions.close()
+
+	// Assemble changes per zone and prepare it for the Netcup API client
+	var pending []string
+	for _, zoneName := range p.applyOrder(zones) {
+		if !perZoneChanges[zoneName].HasChanges() {
+			continue
+		}
+		if p.zoneMissing(zoneName) {
+			p.logger.Warn("not applying changes to zone missing from the Netcup account", "zone", zoneName)
+			continue
+		}
+		pending = append(pending, zoneName)
+	}
+	// the canary zone is applied on its own before all other zones
+	if len(pending) > 0 && pending[0] == p.canaryZone {
+		if err := p.applyZone(ctx, sessions, pending[0], perZoneChanges[pending[0]]); err != nil {
+			return err
+		}
+		pending = pending[1:]
+	}
+	if err := p.applyZones(ctx, sessions, pending, perZoneChanges); err != nil {
+		return err
+	}
+
+	p.logger.Debug("update completed")
+
+	return nil
+}
+
+// applyZone applies the changes c to zoneName, recording the outcome in the history, metrics and
+// logs.
+func (p *NetcupProvider) applyZone(ctx context.Context, sessions *sessionSet, zoneName string, c *plan.Changes) error {
+	session, err := sessions.forZone(zoneName)
+	if err != nil {
+		p.recordApplyResult(zoneName, err)
+		return err
+	}
+	// zones applied concurrently may share the session, but not its last response
+	zoneSession := *session
+	session = &zoneSession
+	unlock, err := p.lockZone(ctx, zoneName)
+	if err != nil {
+		p.recordApplyResult(zoneName, err)
+		return err
+	}
+	start := time.Now()
+	change, calls, err := p.applyZoneChanges(session, zoneName, c)
+	if err != nil {
+		sessions.invalidate(zoneName, err)
+	}
+	if p.cache != nil {
+		// even failed changes may have changed some records
+		p.cache.Invalidate(zoneName)
+	}
+	verification := "skipped"
+	if zoneName == p.canaryZone && p.shadow == nil {
+		if err == nil {
+			calls++
+			verification = "passed"
+			if err = verifyZoneChanges(session, zoneName, change); err != nil {
+				verification = "failed"
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("canary zone '%s' failed, not applying changes to other zones: %w", zoneName, err)
+			canaryFailuresTotal.Inc()
+		}
+	}
+	unlock()
+	entry := newChangeEntry(zoneName, change, start, err)
+	if p.shadow != nil {
+		entry.Outcome = "shadow"
+	}
+	p.recordChange(entry)
+	p.recordApplyResult(zoneName, err)
+	p.logZoneSummary(entry, calls, verification)
+	if err != nil {
+		return err
+	}
+	p.recordAPISuccess()
+	return nil
+}
+
+// splitChanges splits changes by the zone of their endpoints. Endpoints outside of zones are ignored.
+func (p *NetcupProvider) splitChanges(changes *plan.Changes, zones []string) map[string]*plan.Changes {
 	perZoneChanges := map[string]*plan.Changes{}
 
-	for _, zoneName := range p.domainFilter.Filters {
+	for _, zoneName := range zones {
 		p.logger.Debug("zone detected", "zone", zoneName)
 
 		perZoneChanges[zoneName] = &plan.Changes{}
 	}
 
 	for _, ep := range changes.Create {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := endpointZoneName(ep, zones)
 		if zoneName == "" {
 			p.logger.Debug("ignoring change since it did not match any zone", "type", "create", "endpoint", ep)
 			continue
@@ -149,7 +624,7 @@ func (p *NetcupProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 	}
 
 	for _, ep := range changes.UpdateOld {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := endpointZoneName(ep, zones)
 		if zoneName == "" {
 			p.logger.Debug("ignoring change since it did not match any zone", "type", "updateOld", "endpoint", ep)
 			continue
@@ -160,7 +635,7 @@ func (p *NetcupProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 	}
 
 	for _, ep := range changes.UpdateNew {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := endpointZoneName(ep, zones)
 		if zoneName == "" {
 			p.logger.Debug("ignoring change since it did not match any zone", "type", "updateNew", "endpoint", ep)
 			continue
@@ -170,7 +645,7 @@ func (p *NetcupProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 	}
 
 	for _, ep := range changes.Delete {
-		zoneName := endpointZoneName(ep, p.domainFilter.Filters)
+		zoneName := endpointZoneName(ep, zones)
 		if zoneName == "" {
 			p.logger.Debug("ignoring change since it did not match any zone", "type", "delete", "endpoint", ep)
 			continue
@@ -178,112 +653,199 @@ func (p *NetcupProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 		p.logger.Debug("planning", "type", "delete", "endpoint", ep, "zone", zoneName)
 		perZoneChanges[zoneName].Delete = append(perZoneChanges[zoneName].Delete, ep)
 	}
+	return perZoneChanges
+}
 
-	if p.dryRun {
-		p.logger.Info("dry run - not applying changes")
-		return nil
+// fetchZoneRecords returns the records of zoneName. The API reports a zone without records as an error,
+// it is returned as an empty list.
+func fetchZoneRecords(session *nc.NetcupSession, zoneName string) ([]nc.DnsRecord, error) {
+	recs, err := session.InfoDnsRecords(zoneName)
+	switch {
+	case err != nil && isNoRecordsResponse(session):
+		return []nc.DnsRecord{}, nil
+	case err != nil:
+		return nil, err
+	case recs == nil:
+		return []nc.DnsRecord{}, nil
 	}
+	return *recs, nil
+}
 
-	// Assemble changes per zone and prepare it for the Netcup API client
-	for zoneName, c := range perZoneChanges {
-		// Gather records from API to extract the record ID which is necessary for updating/deleting the record
-		recs, err := p.session.InfoDnsRecords(zoneName)
+// applyZoneChanges converts the changes of a single zone and sends them to the Netcup API.
+// The converted change and the number of API calls are returned even if applying it failed.
+func (p *NetcupProvider) applyZoneChanges(session *nc.NetcupSession, zoneName string, c *plan.Changes) (*NetcupChange, int, error) {
+	change, err := p.convertZoneChanges(session, zoneName, c)
+	// the records of the zone are read once
+	calls := 1
+	if err != nil {
+		return change, calls, err
+	}
+
+	if p.shadow != nil {
+		p.logger.Info("shadow mode - not applying changes", "zone", zoneName)
+		return change, calls, nil
+	}
+
+	// only the records actually sent count against the change rate
+	if err := p.changeRate.reserve(len(*change.Create) + len(*change.UpdateNew) + len(*change.Delete)); err != nil {
+		p.logger.Error("refusing to apply changes exceeding the maximum change rate", "zone", zoneName, "error", err.Error())
+		return change, calls, err
+	}
+
+	p.drift.expect(zoneName, change)
+	for _, batch := range []struct {
+		action  string
+		records []nc.DnsRecord
+	}{
+		{"updateOld", *change.UpdateOld},
+		{"delete", *change.Delete},
+		{"create", *change.Create},
+		{"updateNew", *change.UpdateNew},
+	} {
+		n, err := p.updateRecords(session, zoneName, batch.action, batch.records)
+		calls += n
 		if err != nil {
-			if p.session.LastResponse != nil && p.session.LastResponse.Status == string(nc.StatusError) && p.session.LastResponse.StatusCode == 5029 {
-				p.logger.Debug("no records exist", "zone", zoneName, "error", err.Error())
-			} else {
-				p.logger.Error("unable to get DNS records for domain", "zone", zoneName, "error", err.Error())
+			return change, calls, err
+		}
+	}
+	return change, calls, nil
+}
+
+// convertZoneChanges converts the changes of a single zone to the records to send to the Netcup API.
+// The converted change is returned even if the records of the zone could not be read.
+func (p *NetcupProvider) convertZoneChanges(session *nc.NetcupSession, zoneName string, c *plan.Changes) (*NetcupChange, error) {
+	// Gather records from API to extract the record ID which is necessary for updating/deleting the record
+	recs, fetchErr := fetchZoneRecords(session, zoneName)
+	ids := newRecordIDs(recs, p.txtQuoting)
+	change := &NetcupChange{
+		Create:    convertToNetcupRecord(ids, c.Create, zoneName, false, p.txtQuoting),
+		UpdateNew: convertToNetcupRecord(ids, c.UpdateNew, zoneName, false, p.txtQuoting),
+		UpdateOld: convertToNetcupRecord(ids, c.UpdateOld, zoneName, true, p.txtQuoting),
+		Delete:    convertToNetcupRecord(ids, c.Delete, zoneName, true, p.txtQuoting),
+	}
+	if fetchErr != nil {
+		// without the record IDs, updates and deletions cannot be applied
+		return change, fmt.Errorf("unable to get DNS records for zone '%s': %w", zoneName, p.apiError(fetchErr, false))
+	}
+	for _, stage := range p.zoneChangeStages() {
+		stage(zoneName, change, recs)
+	}
+	return change, nil
+}
+
+// updateRecords sends records to the API in chunks of at most maxRecordsPerRequest records.
+// Chunks are applied sequentially; on failure the error reports how many records were applied,
+// the remaining ones are planned again by external-dns on its next run. It returns the number of
+// requests sent.
+func (p *NetcupProvider) updateRecords(session *nc.NetcupSession, zoneName string, action string, records []nc.DnsRecord) (int, error) {
+	size := p.maxRecordsPerRequest
+	if size <= 0 || size > len(records) {
+		size = len(records)
+	}
+	chunks := 0
+	if size > 0 {
+		chunks = (len(records) + size - 1) / size
+	}
+	for i := 0; i < chunks; i++ {
+		chunk := records[i*size : min((i+1)*size, len(records))]
+		if _, err := session.UpdateDnsRecords(zoneName, &chunk); err != nil {
+			err = p.apiError(err, false)
+			if i == 0 {
+				return 1, err
 			}
+			return i + 1, fmt.Errorf("applied %d of %d records (%s) before failing: %w", i*size, len(records), action, err)
 		}
-		change := &NetcupChange{
-			Create:    convertToNetcupRecord(recs, c.Create, zoneName, false),
-			UpdateNew: convertToNetcupRecord(recs, c.UpdateNew, zoneName, false),
-			UpdateOld: convertToNetcupRecord(recs, c.UpdateOld, zoneName, true),
-			Delete:    convertToNetcupRecord(recs, c.Delete, zoneName, true),
+		if chunks > 1 {
+			p.logger.Info("applied chunk of records", "zone", zoneName, "type", action, "chunk", i+1, "chunks", chunks, "records", min((i+1)*size, len(records)), "total", len(records))
 		}
+	}
+	return chunks, nil
+}
 
-		// If not in dry run, apply changes
-		_, err = p.session.UpdateDnsRecords(zoneName, change.UpdateOld)
-		if err != nil {
-			return err
+// mergeUpdates avoids the gap between deleting the old and creating the new record of an update:
+// records whose destination did not change are left alone, and the remaining records of the same
+// hostname and type are modified in place. Other records keep the delete and create semantics.
+func mergeUpdates(change *NetcupChange, quoting TXTQuoting) {
+	key := func(rec nc.DnsRecord) string {
+		return rec.Type + " " + rec.Hostname
+	}
+	oldRecs, newRecs := *change.UpdateOld, *change.UpdateNew
+	used := make([]bool, len(oldRecs))
+	unchanged := make([]bool, len(newRecs))
+	for j, rec := range newRecs {
+		for i, old := range oldRecs {
+			if !used[i] && old.Id != "" && key(old) == key(rec) && quoting.sameDestination(rec.Type, old.Destination, rec.Destination) {
+				used[i], unchanged[j] = true, true
+				break
+			}
 		}
-		_, err = p.session.UpdateDnsRecords(zoneName, change.Delete)
-		if err != nil {
-			return err
+	}
+
+	updateNew := []nc.DnsRecord{}
+	for j, rec := range newRecs {
+		if unchanged[j] {
+			continue
 		}
-		_, err = p.session.UpdateDnsRecords(zoneName, change.Create)
-		if err != nil {
-			return err
+		for i, old := range oldRecs {
+			if !used[i] && old.Id != "" && key(old) == key(rec) {
+				used[i] = true
+				rec.Id = old.Id
+				rec.Priority = old.Priority
+				break
+			}
 		}
-		_, err = p.session.UpdateDnsRecords(zoneName, change.UpdateNew)
-		if err != nil {
-			return err
+		updateNew = append(updateNew, rec)
+	}
+	updateOld := []nc.DnsRecord{}
+	for i, old := range oldRecs {
+		if !used[i] {
+			updateOld = append(updateOld, old)
 		}
 	}
-
-	p.logger.Debug("update completed")
-
-	return nil
+	change.UpdateOld = &updateOld
+	change.UpdateNew = &updateNew
 }
 
-// convertToNetcupRecord transforms a list of endpoints into a list of Netcup DNS Records
+// convertToNetcupRecord transforms a list of endpoints into a list of Netcup DNS Records, one per target
 // returns a pointer to a list of DNS Records
-func convertToNetcupRecord(recs *[]nc.DnsRecord, endpoints []*endpoint.Endpoint, zoneName string, DeleteRecord bool) *[]nc.DnsRecord {
-	records := make([]nc.DnsRecord, len(endpoints))
+func convertToNetcupRecord(ids *recordIDs, endpoints []*endpoint.Endpoint, zoneName string, DeleteRecord bool, quoting TXTQuoting) *[]nc.DnsRecord {
+	records := make([]nc.DnsRecord, 0, len(endpoints))
 
-	for i, ep := range endpoints {
+	for _, ep := range endpoints {
 		recordName := strings.TrimSuffix(ep.DNSName, "."+zoneName)
 		if recordName == zoneName {
 			recordName = "@"
 		}
-		target := ep.Targets[0]
-		if ep.RecordType == endpoint.RecordTypeTXT && strings.HasPrefix(target, "\"heritage=") {
-			target = strings.Trim(ep.Targets[0], "\"")
-		}
+		for _, target := range ep.Targets {
+			if ep.RecordType == endpoint.RecordTypeTXT {
+				target = quoting.text(target)
+			}
+			// PTR targets are hostnames outside of the reverse zone and must be fully qualified
+			if ep.RecordType == endpoint.RecordTypePTR && !strings.HasSuffix(target, ".") {
+				target += "."
+			}
 
-		records[i] = nc.DnsRecord{
-			Type:         ep.RecordType,
-			Hostname:     recordName,
-			Destination:  target,
-			Id:           getIDforRecord(recordName, target, ep.RecordType, recs),
-			DeleteRecord: DeleteRecord,
+			records = append(records, nc.DnsRecord{
+				Type:         ep.RecordType,
+				Hostname:     recordName,
+				Destination:  target,
+				Id:           ids.id(recordName, target, ep.RecordType),
+				DeleteRecord: DeleteRecord,
+			})
 		}
 	}
+	sortRecords(records)
 	return &records
 }
 
-// getIDforRecord compares the endpoint with existing records to get the ID from Netcup to ensure it can be safely removed.
-// returns empty string if no match found
-func getIDforRecord(recordName string, target string, recordType string, recs *[]nc.DnsRecord) string {
-	for _, rec := range *recs {
-		if recordType == rec.Type && target == rec.Destination && rec.Hostname == recordName {
-			return rec.Id
-		}
-	}
-
-	return ""
-}
-
 // endpointZoneName determines zoneName for endpoint by taking longest suffix zoneName match in endpoint DNSName
 // returns empty string if no match found
 func endpointZoneName(endpoint *endpoint.Endpoint, zones []string) (zone string) {
 	var matchZoneName string = ""
 	for _, zoneName := range zones {
-		if strings.HasSuffix(endpoint.DNSName, zoneName) && len(zoneName) > len(matchZoneName) {
+		if (endpoint.DNSName == zoneName || strings.HasSuffix(endpoint.DNSName, "."+zoneName)) && len(zoneName) > len(matchZoneName) {
 			matchZoneName = zoneName
 		}
 	}
 	return matchZoneName
 }
-
-// ensureLogin makes sure that we are logged in to Netcup API.
-func (p *NetcupProvider) ensureLogin() error {
-	p.logger.Debug("performing login to Netcup DNS API")
-	session, err := p.client.Login()
-	if err != nil {
-		return err
-	}
-	p.session = session
-	p.logger.Debug("successfully logged in to Netcup DNS API")
-	return nil
-}
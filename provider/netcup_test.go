@@ -16,10 +16,12 @@ package netcup
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"testing"
 
 	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
 	"github.com/prometheus/common/promslog"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/external-dns/endpoint"
@@ -30,8 +32,11 @@ func TestNetcupProvider(t *testing.T) {
 	t.Run("EndpointZoneName", testEndpointZoneName)
 	t.Run("GetIDforRecord", testGetIDforRecord)
 	t.Run("ConvertToNetcupRecord", testConvertToNetcupRecord)
+	t.Run("MergeUpdates", testMergeUpdates)
+	t.Run("RecordsToEndpoints", testRecordsToEndpoints)
 	t.Run("NewNetcupProvider", testNewNetcupProvider)
 	t.Run("ApplyChanges", testApplyChanges)
+	t.Run("ApplyChangesChunked", testApplyChangesChunked)
 	t.Run("Records", testRecords)
 }
 
@@ -59,9 +64,23 @@ func testEndpointZoneName(t *testing.T) {
 		RecordType: endpoint.RecordTypeA,
 	}
 
+	// shares a suffix with a zone but is not part of it
+	ep4 := endpoint.Endpoint{
+		DNSName:    "foobar.org",
+		Targets:    endpoint.Targets{"5.5.5.5"},
+		RecordType: endpoint.RecordTypeA,
+	}
+
 	assert.Equal(t, endpointZoneName(&ep1, zoneList), "bar.org")
 	assert.Equal(t, endpointZoneName(&ep2, zoneList), "")
 	assert.Equal(t, endpointZoneName(&ep3, zoneList), "baz.org")
+	assert.Equal(t, endpointZoneName(&ep4, zoneList), "")
+
+	// reverse zones
+	reverseZones := []string{"2.0.192.in-addr.arpa", "0/26.2.0.192.in-addr.arpa"}
+	assert.Equal(t, "2.0.192.in-addr.arpa", endpointZoneName(endpoint.NewEndpoint("4.2.0.192.in-addr.arpa", endpoint.RecordTypePTR, "www.example.com"), reverseZones))
+	assert.Equal(t, "", endpointZoneName(endpoint.NewEndpoint("4.12.0.192.in-addr.arpa", endpoint.RecordTypePTR, "www.example.com"), reverseZones))
+	assert.Equal(t, "0/26.2.0.192.in-addr.arpa", endpointZoneName(endpoint.NewEndpoint("5.0/26.2.0.192.in-addr.arpa", endpoint.RecordTypePTR, "www.example.com"), reverseZones))
 }
 
 func testGetIDforRecord(t *testing.T) {
@@ -96,8 +115,19 @@ func testGetIDforRecord(t *testing.T) {
 
 	ncRecordList := []nc.DnsRecord{nc1, nc2, nc3}
 
-	assert.Equal(t, "10", getIDforRecord(recordName, target1, recordType, &ncRecordList))
-	assert.Equal(t, "", getIDforRecord(recordName, target2, recordType, &ncRecordList))
+	assert.Equal(t, "10", newRecordIDs(ncRecordList, TXTQuotingAll).id(recordName, target1, recordType))
+	assert.Equal(t, "", newRecordIDs(ncRecordList, TXTQuotingAll).id(recordName, target2, recordType))
+
+	// the first of duplicate records wins, hostnames match with and without trailing dot and TXT
+	// values with and without quotes
+	duplicates := []nc.DnsRecord{
+		{Id: "1", Hostname: "www", Type: "CNAME", Destination: "example.org."},
+		{Id: "2", Hostname: "www", Type: "CNAME", Destination: "example.org"},
+		{Id: "3", Hostname: "txt", Type: "TXT", Destination: `"\"a\""`},
+	}
+	ids := newRecordIDs(duplicates, TXTQuotingAll)
+	assert.Equal(t, "1", ids.id("www", "example.org", "CNAME"))
+	assert.Equal(t, "3", ids.id("txt", `"\"a\""`, "TXT"))
 
 }
 
@@ -163,9 +193,11 @@ func testConvertToNetcupRecord(t *testing.T) {
 	}
 
 	ncRecordList := []nc.DnsRecord{nc1, nc2, nc3, nc4}
+	// converted records are ordered by type, hostname and destination
+	sorted := []nc.DnsRecord{nc3, nc1, nc2, nc4}
 
 	// No deletion
-	assert.Equal(t, convertToNetcupRecord(&ncRecordList, epList, "bar.org", false), &ncRecordList)
+	assert.Equal(t, convertToNetcupRecord(newRecordIDs(ncRecordList, TXTQuotingAll), epList, "bar.org", false, TXTQuotingAll), &sorted)
 	// Deletion active
 
 	nc1.DeleteRecord = true
@@ -173,8 +205,74 @@ func testConvertToNetcupRecord(t *testing.T) {
 	nc3.DeleteRecord = true
 	nc4.DeleteRecord = true
 	ncRecordList2 := []nc.DnsRecord{nc1, nc2, nc3, nc4}
-	assert.Equal(t, convertToNetcupRecord(&ncRecordList2, epList, "bar.org", true), &ncRecordList2)
+	sorted2 := []nc.DnsRecord{nc3, nc1, nc2, nc4}
+	assert.Equal(t, convertToNetcupRecord(newRecordIDs(ncRecordList2, TXTQuotingAll), epList, "bar.org", true, TXTQuotingAll), &sorted2)
+
+	// PTR records in reverse zones have fully qualified targets
+	existing := []nc.DnsRecord{{Id: "7", Hostname: "4", Type: "PTR", Destination: "www.example.com."}}
+	ptr := endpoint.NewEndpoint("4.2.0.192.in-addr.arpa", endpoint.RecordTypePTR, "www.example.com")
+	assert.Equal(t, &[]nc.DnsRecord{{Id: "7", Hostname: "4", Type: "PTR", Destination: "www.example.com.", DeleteRecord: true}},
+		convertToNetcupRecord(newRecordIDs(existing, TXTQuotingAll), []*endpoint.Endpoint{ptr}, "2.0.192.in-addr.arpa", true, TXTQuotingAll))
+}
+
+func testMergeUpdates(t *testing.T) {
+	change := &NetcupChange{
+		UpdateOld: &[]nc.DnsRecord{
+			{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1", Priority: "0", DeleteRecord: true},
+			{Id: "", Hostname: "unknown", Type: "A", Destination: "2.2.2.2", DeleteRecord: true},
+			{Id: "3", Hostname: "mail", Type: "A", Destination: "mail.example.com", DeleteRecord: true},
+			{Id: "4", Hostname: "api", Type: "A", Destination: "5.5.5.5", DeleteRecord: true},
+			{Id: "5", Hostname: "api", Type: "A", Destination: "6.6.6.6", DeleteRecord: true},
+		},
+		UpdateNew: &[]nc.DnsRecord{
+			{Hostname: "www", Type: "A", Destination: "3.3.3.3"},
+			{Hostname: "unknown", Type: "A", Destination: "4.4.4.4"},
+			{Hostname: "mail", Type: "CNAME", Destination: "mx.example.com"},
+			{Hostname: "api", Type: "A", Destination: "7.7.7.7"},
+			{Hostname: "api", Type: "A", Destination: "5.5.5.5"},
+		},
+	}
+	mergeUpdates(change, TXTQuotingAll)
+
+	assert.Equal(t, []nc.DnsRecord{
+		{Id: "", Hostname: "unknown", Type: "A", Destination: "2.2.2.2", DeleteRecord: true},
+		{Id: "3", Hostname: "mail", Type: "A", Destination: "mail.example.com", DeleteRecord: true},
+	}, *change.UpdateOld)
+	assert.Equal(t, []nc.DnsRecord{
+		{Id: "1", Hostname: "www", Type: "A", Destination: "3.3.3.3", Priority: "0"},
+		{Hostname: "unknown", Type: "A", Destination: "4.4.4.4"},
+		{Hostname: "mail", Type: "CNAME", Destination: "mx.example.com"},
+		{Id: "5", Hostname: "api", Type: "A", Destination: "7.7.7.7"},
+	}, *change.UpdateNew)
+}
+
+func testRecordsToEndpoints(t *testing.T) {
+	recs := []nc.DnsRecord{
+		{Hostname: "mail", Type: "CNAME", Destination: "mx.example.org."},
+		{Hostname: "www", Type: "A", Destination: "3.3.3.3"},
+		{Hostname: "www", Type: "AAAA", Destination: "::1"},
+		{Hostname: "www", Type: "A", Destination: "2.2.2.2"},
+		{Hostname: "@", Type: "A", Destination: "1.1.1.1"},
+	}
+	// ordered by type, name and target regardless of the order of the records
+	assert.Equal(t, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.com", endpoint.RecordTypeA, 300, "1.1.1.1"),
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 300, "2.2.2.2", "3.3.3.3"),
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeAAAA, 300, "::1"),
+		endpoint.NewEndpointWithTTL("mail.example.com", endpoint.RecordTypeCNAME, 300, "mx.example.org"),
+	}, recordsToEndpoints("example.com", 300, recs, TXTQuotingAll))
+}
 
+func BenchmarkRecordsToEndpoints(b *testing.B) {
+	recs := make([]nc.DnsRecord, 0, 5000)
+	for i := 0; i < cap(recs); i++ {
+		recs = append(recs, nc.DnsRecord{Hostname: fmt.Sprintf("host%d", i/2), Type: "A", Destination: fmt.Sprintf("10.0.%d.%d", i/256, i%256)})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recordsToEndpoints("example.com", 300, recs, TXTQuotingAll)
+	}
 }
 
 func testNewNetcupProvider(t *testing.T) {
@@ -265,6 +363,26 @@ func testApplyChanges(t *testing.T) {
 
 }
 
+func testApplyChangesChunked(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithMaxRecordsPerRequest(2))
+	assert.NoError(t, err)
+
+	changes := &plan.Changes{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		changes.Create = append(changes.Create, endpoint.NewEndpoint(name+".example.com", endpoint.RecordTypeA, "1.1.1.1"))
+	}
+	err = p.ApplyChanges(context.TODO(), changes)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, srv.Calls("updateDnsRecords"))
+	assert.Len(t, srv.Records("example.com"), 5)
+}
+
 func testRecords(t *testing.T) {
 	domainFilter := []string{"example.com"}
 	var logger *slog.Logger
@@ -275,3 +393,74 @@ func testRecords(t *testing.T) {
 	assert.Equal(t, []*endpoint.Endpoint{}, ep)
 	assert.NoError(t, err)
 }
+
+func TestStreamRecords(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+	srv.AddZone("example.org", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "2.2.2.2"})
+
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// endpoints are passed zone by zone
+	var zones [][]string
+	err = p.StreamRecords(context.TODO(), func(endpoints []*endpoint.Endpoint) error {
+		var names []string
+		for _, ep := range endpoints {
+			names = append(names, ep.DNSName)
+		}
+		zones = append(zones, names)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"www.example.com"}, {"www.example.org"}}, zones)
+
+	// an error of fn stops the stream
+	calls := 0
+	err = p.StreamRecords(context.TODO(), func([]*endpoint.Endpoint) error {
+		calls++
+		return fmt.Errorf("client gone")
+	})
+	assert.EqualError(t, err, "client gone")
+	assert.Equal(t, 1, calls)
+}
+
+func TestEmptyZone(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithCanaryZone("example.com"))
+	assert.NoError(t, err)
+
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	// creates into a zone without records are applied and verified
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("a-www.example.com", endpoint.RecordTypeTXT, "heritage=external-dns"),
+	}}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, srv.Records("example.com"), 2)
+}
+
+func TestApplyChangesWithoutRecordIDs(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// records cannot be updated or deleted without their IDs, so nothing is sent
+	srv.FailAction("infoDnsRecords", 5000)
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")}})
+	assert.ErrorContains(t, err, "unable to get DNS records for zone 'example.com'")
+	assert.Len(t, srv.Records("example.com"), 1)
+}
@@ -0,0 +1,288 @@
+// Package netcuptest provides an in-memory fake of Netcup's CCP DNS API for tests.
+package netcuptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+const (
+	// StatusCodeSuccess is returned for successful requests.
+	StatusCodeSuccess = 2000
+	// StatusCodeNoRecords is returned by infoDnsRecords for zones without records.
+	StatusCodeNoRecords = 5029
+	// StatusCodeUnknownZone is returned for zones that are not part of the account.
+	StatusCodeUnknownZone = 5028
+)
+
+// Zone is a DNS zone held by the fake server.
+type Zone struct {
+	Info    nc.DnsZoneData
+	Records []nc.DnsRecord
+}
+
+// Server is a fake CCP API endpoint backed by in-memory zones.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	zones  map[string]*Zone
+	errors map[string]failure
+	calls  map[string]int
+	logins map[int]int
+	nextID int
+
+	rateLimited bool
+}
+
+// NewServer starts a fake CCP API server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		zones:  map[string]*Zone{},
+		errors: map[string]failure{},
+		calls:  map[string]int{},
+		logins: map[int]int{},
+		nextID: 1,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// AddZone adds a zone with the given TTL and records. Records without an ID get one assigned.
+func (s *Server) AddZone(name string, ttl string, records ...nc.DnsRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z := &Zone{Info: nc.DnsZoneData{DomainName: name, Ttl: ttl, Serial: "2024010101"}}
+	for _, rec := range records {
+		if rec.Id == "" {
+			rec.Id = strconv.Itoa(s.nextID)
+			s.nextID++
+		}
+		z.Records = append(z.Records, rec)
+	}
+	s.zones[name] = z
+}
+
+// RemoveZone removes a zone from the account.
+func (s *Server) RemoveZone(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.zones, name)
+}
+
+// Records returns a copy of the records currently stored for a zone.
+func (s *Server) Records(name string) []nc.DnsRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[name]
+	if !ok {
+		return nil
+	}
+	return append([]nc.DnsRecord(nil), z.Records...)
+}
+
+// ZoneInfo returns the settings of a zone.
+func (s *Server) ZoneInfo(name string) nc.DnsZoneData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[name]
+	if !ok {
+		return nc.DnsZoneData{}
+	}
+	return z.Info
+}
+
+// UpdateRecords changes the records of a zone directly, like an edit in the CCP web interface would.
+// It follows the semantics of updateDnsRecords.
+func (s *Server) UpdateRecords(name string, records ...nc.DnsRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if z, ok := s.zones[name]; ok {
+		s.update(z, records)
+	}
+}
+
+// failure is the response of a failing action.
+type failure struct {
+	statusCode int
+	message    string
+}
+
+// FailAction makes every subsequent request for action fail with the given status code.
+// A status code of 0 clears the failure.
+func (s *Server) FailAction(action string, statusCode int) {
+	s.FailActionWithMessage(action, statusCode, "")
+}
+
+// FailActionWithMessage is like FailAction, and responds with message as the long message.
+func (s *Server) FailActionWithMessage(action string, statusCode int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if statusCode == 0 {
+		delete(s.errors, action)
+		return
+	}
+	s.errors[action] = failure{statusCode: statusCode, message: message}
+}
+
+// RateLimit makes every subsequent request fail with HTTP status 429 until disabled again.
+func (s *Server) RateLimit(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimited = enabled
+}
+
+// Calls returns the number of requests received for action.
+func (s *Server) Calls(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[action]
+}
+
+// Logins returns the number of logins received for customerID.
+func (s *Server) Logins(customerID int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins[customerID]
+}
+
+type request struct {
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"param"`
+}
+
+type zoneParams struct {
+	CustomerNumber int              `json:"customernumber"`
+	DomainName     string           `json:"domainname"`
+	DnsRecordSet   *nc.DnsRecordSet `json:"dnsrecordset"`
+	DnsZone        *nc.DnsZoneData  `json:"dnszone"`
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var params zoneParams
+	_ = json.Unmarshal(req.Params, &params)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[req.Action]++
+
+	if s.rateLimited {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if f, ok := s.errors[req.Action]; ok {
+		s.replyMessage(w, req.Action, f.statusCode, f.message, nil)
+		return
+	}
+
+	switch req.Action {
+	case "login":
+		s.logins[params.CustomerNumber]++
+		s.reply(w, req.Action, StatusCodeSuccess, nc.LoginResponseData{ApiSessionId: "session"})
+	case "logout":
+		s.reply(w, req.Action, StatusCodeSuccess, nil)
+	case "infoDnsZone":
+		z, ok := s.zones[params.DomainName]
+		if !ok {
+			s.reply(w, req.Action, StatusCodeUnknownZone, nil)
+			return
+		}
+		s.reply(w, req.Action, StatusCodeSuccess, z.Info)
+	case "updateDnsZone":
+		z, ok := s.zones[params.DomainName]
+		if !ok {
+			s.reply(w, req.Action, StatusCodeUnknownZone, nil)
+			return
+		}
+		if params.DnsZone != nil {
+			z.Info = *params.DnsZone
+			z.Info.DomainName = params.DomainName
+		}
+		s.reply(w, req.Action, StatusCodeSuccess, z.Info)
+	case "infoDnsRecords":
+		z, ok := s.zones[params.DomainName]
+		if !ok {
+			s.reply(w, req.Action, StatusCodeUnknownZone, nil)
+			return
+		}
+		if len(z.Records) == 0 {
+			s.reply(w, req.Action, StatusCodeNoRecords, nil)
+			return
+		}
+		s.reply(w, req.Action, StatusCodeSuccess, nc.InfoDnsRecordsResponseData{DnsRecords: z.Records})
+	case "updateDnsRecords":
+		z, ok := s.zones[params.DomainName]
+		if !ok {
+			s.reply(w, req.Action, StatusCodeUnknownZone, nil)
+			return
+		}
+		if params.DnsRecordSet != nil {
+			s.update(z, params.DnsRecordSet.Content)
+		}
+		s.reply(w, req.Action, StatusCodeSuccess, nc.UpdateDnsRecordsResponseData{DnsRecords: z.Records})
+	default:
+		s.reply(w, req.Action, 4000, nil)
+	}
+}
+
+// update applies a record set the way the CCP API does: records with an ID are
+// modified or deleted, records without an ID are created, and the serial of the zone is increased.
+func (s *Server) update(z *Zone, records []nc.DnsRecord) {
+	if serial, err := strconv.ParseUint(z.Info.Serial, 10, 64); err == nil {
+		z.Info.Serial = strconv.FormatUint(serial+1, 10)
+	}
+	for _, rec := range records {
+		idx := -1
+		for i := range z.Records {
+			if rec.Id != "" && z.Records[i].Id == rec.Id {
+				idx = i
+				break
+			}
+		}
+		switch {
+		case idx >= 0 && rec.DeleteRecord:
+			z.Records = append(z.Records[:idx], z.Records[idx+1:]...)
+		case idx >= 0:
+			z.Records[idx] = rec
+		case rec.DeleteRecord:
+			// deleting an unknown record is a no-op
+		default:
+			rec.Id = strconv.Itoa(s.nextID)
+			s.nextID++
+			z.Records = append(z.Records, rec)
+		}
+	}
+}
+
+func (s *Server) reply(w http.ResponseWriter, action string, statusCode int, data interface{}) {
+	s.replyMessage(w, action, statusCode, "", data)
+}
+
+func (s *Server) replyMessage(w http.ResponseWriter, action string, statusCode int, message string, data interface{}) {
+	status := string(nc.StatusSuccess)
+	if statusCode != StatusCodeSuccess {
+		status = string(nc.StatusError)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"serverrequestid": "server",
+		"clientrequestid": "",
+		"action":          action,
+		"status":          status,
+		"statuscode":      statusCode,
+		"shortmessage":    status,
+		"longmessage":     message,
+		"responsedata":    data,
+	})
+}
@@ -0,0 +1,229 @@
+package netcup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ResourceInventory tells whether the Kubernetes resources named in the resource labels of the TXT
+// registry, e.g. "ingress/default/web", still exist.
+type ResourceInventory interface {
+	// Exists reports whether resource exists. known is false if the inventory cannot tell for the
+	// kind of the resource.
+	Exists(ctx context.Context, resource string) (exists, known bool, err error)
+}
+
+// resourceKindAliases maps the kinds of Kubernetes objects to the kinds of the resource labels that
+// differ from their lowercase name.
+var resourceKindAliases = map[string]string{
+	"dnsendpoint": "crd",
+}
+
+// parseResource splits a resource label into its lowercase kind, namespace and name.
+func parseResource(resource string) (kind, namespace, name string, ok bool) {
+	parts := strings.Split(resource, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	kind = strings.ToLower(parts[0])
+	if alias, ok := resourceKindAliases[kind]; ok {
+		kind = alias
+	}
+	return kind, parts[1], parts[2], true
+}
+
+// FileInventory lists the resources that exist, e.g. exported with kubectl. Only the kinds listed
+// are checked, so resources of kinds that were not exported are never taken for deleted.
+type FileInventory struct {
+	resources map[string]bool
+	kinds     map[string]bool
+}
+
+// ReadInventoryFile reads a file with one resource per line as kind/namespace/name, e.g.
+// Ingress/default/web. Empty lines and lines starting with # are ignored.
+func ReadInventoryFile(path string) (*FileInventory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inventory := &FileInventory{resources: map[string]bool{}, kinds: map[string]bool{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		kind, namespace, name, ok := parseResource(text)
+		if !ok {
+			return nil, fmt.Errorf("invalid resource '%s' in inventory file '%s' line %d, expected kind/namespace/name", text, path, line)
+		}
+		inventory.resources[kind+"/"+namespace+"/"+name] = true
+		inventory.kinds[kind] = true
+	}
+	return inventory, scanner.Err()
+}
+
+// Exists implements ResourceInventory.
+func (i *FileInventory) Exists(_ context.Context, resource string) (bool, bool, error) {
+	kind, namespace, name, ok := parseResource(resource)
+	if !ok || !i.kinds[kind] {
+		return false, false, nil
+	}
+	return i.resources[kind+"/"+namespace+"/"+name], true, nil
+}
+
+// kubernetesResourcePaths are the API paths of the resources of the sources of external-dns by kind.
+var kubernetesResourcePaths = map[string]string{
+	"service":        "api/v1/namespaces/%s/services/%s",
+	"ingress":        "apis/networking.k8s.io/v1/namespaces/%s/ingresses/%s",
+	"crd":            "apis/externaldns.k8s.io/v1alpha1/namespaces/%s/dnsendpoints/%s",
+	"httproute":      "apis/gateway.networking.k8s.io/v1/namespaces/%s/httproutes/%s",
+	"grpcroute":      "apis/gateway.networking.k8s.io/v1/namespaces/%s/grpcroutes/%s",
+	"tlsroute":       "apis/gateway.networking.k8s.io/v1alpha2/namespaces/%s/tlsroutes/%s",
+	"tcproute":       "apis/gateway.networking.k8s.io/v1alpha2/namespaces/%s/tcproutes/%s",
+	"udproute":       "apis/gateway.networking.k8s.io/v1alpha2/namespaces/%s/udproutes/%s",
+	"gateway":        "apis/networking.istio.io/v1beta1/namespaces/%s/gateways/%s",
+	"virtualservice": "apis/networking.istio.io/v1beta1/namespaces/%s/virtualservices/%s",
+}
+
+// KubernetesInventory looks the resources up in the Kubernetes API, which needs get permissions on
+// the resources of the sources of external-dns.
+type KubernetesInventory struct {
+	server    string
+	client    *http.Client
+	tokenFile string
+}
+
+// NewInClusterInventory returns an inventory using the Kubernetes API of the cluster it runs in with
+// the service account of its pod.
+func NewInClusterInventory() (*KubernetesInventory, error) {
+	server, client, _, err := inClusterAPI()
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesInventory{server: server, client: client, tokenFile: serviceAccountDir + "/token"}, nil
+}
+
+// Exists implements ResourceInventory.
+func (k *KubernetesInventory) Exists(ctx context.Context, resource string) (bool, bool, error) {
+	kind, namespace, name, ok := parseResource(resource)
+	apiPath, known := kubernetesResourcePaths[kind]
+	if !ok || !known {
+		return false, false, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.server+"/"+fmt.Sprintf(apiPath, url.PathEscape(namespace), url.PathEscape(name)), nil)
+	if err != nil {
+		return false, true, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.tokenFile != "" {
+		// projected service account tokens are rotated, read the current one
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return false, true, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return false, true, err
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, true, nil
+	case http.StatusNotFound:
+		return false, true, nil
+	default:
+		return false, true, fmt.Errorf("unable to get %s: status %d", resource, resp.StatusCode)
+	}
+}
+
+// Orphan is a record created by external-dns for a Kubernetes resource that no longer exists.
+type Orphan struct {
+	DNSName    string   `json:"dnsName"`
+	RecordType string   `json:"recordType"`
+	Targets    []string `json:"targets"`
+	Owner      string   `json:"owner"`
+	Resource   string   `json:"resource"`
+	// OwnershipRecords are the names of the heritage TXT records to delete along with the record.
+	OwnershipRecords []string `json:"ownershipRecords"`
+}
+
+// FindOrphans lists the records of recordTypes in the managed zones whose heritage TXT record names
+// a resource that inventory reports as deleted, for review before deleting them. Only the records
+// of ownerID are considered if set. Records without a resource label, or whose resource inventory
+// cannot check, are skipped.
+func (p *NetcupProvider) FindOrphans(ctx context.Context, inventory ResourceInventory, recordTypes []string, ownerID string) ([]Orphan, error) {
+	sessions := p.newSessionSet()
+	defer sessions.close()
+
+	exists := map[string]bool{}
+	unchecked := map[string]bool{}
+	var orphans []Orphan
+	for _, zone := range p.managedZones() {
+		cached, err := p.readZone(sessions, zone)
+		if err != nil {
+			return nil, err
+		}
+		endpoints := attachLabels(recordsToEndpoints(zone, p.parseZoneTTL(zone, cached.TTL), cached.Records, p.txtQuoting))
+		txtNames := map[string]bool{}
+		for _, ep := range endpoints {
+			if ep.RecordType == endpoint.RecordTypeTXT && recordOwner(ep) != "" {
+				txtNames[ep.DNSName] = true
+			}
+		}
+
+		for _, ep := range endpoints {
+			if !slices.ContainsFunc(recordTypes, func(t string) bool { return strings.EqualFold(t, ep.RecordType) }) {
+				continue
+			}
+			owner, resource := ep.Labels[endpoint.OwnerLabelKey], ep.Labels[endpoint.ResourceLabelKey]
+			if owner == "" || resource == "" || ownerID != "" && owner != ownerID {
+				continue
+			}
+			found, checked := exists[resource]
+			if !checked && !unchecked[resource] {
+				var known bool
+				found, known, err = inventory.Exists(ctx, resource)
+				if err != nil {
+					return nil, err
+				}
+				if !known {
+					p.logger.Info("unable to check resource, skipping its records", "resource", resource)
+					unchecked[resource] = true
+					continue
+				}
+				exists[resource] = found
+			}
+			if found || unchecked[resource] {
+				continue
+			}
+
+			orphan := Orphan{DNSName: ep.DNSName, RecordType: ep.RecordType, Targets: ep.Targets, Owner: owner, Resource: resource}
+			// external-dns creates no ownership record in the old format for AAAA records
+			names := []string{ep.DNSName, strings.ToLower(ep.RecordType) + "-" + ep.DNSName}
+			if ep.RecordType == endpoint.RecordTypeAAAA {
+				names = names[1:]
+			}
+			for _, name := range names {
+				if txtNames[name] {
+					orphan.OwnershipRecords = append(orphan.OwnershipRecords, name)
+				}
+			}
+			p.logger.Info("found orphaned record", "record", ep.DNSName, "type", ep.RecordType, "resource", resource)
+			orphans = append(orphans, orphan)
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,112 @@
+package netcup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory")
+	assert.NoError(t, os.WriteFile(path, []byte("# exported resources\nIngress/default/web\n\nDNSEndpoint/default/records\n"), 0o600))
+	inventory, err := ReadInventoryFile(path)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		resource      string
+		exists, known bool
+	}{
+		{"ingress/default/web", true, true},
+		{"ingress/default/gone", false, true},
+		{"crd/default/records", true, true},
+		{"service/default/web", false, false},
+		{"invalid", false, false},
+	} {
+		exists, known, err := inventory.Exists(context.TODO(), tc.resource)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.exists, exists, tc.resource)
+		assert.Equal(t, tc.known, known, tc.resource)
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte("web\n"), 0o600))
+	_, err = ReadInventoryFile(path)
+	assert.Error(t, err)
+}
+
+func TestKubernetesInventory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apis/networking.k8s.io/v1/namespaces/default/ingresses/web":
+			_, _ = w.Write([]byte(`{"kind":"Ingress"}`))
+		case "/api/v1/namespaces/kube-system/services/dns":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	inventory := &KubernetesInventory{server: server.URL, client: server.Client()}
+
+	exists, known, err := inventory.Exists(context.TODO(), "ingress/default/web")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, known)
+	exists, known, err = inventory.Exists(context.TODO(), "httproute/default/gone")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.True(t, known)
+	_, known, err = inventory.Exists(context.TODO(), "HTTPProxy/default/web")
+	assert.NoError(t, err)
+	assert.False(t, known)
+	_, _, err = inventory.Exists(context.TODO(), "service/kube-system/dns")
+	assert.Error(t, err)
+}
+
+func TestFindOrphans(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "web", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "web", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=ingress/default/web"`},
+		nc.DnsRecord{Hostname: "a-web", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=ingress/default/web"`},
+		nc.DnsRecord{Hostname: "gone", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "gone", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=ingress/default/gone"`},
+		nc.DnsRecord{Hostname: "a-gone", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=ingress/default/gone"`},
+		nc.DnsRecord{Hostname: "gone", Type: "AAAA", Destination: "::2"},
+		nc.DnsRecord{Hostname: "aaaa-gone", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=ingress/default/gone"`},
+		nc.DnsRecord{Hostname: "other", Type: "A", Destination: "3.3.3.3"},
+		nc.DnsRecord{Hostname: "a-other", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=staging,external-dns/resource=ingress/default/other"`},
+		nc.DnsRecord{Hostname: "svc", Type: "A", Destination: "4.4.4.4"},
+		nc.DnsRecord{Hostname: "a-svc", Type: "TXT", Destination: `"heritage=external-dns,external-dns/owner=cluster,external-dns/resource=service/default/svc"`},
+		nc.DnsRecord{Hostname: "manual", Type: "A", Destination: "5.5.5.5"},
+	)
+	path := filepath.Join(t.TempDir(), "inventory")
+	assert.NoError(t, os.WriteFile(path, []byte("Ingress/default/web\n"), 0o600))
+	inventory, err := ReadInventoryFile(path)
+	assert.NoError(t, err)
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// records of other owners, unchecked kinds and without heritage are left out
+	orphans, err := p.FindOrphans(context.TODO(), inventory, []string{"A", "AAAA"}, "cluster")
+	assert.NoError(t, err)
+	assert.Equal(t, []Orphan{
+		{DNSName: "gone.example.com", RecordType: "A", Targets: []string{"2.2.2.2"}, Owner: "cluster", Resource: "ingress/default/gone", OwnershipRecords: []string{"gone.example.com", "a-gone.example.com"}},
+		{DNSName: "gone.example.com", RecordType: "AAAA", Targets: []string{"::2"}, Owner: "cluster", Resource: "ingress/default/gone", OwnershipRecords: []string{"aaaa-gone.example.com"}},
+	}, orphans)
+
+	orphans, err = p.FindOrphans(context.TODO(), inventory, []string{"A"}, "")
+	assert.NoError(t, err)
+	assert.Len(t, orphans, 2)
+	assert.Len(t, srv.Records("example.com"), 13)
+}
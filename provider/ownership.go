@@ -0,0 +1,116 @@
+package netcup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// WithOwnerID makes the provider refuse to update or delete records owned by another external-dns
+// instance. id must match the --txt-owner-id of external-dns.
+func WithOwnerID(id string) Option {
+	return func(p *NetcupProvider) {
+		p.ownerID = id
+	}
+}
+
+// WithOwnerFilter hides records owned by other external-dns instances from Records, e.g. so the
+// planner of a staging cluster never sees the records of production in a shared zone. Records whose
+// owner is in exclude, or not in include if it is set, are hidden; records without a known owner are
+// always shown.
+func WithOwnerFilter(include, exclude []string) Option {
+	return func(p *NetcupProvider) {
+		p.includeOwners = include
+		p.excludeOwners = exclude
+	}
+}
+
+// ownerFilteredEndpoints drops the endpoints hidden by the owner filter. Endpoints carry the owner
+// of their heritage TXT record once its labels are attached.
+func (p *NetcupProvider) ownerFilteredEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	if len(p.includeOwners) == 0 && len(p.excludeOwners) == 0 {
+		return endpoints
+	}
+	shown := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		owner := recordOwner(ep)
+		if owner != "" && (slices.Contains(p.excludeOwners, owner) || len(p.includeOwners) > 0 && !slices.Contains(p.includeOwners, owner)) {
+			p.logger.Debug("hiding endpoint owned by another external-dns instance", "endpoint", ep.String(), "owner", owner)
+			continue
+		}
+		shown = append(shown, ep)
+	}
+	return shown
+}
+
+// recordOwner returns the owner of ep as recorded by the TXT registry, or an empty string if unknown.
+// Heritage TXT records carry the owner in their target.
+func recordOwner(ep *endpoint.Endpoint) string {
+	if owner := ep.Labels[endpoint.OwnerLabelKey]; owner != "" {
+		return owner
+	}
+	if ep.RecordType == endpoint.RecordTypeTXT && len(ep.Targets) > 0 {
+		if labels, err := endpoint.NewLabelsFromStringPlain(ep.Targets[0]); err == nil {
+			return labels[endpoint.OwnerLabelKey]
+		}
+	}
+	return ""
+}
+
+// checkOwnership drops the updates and deletions of records owned by someone else and returns
+// the remaining changes together with an error describing the conflicts.
+// Updates are checked on their current state and dropped together with the desired one.
+func (p *NetcupProvider) checkOwnership(changes *plan.Changes) (*plan.Changes, error) {
+	if p.ownerID == "" {
+		return changes, nil
+	}
+	var conflicts []string
+	owned := func(ep *endpoint.Endpoint, action string) bool {
+		owner := recordOwner(ep)
+		if owner == "" || owner == p.ownerID {
+			return true
+		}
+		p.logger.Error("refusing to change record owned by another external-dns instance", "action", action, "endpoint", ep.String(), "owner", owner)
+		ownershipConflictsTotal.WithLabelValues(action, ep.RecordType).Inc()
+		conflicts = append(conflicts, fmt.Sprintf("%s %s %s is owned by '%s'", action, ep.RecordType, ep.DNSName, owner))
+		return false
+	}
+
+	checked := &plan.Changes{Create: changes.Create}
+	for _, ep := range changes.Delete {
+		if owned(ep, actionDelete) {
+			checked.Delete = append(checked.Delete, ep)
+		}
+	}
+	if len(changes.UpdateOld) == len(changes.UpdateNew) {
+		for i, ep := range changes.UpdateOld {
+			if owned(ep, actionUpdate) {
+				checked.UpdateOld = append(checked.UpdateOld, ep)
+				checked.UpdateNew = append(checked.UpdateNew, changes.UpdateNew[i])
+			}
+		}
+	} else {
+		// without pairs, drop the desired state of every record whose current state conflicts
+		refused := map[string]bool{}
+		for _, ep := range changes.UpdateOld {
+			if owned(ep, actionUpdate) {
+				checked.UpdateOld = append(checked.UpdateOld, ep)
+			} else {
+				refused[ep.RecordType+" "+ep.DNSName] = true
+			}
+		}
+		for _, ep := range changes.UpdateNew {
+			if !refused[ep.RecordType+" "+ep.DNSName] {
+				checked.UpdateNew = append(checked.UpdateNew, ep)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return checked, fmt.Errorf("ownership conflict, expected owner '%s': %s", p.ownerID, strings.Join(conflicts, "; "))
+	}
+	return checked, nil
+}
@@ -0,0 +1,104 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func ownedEndpoint(name, recordType, target, owner string) *endpoint.Endpoint {
+	ep := endpoint.NewEndpoint(name, recordType, target)
+	ep.Labels[endpoint.OwnerLabelKey] = owner
+	return ep
+}
+
+func TestRecordOwner(t *testing.T) {
+	assert.Equal(t, "", recordOwner(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")))
+	assert.Equal(t, "a", recordOwner(ownedEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "a")))
+	assert.Equal(t, "b", recordOwner(endpoint.NewEndpoint("a-www.example.com", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=b,external-dns/resource=service/default/www\"")))
+	assert.Equal(t, "", recordOwner(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeTXT, "v=spf1 -all")))
+}
+
+func TestOwnershipConflicts(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "mine", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "theirs", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "a-theirs", Type: "TXT", Destination: "heritage=external-dns,external-dns/owner=other"},
+	)
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithOwnerID("default"))
+	assert.NoError(t, err)
+
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			ownedEndpoint("mine.example.com", endpoint.RecordTypeA, "1.1.1.1", "default"),
+			ownedEndpoint("theirs.example.com", endpoint.RecordTypeA, "2.2.2.2", "other"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			ownedEndpoint("mine.example.com", endpoint.RecordTypeA, "3.3.3.3", "default"),
+			ownedEndpoint("theirs.example.com", endpoint.RecordTypeA, "4.4.4.4", "default"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("a-theirs.example.com", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=other\""),
+		},
+	})
+	assert.ErrorContains(t, err, "update A theirs.example.com is owned by 'other'")
+	assert.ErrorContains(t, err, "delete TXT a-theirs.example.com is owned by 'other'")
+
+	destinations := map[string]string{}
+	for _, rec := range srv.Records("example.com") {
+		destinations[rec.Hostname] = rec.Destination
+	}
+	assert.Equal(t, map[string]string{
+		"mine":     "3.3.3.3",
+		"theirs":   "2.2.2.2",
+		"a-theirs": "heritage=external-dns,external-dns/owner=other",
+	}, destinations)
+}
+
+func TestOwnerFilter(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "static", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "staging", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "a-staging", Type: "TXT", Destination: "heritage=external-dns,external-dns/owner=staging"},
+		nc.DnsRecord{Hostname: "prod", Type: "A", Destination: "3.3.3.3"},
+		nc.DnsRecord{Hostname: "a-prod", Type: "TXT", Destination: "heritage=external-dns,external-dns/owner=prod"},
+	)
+
+	names := func(endpoints []*endpoint.Endpoint) []string {
+		var names []string
+		for _, ep := range endpoints {
+			names = append(names, ep.DNSName)
+		}
+		return names
+	}
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	for _, tc := range []struct {
+		name             string
+		include, exclude []string
+		expected         []string
+	}{
+		{"none", nil, nil, []string{"static.example.com", "staging.example.com", "a-staging.example.com", "prod.example.com", "a-prod.example.com"}},
+		{"include", []string{"staging"}, nil, []string{"static.example.com", "staging.example.com", "a-staging.example.com"}},
+		{"exclude", nil, []string{"prod"}, []string{"static.example.com", "staging.example.com", "a-staging.example.com"}},
+	} {
+		p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithOwnerFilter(tc.include, tc.exclude))
+		assert.NoError(t, err, tc.name)
+		records, err := p.Records(context.TODO())
+		assert.NoError(t, err, tc.name)
+		assert.ElementsMatch(t, tc.expected, names(records), tc.name)
+	}
+}
@@ -0,0 +1,110 @@
+package netcup
+
+import (
+	"context"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// endpointStage transforms endpoints, e.g. dropping or annotating some of them. Stages may modify
+// the endpoints they are given.
+type endpointStage func([]*endpoint.Endpoint) []*endpoint.Endpoint
+
+// runEndpointStages passes endpoints through stages in order.
+func runEndpointStages(endpoints []*endpoint.Endpoint, stages ...endpointStage) []*endpoint.Endpoint {
+	for _, stage := range stages {
+		endpoints = stage(endpoints)
+	}
+	return endpoints
+}
+
+// recordStages are applied to the endpoints of every zone read from Netcup before they are passed to
+// external-dns.
+func (p *NetcupProvider) recordStages() []endpointStage {
+	return []endpointStage{
+		p.revertedEndpoints,
+		attachLabels,
+		p.ownerFilteredEndpoints,
+		p.managedEndpoints,
+		p.reportRequestedTTLs,
+	}
+}
+
+// adjustStages are applied to the endpoints external-dns asks to adjust before planning.
+func (p *NetcupProvider) adjustStages() []endpointStage {
+	return []endpointStage{
+		p.supportedEndpoints,
+		p.managedEndpoints,
+		p.adjustRequestedTTLs,
+	}
+}
+
+// changeStage transforms the changes of external-dns before they are applied. Stages return the
+// changes to pass on and must not modify the changes or endpoints they are given.
+type changeStage struct {
+	name  string
+	apply func(context.Context, *plan.Changes) *plan.Changes
+}
+
+// changeStages are applied to the changes of external-dns in order. The changes are then converted
+// to the records of each zone, quoting TXT values, and passed through the zoneChangeStages.
+func (p *NetcupProvider) changeStages() []changeStage {
+	return []changeStage{
+		{"name rewrites", func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			return &plan.Changes{
+				Create:    p.rewrittenEndpoints(changes.Create),
+				UpdateOld: p.rewrittenEndpoints(changes.UpdateOld),
+				UpdateNew: p.rewrittenEndpoints(changes.UpdateNew),
+				Delete:    p.rewrittenEndpoints(changes.Delete),
+			}
+		}},
+		{"managed record types", func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			return &plan.Changes{
+				Create:    p.managedEndpoints(changes.Create),
+				UpdateOld: p.managedEndpoints(changes.UpdateOld),
+				UpdateNew: p.managedEndpoints(changes.UpdateNew),
+				Delete:    p.managedEndpoints(changes.Delete),
+			}
+		}},
+		{"requested TTLs", func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			return p.withoutTTLOnlyUpdates(changes)
+		}},
+		{"policy", func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			return p.policy.filter(changes, p.logger)
+		}},
+		{"protected deletes", func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			return p.withoutDeletes(changes)
+		}},
+		{"target health", p.withHealthyTargets},
+	}
+}
+
+// runChangeStages passes changes through stages in order and returns nil once a stage leaves no
+// changes.
+func (p *NetcupProvider) runChangeStages(ctx context.Context, changes *plan.Changes, stages ...changeStage) *plan.Changes {
+	for _, stage := range stages {
+		changes = stage.apply(ctx, changes)
+		if !changes.HasChanges() {
+			p.logger.Debug("no changes left - nothing to do", "stage", stage.name)
+			return nil
+		}
+	}
+	return changes
+}
+
+// zoneChangeStage transforms the records to change in a zone, whose current records are recs.
+type zoneChangeStage func(zoneName string, change *NetcupChange, recs []nc.DnsRecord)
+
+// zoneChangeStages are applied to the records to change in each zone in order.
+func (p *NetcupProvider) zoneChangeStages() []zoneChangeStage {
+	return []zoneChangeStage{
+		func(_ string, change *NetcupChange, _ []nc.DnsRecord) {
+			mergeUpdates(change, p.txtQuoting)
+		},
+		func(zoneName string, change *NetcupChange, recs []nc.DnsRecord) {
+			p.companions.add(change, zoneName, recs, p.txtQuoting, p.logger)
+		},
+	}
+}
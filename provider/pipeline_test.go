@@ -0,0 +1,78 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRunChangeStages(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+
+	www := endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1")
+	app := endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "1.1.1.1")
+	var order []string
+	stage := func(name string, keep func(*endpoint.Endpoint) bool) changeStage {
+		return changeStage{name, func(_ context.Context, changes *plan.Changes) *plan.Changes {
+			order = append(order, name)
+			filtered := &plan.Changes{}
+			for _, ep := range changes.Create {
+				if keep(ep) {
+					filtered.Create = append(filtered.Create, ep)
+				}
+			}
+			return filtered
+		}}
+	}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{www, app}}
+
+	// stages run in order on the output of the previous one
+	got := p.runChangeStages(context.TODO(), changes,
+		stage("first", func(ep *endpoint.Endpoint) bool { return true }),
+		stage("second", func(ep *endpoint.Endpoint) bool { return ep != app }),
+	)
+	assert.Equal(t, &plan.Changes{Create: []*endpoint.Endpoint{www}}, got)
+	assert.Equal(t, []string{"first", "second"}, order)
+
+	// a stage leaving no changes stops the pipeline
+	order = nil
+	got = p.runChangeStages(context.TODO(), changes,
+		stage("none", func(ep *endpoint.Endpoint) bool { return false }),
+		stage("skipped", func(ep *endpoint.Endpoint) bool { return true }),
+	)
+	assert.Nil(t, got)
+	assert.Equal(t, []string{"none"}, order)
+}
+
+func TestEndpointStages(t *testing.T) {
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}), WithManagedRecordTypes([]string{endpoint.RecordTypeA}))
+	assert.NoError(t, err)
+
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 600, "1.1.1.1"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "example.com"),
+		endpoint.NewEndpoint("www.example.com", "NAPTR", `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`),
+	}
+	adjusted := runEndpointStages(endpoints, p.adjustStages()...)
+	if assert.Len(t, adjusted, 1) {
+		ttl, _ := adjusted[0].GetProviderSpecificProperty(requestedTTLProperty)
+		assert.Equal(t, "600", ttl)
+	}
+
+	// records read back report the requested TTL
+	read := runEndpointStages([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 300, "1.1.1.1"),
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeCNAME, "example.com"),
+	}, p.recordStages()...)
+	if assert.Len(t, read, 1) {
+		ttl, _ := read[0].GetProviderSpecificProperty(requestedTTLProperty)
+		assert.Equal(t, "600", ttl)
+	}
+}
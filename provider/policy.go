@@ -0,0 +1,161 @@
+package netcup
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// PolicyRule matches changes by hostname glob, record type and action.
+// Empty fields match everything.
+type PolicyRule struct {
+	Hostname    string   `json:"hostname"`
+	RecordTypes []string `json:"recordTypes"`
+	Actions     []string `json:"actions"`
+	Effect      string   `json:"effect"`
+}
+
+// Policy decides which changes may be applied. The first matching rule wins; if no rule
+// matches, Default applies.
+type Policy struct {
+	Default string       `json:"default"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// ReadPolicyFile reads a YAML or JSON policy file.
+func ReadPolicyFile(file string) (*Policy, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(content, policy); err != nil {
+		return nil, fmt.Errorf("unable to parse policy file '%s': %v", file, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file '%s': %v", file, err)
+	}
+	return policy, nil
+}
+
+// WithPolicy evaluates policy before every change.
+func WithPolicy(policy *Policy) Option {
+	return func(p *NetcupProvider) {
+		p.policy = policy
+	}
+}
+
+func (pol *Policy) validate() error {
+	if pol.Default == "" {
+		pol.Default = policyAllow
+	}
+	if pol.Default != policyAllow && pol.Default != policyDeny {
+		return fmt.Errorf("default must be '%s' or '%s', got '%s'", policyAllow, policyDeny, pol.Default)
+	}
+	for i, rule := range pol.Rules {
+		if rule.Effect != policyAllow && rule.Effect != policyDeny {
+			return fmt.Errorf("rule %d: effect must be '%s' or '%s', got '%s'", i, policyAllow, policyDeny, rule.Effect)
+		}
+		if _, err := path.Match(rule.Hostname, ""); err != nil {
+			return fmt.Errorf("rule %d: invalid hostname glob '%s': %v", i, rule.Hostname, err)
+		}
+		for _, action := range rule.Actions {
+			if action != actionCreate && action != actionUpdate && action != actionDelete {
+				return fmt.Errorf("rule %d: unknown action '%s'", i, action)
+			}
+		}
+	}
+	return nil
+}
+
+// allows reports whether action may be applied to ep.
+func (pol *Policy) allows(ep *endpoint.Endpoint, action string) bool {
+	if pol == nil {
+		return true
+	}
+	for _, rule := range pol.Rules {
+		if rule.matches(ep, action) {
+			return rule.Effect == policyAllow
+		}
+	}
+	return pol.Default != policyDeny
+}
+
+func (r PolicyRule) matches(ep *endpoint.Endpoint, action string) bool {
+	if r.Hostname != "" {
+		if ok, _ := path.Match(strings.ToLower(r.Hostname), strings.ToLower(ep.DNSName)); !ok {
+			return false
+		}
+	}
+	if len(r.RecordTypes) > 0 && !slices.ContainsFunc(r.RecordTypes, func(t string) bool { return strings.EqualFold(t, ep.RecordType) }) {
+		return false
+	}
+	if len(r.Actions) > 0 && !slices.Contains(r.Actions, action) {
+		return false
+	}
+	return true
+}
+
+// filter returns the changes allowed by the policy. Denied changes are logged and counted.
+// Updates are evaluated on the desired endpoint and dropped together with their current state.
+func (pol *Policy) filter(changes *plan.Changes, logger *slog.Logger) *plan.Changes {
+	if pol == nil {
+		return changes
+	}
+	allowed := func(ep *endpoint.Endpoint, action string) bool {
+		if pol.allows(ep, action) {
+			return true
+		}
+		logger.Warn("change denied by policy", "action", action, "endpoint", ep.String())
+		policyDeniedChangesTotal.WithLabelValues(action, ep.RecordType).Inc()
+		return false
+	}
+
+	filtered := &plan.Changes{}
+	for _, ep := range changes.Create {
+		if allowed(ep, actionCreate) {
+			filtered.Create = append(filtered.Create, ep)
+		}
+	}
+	for _, ep := range changes.Delete {
+		if allowed(ep, actionDelete) {
+			filtered.Delete = append(filtered.Delete, ep)
+		}
+	}
+	if len(changes.UpdateOld) == len(changes.UpdateNew) {
+		for i, ep := range changes.UpdateNew {
+			if allowed(ep, actionUpdate) {
+				filtered.UpdateOld = append(filtered.UpdateOld, changes.UpdateOld[i])
+				filtered.UpdateNew = append(filtered.UpdateNew, ep)
+			}
+		}
+	} else {
+		for _, ep := range changes.UpdateOld {
+			if allowed(ep, actionUpdate) {
+				filtered.UpdateOld = append(filtered.UpdateOld, ep)
+			}
+		}
+		for _, ep := range changes.UpdateNew {
+			if allowed(ep, actionUpdate) {
+				filtered.UpdateNew = append(filtered.UpdateNew, ep)
+			}
+		}
+	}
+	return filtered
+}
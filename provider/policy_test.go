@@ -0,0 +1,86 @@
+package netcup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("ReadFile", testReadPolicyFile)
+	t.Run("Allows", testPolicyAllows)
+	t.Run("Filter", testPolicyFilter)
+}
+
+func testReadPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	content := "default: deny\nrules:\n- hostname: '*.example.com'\n  recordTypes: [A]\n  effect: allow\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	policy, err := ReadPolicyFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &Policy{
+		Default: policyDeny,
+		Rules:   []PolicyRule{{Hostname: "*.example.com", RecordTypes: []string{"A"}, Effect: policyAllow}},
+	}, policy)
+
+	for _, invalid := range []string{
+		"default: maybe\n",
+		"rules:\n- effect: permit\n",
+		"rules:\n- effect: deny\n  actions: [upsert]\n",
+		"rules:\n- effect: deny\n  hostname: '[a'\n",
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(invalid), 0o600))
+		_, err := ReadPolicyFile(path)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func testPolicyAllows(t *testing.T) {
+	policy := &Policy{
+		Default: policyAllow,
+		Rules: []PolicyRule{
+			{Hostname: "*.prod.example.com", Actions: []string{actionDelete}, Effect: policyDeny},
+			{RecordTypes: []string{"mx"}, Effect: policyDeny},
+		},
+	}
+	assert.NoError(t, policy.validate())
+
+	prod := endpoint.NewEndpoint("api.prod.example.com", endpoint.RecordTypeA, "1.2.3.4")
+	assert.False(t, policy.allows(prod, actionDelete))
+	assert.True(t, policy.allows(prod, actionCreate))
+	assert.False(t, policy.allows(endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mx.example.com"), actionCreate))
+	assert.True(t, policy.allows(endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.2.3.4"), actionDelete))
+
+	var nilPolicy *Policy
+	assert.True(t, nilPolicy.allows(prod, actionDelete))
+}
+
+func testPolicyFilter(t *testing.T) {
+	policy := &Policy{
+		Default: policyAllow,
+		Rules:   []PolicyRule{{Hostname: "*.prod.example.com", Effect: policyDeny}},
+	}
+	logger := promslog.New(&promslog.Config{})
+
+	prodOld := endpoint.NewEndpoint("api.prod.example.com", endpoint.RecordTypeA, "1.2.3.4")
+	prodNew := endpoint.NewEndpoint("api.prod.example.com", endpoint.RecordTypeA, "5.6.7.8")
+	devOld := endpoint.NewEndpoint("api.dev.example.com", endpoint.RecordTypeA, "1.2.3.4")
+	devNew := endpoint.NewEndpoint("api.dev.example.com", endpoint.RecordTypeA, "5.6.7.8")
+
+	filtered := policy.filter(&plan.Changes{
+		Create:    []*endpoint.Endpoint{prodNew, devNew},
+		UpdateOld: []*endpoint.Endpoint{prodOld, devOld},
+		UpdateNew: []*endpoint.Endpoint{prodNew, devNew},
+		Delete:    []*endpoint.Endpoint{prodOld},
+	}, logger)
+	assert.Equal(t, &plan.Changes{
+		Create:    []*endpoint.Endpoint{devNew},
+		UpdateOld: []*endpoint.Endpoint{devOld},
+		UpdateNew: []*endpoint.Endpoint{devNew},
+	}, filtered)
+}
@@ -0,0 +1,65 @@
+package netcup
+
+import (
+	"slices"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// WithProtectDeletes keeps all records external-dns wants to delete or replace, e.g. while adopting
+// external-dns on a zone with existing records. Creates and updates that only add targets are applied.
+func WithProtectDeletes() Option {
+	return func(p *NetcupProvider) {
+		p.protectDeletes = true
+	}
+}
+
+// withoutDeletes drops the deletes of changes and the targets updates would remove, logging and
+// counting them. Updates keep their current targets in addition to the desired ones; updates of
+// CNAME records, which cannot have more than one target, are dropped instead.
+func (p *NetcupProvider) withoutDeletes(changes *plan.Changes) *plan.Changes {
+	if !p.protectDeletes {
+		return changes
+	}
+	protected := func(ep *endpoint.Endpoint, action string) {
+		p.logger.Warn("keeping record, deletes are protected", "action", action, "endpoint", ep.String())
+		protectedDeletesTotal.WithLabelValues(action, ep.RecordType).Inc()
+	}
+
+	for _, ep := range changes.Delete {
+		protected(ep, actionDelete)
+	}
+	filtered := &plan.Changes{Create: changes.Create}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		// the current state of the updates is unknown
+		for _, ep := range changes.UpdateOld {
+			protected(ep, actionUpdate)
+		}
+		return filtered
+	}
+	for i, old := range changes.UpdateOld {
+		updated := changes.UpdateNew[i]
+		var removed endpoint.Targets
+		for _, target := range old.Targets {
+			if !slices.ContainsFunc(updated.Targets, func(t string) bool {
+				return p.txtQuoting.sameDestination(old.RecordType, t, target)
+			}) {
+				removed = append(removed, target)
+			}
+		}
+		switch {
+		case len(removed) == 0:
+		case old.RecordType == endpoint.RecordTypeCNAME:
+			protected(old, actionUpdate)
+			continue
+		default:
+			protected(&endpoint.Endpoint{DNSName: old.DNSName, RecordType: old.RecordType, Targets: removed}, actionUpdate)
+			updated = updated.DeepCopy()
+			updated.Targets = append(updated.Targets, removed...)
+		}
+		filtered.UpdateOld = append(filtered.UpdateOld, old)
+		filtered.UpdateNew = append(filtered.UpdateNew, updated)
+	}
+	return filtered
+}
@@ -0,0 +1,60 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestProtectDeletes(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "old", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "app", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "alias", Type: "CNAME", Destination: "www.example.com"},
+	)
+	protectedDeletesTotal.Reset()
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithProtectDeletes())
+	assert.NoError(t, err)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "3.3.3.3")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "1.1.1.1")},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("alias.example.com", endpoint.RecordTypeCNAME, "www.example.com"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"),
+			endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2"),
+			endpoint.NewEndpoint("alias.example.com", endpoint.RecordTypeCNAME, "app.example.com"),
+		},
+	}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+
+	destinations := map[string][]string{}
+	for _, rec := range srv.Records("example.com") {
+		destinations[rec.Hostname] = append(destinations[rec.Hostname], rec.Destination)
+	}
+	assert.ElementsMatch(t, []string{"3.3.3.3"}, destinations["new"])
+	assert.ElementsMatch(t, []string{"1.1.1.1"}, destinations["old"], "delete is skipped")
+	assert.ElementsMatch(t, []string{"1.1.1.1", "2.2.2.2"}, destinations["www"], "additive update is applied")
+	assert.ElementsMatch(t, []string{"1.1.1.1", "2.2.2.2"}, destinations["app"], "replaced target is kept")
+	assert.ElementsMatch(t, []string{"www.example.com"}, destinations["alias"], "CNAME is not replaced")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(protectedDeletesTotal.WithLabelValues(actionDelete, endpoint.RecordTypeA)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(protectedDeletesTotal.WithLabelValues(actionUpdate, endpoint.RecordTypeA)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(protectedDeletesTotal.WithLabelValues(actionUpdate, endpoint.RecordTypeCNAME)))
+}
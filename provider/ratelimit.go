@@ -0,0 +1,64 @@
+package netcup
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// defaultRateLimitCooldown is the time the provider stays away from the API after being rate limited.
+const defaultRateLimitCooldown = time.Minute
+
+// WithRateLimitCooldown sets how long the provider waits after being rate limited before calling the
+// API again. Up to a fifth of the cooldown is added as jitter.
+func WithRateLimitCooldown(cooldown time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.rateLimitCooldown = cooldown
+	}
+}
+
+// rateLimited returns an ErrRateLimited error while the cooldown after being rate limited lasts.
+func (p *NetcupProvider) rateLimited() error {
+	p.rateLimitMu.Lock()
+	defer p.rateLimitMu.Unlock()
+	remaining := time.Until(p.rateLimitedUntil)
+	if remaining <= 0 {
+		return nil
+	}
+	return &APIError{
+		Kind:       ErrRateLimited,
+		Err:        errors.New("waiting for the rate limit cooldown to pass"),
+		RetryAfter: remaining,
+	}
+}
+
+// apiError classifies err of an API call and starts the cooldown if the API rate limited the provider.
+func (p *NetcupProvider) apiError(err error, login bool) error {
+	err = classifyError(err, login)
+	p.recordAPIError(err)
+	if errors.Is(err, ErrMaintenance) {
+		p.enterMaintenance(err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrRateLimited || apiErr.RetryAfter > 0 {
+		return err
+	}
+
+	cooldown := p.rateLimitCooldown
+	if cooldown > 0 {
+		cooldown += rand.N(cooldown/5 + 1)
+	}
+	apiErr.RetryAfter = cooldown
+
+	p.rateLimitMu.Lock()
+	p.rateLimitedUntil = time.Now().Add(cooldown)
+	p.rateLimitMu.Unlock()
+	p.logger.Warn("rate limited by the Netcup API, pausing API calls", "cooldown", cooldown.String())
+	rateLimited.Set(1)
+	time.AfterFunc(cooldown, func() {
+		if p.rateLimited() == nil {
+			rateLimited.Set(0)
+		}
+	})
+	return err
+}
@@ -0,0 +1,48 @@
+package netcup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+func TestRateLimitCooldown(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithRateLimitCooldown(200*time.Millisecond))
+	assert.NoError(t, err)
+
+	srv.RateLimit(true)
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.ErrorIs(t, err, provider.SoftError)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.GreaterOrEqual(t, apiErr.RetryAfter, 200*time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rateLimited))
+
+	// no API calls during the cooldown
+	srv.RateLimit(false)
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, 1, srv.Calls("login"))
+
+	assert.Eventually(t, func() bool {
+		_, err := p.Records(context.TODO())
+		return err == nil
+	}, 2*time.Second, 50*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(rateLimited) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,63 @@
+package netcup
+
+import (
+	"strings"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// recordIDKey identifies the records of a zone by type, hostname and normalized destination.
+type recordIDKey struct {
+	recordType  string
+	hostname    string
+	destination string
+}
+
+// recordIDs indexes the records of a zone, so the IDs of the records to update or delete are
+// found without scanning the zone for every target. It matches like TXTQuoting.sameDestination.
+type recordIDs struct {
+	recs    []nc.DnsRecord
+	index   map[recordIDKey]int
+	quoting TXTQuoting
+}
+
+// newRecordIDs indexes recs. Of several records matching a target, the first one wins.
+func newRecordIDs(recs []nc.DnsRecord, quoting TXTQuoting) *recordIDs {
+	ids := &recordIDs{recs: recs, index: make(map[recordIDKey]int, len(recs)), quoting: quoting}
+	for i, rec := range recs {
+		ids.add(recordIDKey{rec.Type, rec.Hostname, ids.normalize(rec.Type, rec.Destination)}, i)
+		// a TXT destination also matches if it is the value written for the target
+		if rec.Type == endpoint.RecordTypeTXT {
+			ids.add(recordIDKey{rec.Type, rec.Hostname, rec.Destination}, i)
+		}
+	}
+	return ids
+}
+
+func (ids *recordIDs) add(key recordIDKey, i int) {
+	if j, ok := ids.index[key]; !ok || i < j {
+		ids.index[key] = i
+	}
+}
+
+// normalize returns the form of a destination of recordType that equal destinations share.
+func (ids *recordIDs) normalize(recordType, destination string) string {
+	switch {
+	case recordType == endpoint.RecordTypeTXT:
+		return ids.quoting.text(destination)
+	case opaqueRecordType(recordType):
+		return destination
+	}
+	return strings.TrimSuffix(destination, ".")
+}
+
+// id returns the ID of the record with recordName, target and recordType, or "" if the zone has none,
+// to ensure only existing records are updated or removed.
+func (ids *recordIDs) id(recordName string, target string, recordType string) string {
+	i, ok := ids.index[recordIDKey{recordType, recordName, ids.normalize(recordType, target)}]
+	if !ok {
+		return ""
+	}
+	return ids.recs[i].Id
+}
@@ -0,0 +1,140 @@
+package netcup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// supportedRecordTypes are the record types the Netcup DNS API supports.
+var supportedRecordTypes = []string{
+	endpoint.RecordTypeA,
+	endpoint.RecordTypeAAAA,
+	"CAA",
+	endpoint.RecordTypeCNAME,
+	"DS",
+	endpoint.RecordTypeMX,
+	endpoint.RecordTypeNS,
+	endpoint.RecordTypePTR,
+	endpoint.RecordTypeSRV,
+	"SSHFP",
+	"TLSA",
+	endpoint.RecordTypeTXT,
+}
+
+// WithManagedRecordTypes limits the records read and changed by the provider to the given types.
+// TXT records are always managed since external-dns keeps its ownership information in them.
+func WithManagedRecordTypes(types []string) Option {
+	return func(p *NetcupProvider) {
+		p.recordTypes = []string{endpoint.RecordTypeTXT}
+		for _, t := range types {
+			t = strings.ToUpper(t)
+			if !slices.Contains(p.recordTypes, t) {
+				p.recordTypes = append(p.recordTypes, t)
+			}
+		}
+		slices.Sort(p.recordTypes)
+	}
+}
+
+// WithExtraRecordTypes adds record types the Netcup API accepts in addition to the supported ones,
+// e.g. HTTPS or SVCB. Their values are passed through without interpretation.
+func WithExtraRecordTypes(types []string) Option {
+	return func(p *NetcupProvider) {
+		for _, t := range types {
+			t = strings.ToUpper(t)
+			if !slices.Contains(supportedRecordTypes, t) && !slices.Contains(p.extraRecordTypes, t) {
+				p.extraRecordTypes = append(p.extraRecordTypes, t)
+			}
+		}
+	}
+}
+
+// supportedTypes returns the supported record types including the extra ones.
+func (p *NetcupProvider) supportedTypes() []string {
+	types := slices.Concat(supportedRecordTypes, p.extraRecordTypes)
+	slices.Sort(types)
+	return types
+}
+
+// opaqueRecordType reports whether the values of recordType are passed through unchanged, as for
+// types enabled with WithExtraRecordTypes.
+func opaqueRecordType(recordType string) bool {
+	return !slices.Contains(supportedRecordTypes, recordType)
+}
+
+// validateRecordTypes checks that all managed record types are supported by Netcup.
+func (p *NetcupProvider) validateRecordTypes() error {
+	for _, t := range p.recordTypes {
+		if !slices.Contains(p.supportedTypes(), t) {
+			return fmt.Errorf("record type '%s' is not supported by Netcup, supported types are %s", t, strings.Join(p.supportedTypes(), ", "))
+		}
+	}
+	return nil
+}
+
+// ManagedRecordTypes returns the record types managed by the provider.
+func (p *NetcupProvider) ManagedRecordTypes() []string {
+	if p.recordTypes == nil {
+		return p.supportedTypes()
+	}
+	return slices.Clone(p.recordTypes)
+}
+
+// managesRecordType reports whether records of recordType are managed by the provider.
+func (p *NetcupProvider) managesRecordType(recordType string) bool {
+	if p.recordTypes == nil {
+		return slices.Contains(p.supportedTypes(), recordType)
+	}
+	return slices.Contains(p.recordTypes, recordType)
+}
+
+// AdjustEndpoints drops the desired endpoints of record types the provider does not manage, so
+// external-dns never plans changes for them, and moves the TTLs into the requested TTL property.
+func (p *NetcupProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return runEndpointStages(endpoints, p.adjustStages()...), nil
+}
+
+// managedEndpoints returns the endpoints of managed record types and logs the other ones.
+func (p *NetcupProvider) managedEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	if p.recordTypes == nil {
+		return endpoints
+	}
+	managed := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if p.managesRecordType(ep.RecordType) {
+			managed = append(managed, ep)
+			continue
+		}
+		p.logger.Debug("ignoring endpoint of unmanaged record type", "endpoint", ep.String())
+	}
+	return managed
+}
+
+// supportedEndpoints drops the endpoints of record types Netcup does not support, e.g. NAPTR or
+// HTTPS. Every unsupported type is logged once, as external-dns asks again in every sync.
+func (p *NetcupProvider) supportedEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	supported := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if slices.Contains(p.supportedTypes(), ep.RecordType) {
+			supported = append(supported, ep)
+			continue
+		}
+		unsupportedEndpointsTotal.WithLabelValues(ep.RecordType).Inc()
+		p.unsupportedTypesMu.Lock()
+		_, logged := p.unsupportedTypes[ep.RecordType]
+		if !logged {
+			if p.unsupportedTypes == nil {
+				p.unsupportedTypes = map[string]struct{}{}
+			}
+			p.unsupportedTypes[ep.RecordType] = struct{}{}
+		}
+		p.unsupportedTypesMu.Unlock()
+		if !logged {
+			p.logger.Warn("dropping endpoints of record type not supported by Netcup", "record_type", ep.RecordType, "endpoint", ep.String(), "supported", strings.Join(p.supportedTypes(), ","))
+		}
+	}
+	return supported
+}
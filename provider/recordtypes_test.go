@@ -0,0 +1,109 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestManagedRecordTypes(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "@", Type: "NS", Destination: "root-dns.netcup.net"},
+		nc.DnsRecord{Hostname: "a-www", Type: "TXT", Destination: "heritage=external-dns"},
+	)
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	_, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithManagedRecordTypes([]string{"A", "SPF"}))
+	assert.ErrorContains(t, err, "record type 'SPF' is not supported")
+
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, supportedRecordTypes, p.ManagedRecordTypes())
+
+	p, err = NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithManagedRecordTypes([]string{"a", "CNAME"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A", "CNAME", "TXT"}, p.ManagedRecordTypes())
+
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	var types []string
+	for _, ep := range records {
+		types = append(types, ep.RecordType)
+	}
+	assert.ElementsMatch(t, []string{"A", "TXT"}, types)
+
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeMX, "10 mx.example.com"),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, adjusted, 1)
+	assert.Equal(t, endpoint.RecordTypeA, adjusted[0].RecordType)
+}
+
+func TestAdjustEndpointsDropsUnsupportedTypes(t *testing.T) {
+	unsupportedEndpointsTotal.Reset()
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+
+	desired := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeNAPTR, `100 10 "S" "SIP+D2U" "" _sip._udp.example.com`),
+		endpoint.NewEndpoint("svc.example.com", "HTTPS", "1 . alpn=h2"),
+	}
+	for range 2 {
+		adjusted, err := p.AdjustEndpoints(desired)
+		assert.NoError(t, err)
+		assert.Len(t, adjusted, 1)
+		assert.Equal(t, endpoint.RecordTypeA, adjusted[0].RecordType)
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(unsupportedEndpointsTotal.WithLabelValues(endpoint.RecordTypeNAPTR)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(unsupportedEndpointsTotal.WithLabelValues("HTTPS")))
+	assert.Len(t, p.unsupportedTypes, 2)
+}
+
+func TestExtraRecordTypes(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Id: "1", Hostname: "svc", Type: "HTTPS", Destination: "0 alias.example.com."})
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	_, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithManagedRecordTypes([]string{"A", "SVCB"}))
+	assert.ErrorContains(t, err, "record type 'SVCB' is not supported")
+
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithExtraRecordTypes([]string{"https", "SVCB", "A"}))
+	assert.NoError(t, err)
+	assert.Contains(t, p.ManagedRecordTypes(), "HTTPS")
+	assert.Contains(t, p.ManagedRecordTypes(), "SVCB")
+	assert.Len(t, p.ManagedRecordTypes(), len(supportedRecordTypes)+2)
+
+	// values are passed through unchanged, including the trailing dot
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, endpoint.Targets{"0 alias.example.com."}, records[0].Targets)
+
+	desired := endpoint.NewEndpoint("svc.example.com", "HTTPS", "0 alias.example.com.")
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{desired})
+	assert.NoError(t, err)
+	assert.Len(t, adjusted, 1)
+
+	updated := endpoint.NewEndpoint("svc.example.com", "HTTPS", "1 . alpn=h2")
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: records, UpdateNew: []*endpoint.Endpoint{updated}}))
+	assert.Equal(t, []nc.DnsRecord{{Id: "1", Hostname: "svc", Type: "HTTPS", Destination: "1 . alpn=h2"}}, srv.Records("example.com"))
+}
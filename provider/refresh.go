@@ -0,0 +1,17 @@
+package netcup
+
+import "time"
+
+// WithMinZoneRefreshInterval reads every zone from the Netcup API at most once per interval and
+// answers the polls in between from memory, unless a record cache with a longer TTL is configured.
+// Zones are still read again right after changes were applied to them.
+func WithMinZoneRefreshInterval(interval time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.minRefreshInterval = interval
+	}
+}
+
+// refreshTTL is the age up to which cached zones are served.
+func (p *NetcupProvider) refreshTTL() time.Duration {
+	return max(p.cacheTTL, p.minRefreshInterval)
+}
@@ -0,0 +1,48 @@
+package netcup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestMinZoneRefreshInterval(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithMinZoneRefreshInterval(time.Hour))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			records, err := p.Records(context.TODO())
+			assert.NoError(t, err)
+			assert.Len(t, records, 1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Calls("infoDnsRecords"))
+
+	// changes are visible to the next poll
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")},
+	}))
+	calls := srv.Calls("infoDnsRecords")
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, calls+1, srv.Calls("infoDnsRecords"))
+}
@@ -0,0 +1,50 @@
+package netcup
+
+import "maps"
+
+// TakeOver prepares p to replace old after a configuration reload. p waits for the rate limit
+// cooldown of old to pass and keeps the requested TTLs of old, and the metrics of zones no longer
+// managed are removed.
+func (p *NetcupProvider) TakeOver(old *NetcupProvider) {
+	old.rateLimitMu.Lock()
+	until := old.rateLimitedUntil
+	old.rateLimitMu.Unlock()
+
+	p.rateLimitMu.Lock()
+	if until.After(p.rateLimitedUntil) {
+		p.rateLimitedUntil = until
+	}
+	p.rateLimitMu.Unlock()
+
+	old.requestedTTLsMu.Lock()
+	requestedTTLs := maps.Clone(old.requestedTTLs)
+	old.requestedTTLsMu.Unlock()
+
+	p.requestedTTLsMu.Lock()
+	maps.Copy(p.requestedTTLs, requestedTTLs)
+	p.requestedTTLsMu.Unlock()
+
+	old.maintenanceMu.Lock()
+	inMaintenance := old.maintenance
+	old.maintenanceMu.Unlock()
+
+	p.maintenanceMu.Lock()
+	if p.maintenance == nil && inMaintenance != nil {
+		p.maintenance = &maintenance{since: inMaintenance.since, lastWarning: inMaintenance.lastWarning}
+	}
+	p.maintenanceMu.Unlock()
+
+	forgetZoneMetrics(old.managedZones(), p.managedZones())
+}
+
+// Retire logs out the shared sessions of p once a reload replaced it, so they do not count against
+// Netcup's limit of concurrent sessions. Calls still in flight on p may fail, external-dns retries
+// them with the new provider.
+func (p *NetcupProvider) Retire() {
+	p.sharedSessionsMu.Lock()
+	p.retired = true
+	p.sharedSessionsMu.Unlock()
+	if n := p.closeSharedSessions(); n > 0 {
+		p.logger.Info("logged out of Netcup DNS API sessions of the replaced configuration", "sessions", n)
+	}
+}
@@ -0,0 +1,43 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeOver(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+	srv.AddZone("example.org", "600")
+	zoneTTL.Reset()
+	zoneSerial.Reset()
+
+	logger := promslog.New(&promslog.Config{})
+	domainFilter := []string{"example.com", "example.org"}
+	old, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithRateLimitCooldown(time.Hour))
+	assert.NoError(t, err)
+	_, err = old.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(zoneTTL))
+
+	srv.RateLimit(true)
+	_, err = old.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrRateLimited)
+	srv.RateLimit(false)
+
+	domainFilter = []string{"example.com"}
+	next, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	next.TakeOver(old)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(zoneTTL))
+	_, err = next.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrRateLimited, "the cooldown survives the reload")
+}
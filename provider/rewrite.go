@@ -0,0 +1,103 @@
+package netcup
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// NameRewrite writes the names ending in From as names ending in To with Prefix prepended, for
+// zones at Netcup named differently than in the cluster. E.g. From ".internal.example.com", Prefix
+// "internal-" and To ".example.com" write app.internal.example.com as internal-app.example.com.
+// Records read from Netcup are mapped back.
+type NameRewrite struct {
+	From   string
+	Prefix string
+	To     string
+}
+
+// ParseNameRewrite parses a rewrite of the form "*.internal.example.com=internal-*.example.com".
+func ParseNameRewrite(s string) (NameRewrite, error) {
+	from, to, ok := strings.Cut(strings.ToLower(s), "=")
+	if !ok || !strings.HasPrefix(from, "*.") || strings.Count(to, "*") != 1 {
+		return NameRewrite{}, fmt.Errorf("invalid name rewrite '%s', expected e.g. '*.internal.example.com=internal-*.example.com'", s)
+	}
+	prefix, suffix, _ := strings.Cut(to, "*")
+	if !strings.HasPrefix(suffix, ".") || len(suffix) < 2 {
+		return NameRewrite{}, fmt.Errorf("invalid name rewrite '%s', the target must end in '*.<domain>'", s)
+	}
+	if strings.Contains(prefix, ".") {
+		return NameRewrite{}, fmt.Errorf("invalid name rewrite '%s', the prefix must not contain dots", s)
+	}
+	return NameRewrite{From: from[1:], Prefix: prefix, To: suffix}, nil
+}
+
+// apply returns name as written to Netcup if the rewrite matches it.
+func (r NameRewrite) apply(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, r.From) || len(lower) == len(r.From) {
+		return name, false
+	}
+	return r.Prefix + name[:len(name)-len(r.From)] + r.To, true
+}
+
+// revert returns name as known in the cluster if it was written by the rewrite.
+func (r NameRewrite) revert(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, r.Prefix) || !strings.HasSuffix(lower, r.To) || len(lower) <= len(r.Prefix)+len(r.To) {
+		return name, false
+	}
+	return name[len(r.Prefix):len(name)-len(r.To)] + r.From, true
+}
+
+// WithNameRewrites rewrites the names of endpoints with the first matching rewrite before changes
+// are applied, and maps the names of records back when they are read.
+func WithNameRewrites(rewrites []NameRewrite) Option {
+	return func(p *NetcupProvider) {
+		p.nameRewrites = rewrites
+	}
+}
+
+// rewrittenEndpoints returns endpoints with the names written to Netcup, copying the endpoints it
+// renames.
+func (p *NetcupProvider) rewrittenEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	if len(p.nameRewrites) == 0 {
+		return endpoints
+	}
+	rewritten := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		for _, r := range p.nameRewrites {
+			if name, ok := r.apply(ep.DNSName); ok {
+				ep = ep.DeepCopy()
+				ep.DNSName = name
+				break
+			}
+		}
+		rewritten = append(rewritten, ep)
+	}
+	return rewritten
+}
+
+// revertedEndpoints maps the names of records read from Netcup back to the names in the cluster.
+func (p *NetcupProvider) revertedEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	for _, ep := range endpoints {
+		for _, r := range p.nameRewrites {
+			if name, ok := r.revert(ep.DNSName); ok {
+				ep.DNSName = name
+				break
+			}
+		}
+	}
+	return endpoints
+}
+
+// rewriteDomains returns the domains endpoints are rewritten from, which external-dns must pass on
+// in addition to the zones.
+func (p *NetcupProvider) rewriteDomains() []string {
+	domains := make([]string, 0, len(p.nameRewrites))
+	for _, r := range p.nameRewrites {
+		domains = append(domains, strings.TrimPrefix(r.From, "."))
+	}
+	return domains
+}
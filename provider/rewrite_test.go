@@ -0,0 +1,81 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestNameRewrite(t *testing.T) {
+	t.Run("Parse", testParseNameRewrite)
+	t.Run("Provider", testNameRewriteProvider)
+}
+
+func testParseNameRewrite(t *testing.T) {
+	r, err := ParseNameRewrite("*.Internal.example.com=internal-*.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, NameRewrite{From: ".internal.example.com", Prefix: "internal-", To: ".example.com"}, r)
+
+	name, ok := r.apply("a.app.internal.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "internal-a.app.example.com", name)
+	name, ok = r.revert(name)
+	assert.True(t, ok)
+	assert.Equal(t, "a.app.internal.example.com", name)
+	_, ok = r.apply("internal.example.com")
+	assert.False(t, ok)
+	_, ok = r.revert("www.example.com")
+	assert.False(t, ok)
+
+	r, err = ParseNameRewrite("*.cluster.local=*.example.com")
+	assert.NoError(t, err)
+	name, _ = r.apply("app.cluster.local")
+	assert.Equal(t, "app.example.com", name)
+
+	for _, invalid := range []string{"internal.example.com=example.com", "*.internal.example.com", "*.a.com=*", "*.a.com=x.*.b.com", "*.a.com=**.b.com"} {
+		_, err := ParseNameRewrite(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func testNameRewriteProvider(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	r, err := ParseNameRewrite("*.internal.example.com=internal-*.example.com")
+	assert.NoError(t, err)
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithNameRewrites([]NameRewrite{r}))
+	assert.NoError(t, err)
+	assert.True(t, p.GetDomainFilter().Match("app.internal.example.com"))
+
+	app := endpoint.NewEndpoint("app.internal.example.com", endpoint.RecordTypeA, "2.2.2.2")
+	heritage := endpoint.NewEndpoint("a-app.internal.example.com", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default")
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{app, heritage}}))
+	assert.Equal(t, "app.internal.example.com", app.DNSName, "changes are not modified")
+
+	var hostnames []string
+	for _, rec := range srv.Records("example.com") {
+		hostnames = append(hostnames, rec.Hostname)
+	}
+	assert.ElementsMatch(t, []string{"www", "internal-app", "internal-a-app"}, hostnames)
+
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	byName := map[string]*endpoint.Endpoint{}
+	for _, ep := range records {
+		byName[ep.DNSName] = ep
+	}
+	assert.Contains(t, byName, "www.example.com")
+	assert.Contains(t, byName, "a-app.internal.example.com")
+	if assert.Contains(t, byName, "app.internal.example.com") {
+		assert.Equal(t, "default", byName["app.internal.example.com"].Labels[endpoint.OwnerLabelKey])
+	}
+}
@@ -0,0 +1,132 @@
+package netcup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// SelfTest creates a uniquely named TXT record in zone, verifies it via the API and, if
+// nameserver is set, via live DNS, and removes it again. It proves that credentials,
+// permissions and propagation work.
+func (p *NetcupProvider) SelfTest(ctx context.Context, zone string, nameserver string, timeout time.Duration) error {
+	if p.dryRun {
+		return fmt.Errorf("self test cannot run in dry run mode")
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	probe := nc.DnsRecord{
+		Hostname:    "_external-dns-netcup-selftest-" + hex.EncodeToString(suffix),
+		Type:        "TXT",
+		Destination: "external-dns-netcup-webhook selftest " + time.Now().UTC().Format(time.RFC3339),
+	}
+	fqdn := probe.Hostname + "." + zone
+
+	sessions := p.newSessionSet()
+	defer sessions.close()
+
+	session, err := sessions.forZone(zone)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	p.logger.Info("login succeeded", "zone", zone)
+
+	if _, err := session.InfoDnsZone(zone); err != nil {
+		return fmt.Errorf("unable to query zone '%s': %w", zone, p.apiError(err, false))
+	}
+
+	if _, err := session.UpdateDnsRecords(zone, &[]nc.DnsRecord{probe}); err != nil {
+		return fmt.Errorf("unable to create probe record '%s': %w", fqdn, p.apiError(err, false))
+	}
+	p.logger.Info("created probe record", "record", fqdn)
+
+	// always try to remove the probe record, even if verification fails
+	var created *nc.DnsRecord
+	defer func() {
+		if created == nil {
+			p.logger.Warn("probe record not found, it may need to be removed manually", "record", fqdn)
+			return
+		}
+		created.DeleteRecord = true
+		if _, err := session.UpdateDnsRecords(zone, &[]nc.DnsRecord{*created}); err != nil {
+			p.logger.Error("unable to delete probe record, remove it manually", "record", fqdn, "error", err.Error())
+			return
+		}
+		p.logger.Info("deleted probe record", "record", fqdn)
+	}()
+
+	recs, err := fetchZoneRecords(session, zone)
+	if err != nil {
+		return fmt.Errorf("unable to read back records of zone '%s': %w", zone, p.apiError(err, false))
+	}
+	idx := slices.IndexFunc(recs, func(r nc.DnsRecord) bool {
+		return r.Type == probe.Type && r.Hostname == probe.Hostname && r.Destination == probe.Destination
+	})
+	if idx < 0 {
+		return fmt.Errorf("probe record '%s' not returned by the API", fqdn)
+	}
+	created = &recs[idx]
+	p.logger.Info("verified probe record via API", "record", fqdn, "id", created.Id)
+
+	if nameserver == "" {
+		return nil
+	}
+	if err := waitForTXT(ctx, nameserver, fqdn, probe.Destination, timeout); err != nil {
+		return fmt.Errorf("probe record '%s' not visible via DNS at %s: %v", fqdn, nameserver, err)
+	}
+	p.logger.Info("verified probe record via DNS", "record", fqdn, "nameserver", nameserver)
+	return nil
+}
+
+// waitForTXT polls nameserver until fqdn resolves to a TXT record containing value.
+func waitForTXT(ctx context.Context, nameserver string, fqdn string, value string, timeout time.Duration) error {
+	resolver := newResolver(nameserver)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		txts, err := resolver.LookupTXT(ctx, fqdn)
+		if err == nil && slices.Contains(txts, value) {
+			return nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return lastErr
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// newResolver returns a resolver querying nameserver, or the system resolver if nameserver is empty.
+func newResolver(nameserver string) *net.Resolver {
+	if nameserver == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, nameserver)
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.SelfTest(context.TODO(), "example.com", "", time.Second))
+	// the probe record has been removed again
+	assert.Empty(t, srv.Records("example.com"))
+	assert.Equal(t, 2, srv.Calls("updateDnsRecords"))
+
+	assert.Error(t, p.SelfTest(context.TODO(), "missing.com", "", time.Second))
+
+	srv.FailAction("login", 4001)
+	assert.Error(t, p.SelfTest(context.TODO(), "example.com", "", time.Second))
+
+	dryRun, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+	assert.Error(t, dryRun.SelfTest(context.TODO(), "example.com", "", time.Second))
+}
@@ -0,0 +1,283 @@
+package netcup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// errSessionsClosed is returned when logging in after the provider has been closed.
+var errSessionsClosed = errors.New("sessions closed on shutdown")
+
+// WithSessionKeepAlive reuses one session per set of credentials across calls instead of logging
+// in and out for every call, and keeps the sessions from expiring by polling the API every interval.
+func WithSessionKeepAlive(interval time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.keepAlive = interval
+	}
+}
+
+// sessionSet lazily logs in once per set of credentials and logs out all sessions when closed.
+// Every Records/ApplyChanges call uses its own set; the provider tracks open sets to log them
+// out on shutdown. With session keep-alive, the set uses the shared sessions of the provider
+// and leaves them logged in.
+type sessionSet struct {
+	p      *NetcupProvider
+	shared bool
+
+	mu       sync.Mutex
+	sessions map[*nc.NetcupDnsClient]setSession
+	closed   bool
+}
+
+// setSession is a session of a sessionSet. Callers use session without locking, so the set logs
+// out with a private copy taken before handing out session. Shared sessions keep the session of
+// the provider they were copied from.
+type setSession struct {
+	session, logout, origin *nc.NetcupSession
+}
+
+func (p *NetcupProvider) newSessionSet() *sessionSet {
+	s := &sessionSet{
+		p:        p,
+		shared:   p.keepAlive > 0,
+		sessions: map[*nc.NetcupDnsClient]setSession{},
+	}
+	p.sessionSetsMu.Lock()
+	defer p.sessionSetsMu.Unlock()
+	if p.sessionSets == nil {
+		p.sessionSets = map[*sessionSet]struct{}{}
+	}
+	p.sessionSets[s] = struct{}{}
+	return s
+}
+
+// forZone returns a session for the credentials responsible for zone, logging in if necessary.
+func (s *sessionSet) forZone(zone string) (*nc.NetcupSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errSessionsClosed
+	}
+	client := s.p.clientForZone(zone)
+	if cached, ok := s.sessions[client]; ok {
+		return cached.session, nil
+	}
+
+	if err := s.p.rateLimited(); err != nil {
+		return nil, err
+	}
+	var session, origin *nc.NetcupSession
+	var err error
+	if s.shared {
+		session, origin, err = s.p.sharedSession(client, zone)
+	} else {
+		s.p.logger.Debug("performing login to Netcup DNS API", "zone", zone)
+		session, err = client.Login()
+	}
+	if err != nil {
+		return nil, s.p.apiError(err, true)
+	}
+	if !s.shared {
+		s.p.logger.Debug("successfully logged in to Netcup DNS API", "zone", zone)
+	}
+	logout := *session
+	s.sessions[client] = setSession{session: session, logout: &logout, origin: origin}
+	return session, nil
+}
+
+// invalidate drops the session used for zone after a call with it failed with err, unless the
+// error says nothing about the session, e.g. a rate limit or an invalid record. The next call for
+// the credentials logs in again, and a shared session is replaced for all callers.
+func (s *sessionSet) invalidate(zone string, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.Kind == ErrRateLimited || apiErr.Kind == ErrZoneNotFound || apiErr.Kind == ErrChangeRateExceeded || apiErr.StatusCode == statusCodeValidation) {
+		return
+	}
+	client := s.p.clientForZone(zone)
+	s.mu.Lock()
+	cached, ok := s.sessions[client]
+	delete(s.sessions, client)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	s.p.logger.Debug("dropping Netcup DNS API session after failed call", "zone", zone)
+	if s.shared {
+		s.p.dropSharedSession(client, cached.origin)
+		return
+	}
+	_ = cached.logout.Logout()
+}
+
+// close logs out all sessions of the set that are not shared and returns their number.
+func (s *sessionSet) close() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	n := 0
+	for client, cached := range s.sessions {
+		delete(s.sessions, client)
+		if s.shared {
+			continue
+		}
+		// a call interrupted by Close may still use the session
+		if err := cached.logout.Logout(); err != nil {
+			s.p.logger.Debug("unable to log out from Netcup DNS API", "error", err.Error())
+		}
+		n++
+	}
+
+	s.p.sessionSetsMu.Lock()
+	delete(s.p.sessionSets, s)
+	s.p.sessionSetsMu.Unlock()
+	return n
+}
+
+// Close logs out all sessions still in use, e.g. by requests interrupted by a shutdown, so they do
+// not count against Netcup's limit of concurrent sessions. Calls in flight fail afterwards.
+func (p *NetcupProvider) Close() {
+	p.sessionSetsMu.Lock()
+	sets := make([]*sessionSet, 0, len(p.sessionSets))
+	for s := range p.sessionSets {
+		sets = append(sets, s)
+	}
+	p.sessionSetsMu.Unlock()
+
+	n := 0
+	for _, s := range sets {
+		n += s.close()
+	}
+	n += p.closeSharedSessions()
+	if n > 0 {
+		p.logger.Info("logged out of Netcup DNS API sessions on shutdown", "sessions", n)
+	}
+}
+
+// closeSharedSessions logs out the shared sessions and returns their number.
+func (p *NetcupProvider) closeSharedSessions() int {
+	p.sharedSessionsMu.Lock()
+	defer p.sharedSessionsMu.Unlock()
+	n := 0
+	for client, session := range p.sharedSessions {
+		if err := session.Logout(); err != nil {
+			p.logger.Debug("unable to log out from Netcup DNS API", "error", err.Error())
+		}
+		delete(p.sharedSessions, client)
+		sessionMetrics.closed(p.clientCustomers[client], session)
+		n++
+	}
+	return n
+}
+
+// sharedSession returns the shared session of client, logging in if there is none yet. Callers get
+// a copy that shares the session ID but records its own LastResponse, and the shared session itself
+// to identify it when dropping it.
+//
+// Shared sessions live as long as the process. They cannot be persisted across restarts, as
+// netcup-dns-api keeps the session ID unexported and offers no way to resume a session from it.
+func (p *NetcupProvider) sharedSession(client *nc.NetcupDnsClient, zone string) (*nc.NetcupSession, *nc.NetcupSession, error) {
+	p.sharedSessionsMu.Lock()
+	defer p.sharedSessionsMu.Unlock()
+	session, ok := p.sharedSessions[client]
+	if !ok {
+		if p.retired {
+			return nil, nil, errSessionsClosed
+		}
+		p.logger.Debug("performing login to Netcup DNS API", "zone", zone)
+		var err error
+		if session, err = client.Login(); err != nil {
+			return nil, nil, err
+		}
+		p.logger.Debug("successfully logged in to Netcup DNS API", "zone", zone)
+		if p.sharedSessions == nil {
+			p.sharedSessions = map[*nc.NetcupDnsClient]*nc.NetcupSession{}
+		}
+		p.sharedSessions[client] = session
+		sessionMetrics.opened(p.clientCustomers[client], session)
+	}
+	sessionMetrics.used(p.clientCustomers[client], session)
+	copied := *session
+	return &copied, session, nil
+}
+
+// dropSharedSession removes the shared session of client if it is still session, so a session
+// replaced by another caller in the meantime is kept.
+func (p *NetcupProvider) dropSharedSession(client *nc.NetcupDnsClient, session *nc.NetcupSession) {
+	p.sharedSessionsMu.Lock()
+	defer p.sharedSessionsMu.Unlock()
+	if current, ok := p.sharedSessions[client]; ok && current == session {
+		delete(p.sharedSessions, client)
+		sessionMetrics.closed(p.clientCustomers[client], session)
+		copied := *session
+		_ = copied.Logout()
+	}
+}
+
+// RunSessionKeepAlive polls the API with every shared session each keep-alive interval until ctx is
+// cancelled, so the sessions do not expire between the syncs of external-dns.
+func (p *NetcupProvider) RunSessionKeepAlive(ctx context.Context) {
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.keepSessionsAlive()
+	}
+}
+
+// keepSessionsAlive queries a zone with each shared session. Sessions failing the query, or whose
+// credentials no longer manage a zone, are dropped; the next call logs in again.
+func (p *NetcupProvider) keepSessionsAlive() {
+	if p.rateLimited() != nil {
+		return
+	}
+	p.sharedSessionsMu.Lock()
+	sessions := make(map[*nc.NetcupDnsClient]nc.NetcupSession, len(p.sharedSessions))
+	origins := make(map[*nc.NetcupDnsClient]*nc.NetcupSession, len(p.sharedSessions))
+	for client, session := range p.sharedSessions {
+		sessions[client] = *session
+		origins[client] = session
+	}
+	p.sharedSessionsMu.Unlock()
+
+	zones := p.managedZones()
+	for client, session := range sessions {
+		zone := ""
+		for _, z := range zones {
+			if p.clientForZone(z) == client {
+				zone = z
+				break
+			}
+		}
+		var err error
+		if zone == "" {
+			_ = session.Logout()
+		} else if _, err = session.InfoDnsZone(zone); err == nil {
+			p.recordAPISuccess()
+			p.leaveMaintenance()
+			sessionMetrics.used(p.clientCustomers[client], origins[client])
+			p.logger.Debug("kept Netcup DNS API session alive", "zone", zone)
+			continue
+		} else if err = p.apiError(err, false); errors.Is(err, ErrMaintenance) {
+			p.logger.Debug("unable to keep Netcup DNS API session alive during maintenance, logging in again on next use", "zone", zone, "error", err.Error())
+		} else {
+			p.logger.Warn("unable to keep Netcup DNS API session alive, logging in again on next use", "zone", zone, "error", err.Error())
+		}
+		// a call may have replaced the session in the meantime
+		p.sharedSessionsMu.Lock()
+		if current, ok := p.sharedSessions[client]; ok && current == origins[client] {
+			delete(p.sharedSessions, client)
+			sessionMetrics.closed(p.clientCustomers[client], origins[client])
+		}
+		p.sharedSessionsMu.Unlock()
+	}
+}
@@ -0,0 +1,228 @@
+package netcup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestCloseLogsOutOpenSessions(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	inFlight := p.newSessionSet()
+	_, err = inFlight.forZone("example.com")
+	assert.NoError(t, err)
+	done := p.newSessionSet()
+	_, err = done.forZone("example.com")
+	assert.NoError(t, err)
+	done.close()
+	assert.Equal(t, 1, srv.Calls("logout"))
+
+	p.Close()
+	assert.Equal(t, 2, srv.Calls("logout"))
+	_, err = inFlight.forZone("example.com")
+	assert.ErrorIs(t, err, errSessionsClosed)
+
+	// closing again does not log out twice
+	inFlight.close()
+	p.Close()
+	assert.Equal(t, 2, srv.Calls("logout"))
+}
+
+func TestSessionKeepAlive(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithSessionKeepAlive(time.Hour))
+	assert.NoError(t, err)
+
+	for range 2 {
+		_, err = p.Records(context.TODO())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, srv.Calls("login"), "session is reused")
+	assert.Equal(t, 0, srv.Calls("logout"))
+
+	zoneCalls := srv.Calls("infoDnsZone")
+	p.keepSessionsAlive()
+	assert.Equal(t, zoneCalls+1, srv.Calls("infoDnsZone"))
+
+	// an expired session is replaced on next use
+	srv.FailAction("infoDnsZone", 4001)
+	p.keepSessionsAlive()
+	srv.FailAction("infoDnsZone", 0)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Calls("login"))
+
+	p.Close()
+	assert.Equal(t, 1, srv.Calls("logout"))
+}
+
+func TestFailedCallDropsSharedSession(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithSessionKeepAlive(time.Hour))
+	assert.NoError(t, err)
+
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, srv.Calls("login"))
+
+	// an invalid record says nothing about the session
+	srv.FailAction("updateDnsRecords", 4013)
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")}}
+	assert.Error(t, p.ApplyChanges(context.TODO(), changes))
+	srv.FailAction("updateDnsRecords", 0)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, srv.Calls("login"))
+
+	// a rejected session is replaced for the next call instead of failing until the next keep-alive
+	srv.FailAction("infoDnsRecords", 4001)
+	_, err = p.Records(context.TODO())
+	assert.Error(t, err)
+	srv.FailAction("infoDnsRecords", 0)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Calls("login"))
+	assert.Equal(t, 1, srv.Calls("logout"), "the dropped session is logged out")
+}
+
+// TestConcurrentSessionUse is meant to be run with the race detector.
+func TestConcurrentSessionUse(t *testing.T) {
+	for _, keepAlive := range []time.Duration{0, time.Hour} {
+		srv := netcuptest.NewServer()
+		srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+		domainFilter := []string{"example.com"}
+		p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithSessionKeepAlive(keepAlive))
+		assert.NoError(t, err)
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					switch i % 3 {
+					case 0:
+						_, _ = p.Records(context.TODO())
+					case 1:
+						_ = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")}})
+					default:
+						p.keepSessionsAlive()
+					}
+				}
+			}()
+		}
+
+		// calls fail with a rejected session and drop it while others use it, and the provider is
+		// closed while calls are in flight
+		time.Sleep(20 * time.Millisecond)
+		srv.FailAction("infoDnsRecords", 4001)
+		time.Sleep(20 * time.Millisecond)
+		srv.FailAction("infoDnsRecords", 0)
+		for range 3 {
+			p.Close()
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(stop)
+		wg.Wait()
+		srv.Close()
+	}
+}
+
+// sessionMetric returns the value of the session metric name of customerID, if reported.
+func sessionMetric(t *testing.T, name string, customerID string) (float64, bool) {
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(sessionMetrics))
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "external_dns_netcup_"+name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() == customerID {
+				return m.GetGauge().GetValue(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestSessionMetrics(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 42, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithSessionKeepAlive(time.Hour))
+	assert.NoError(t, err)
+	_, ok := sessionMetric(t, "session_active", "42")
+	assert.False(t, ok)
+
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	active, _ := sessionMetric(t, "session_active", "42")
+	assert.Equal(t, float64(1), active)
+	age, _ := sessionMetric(t, "session_age_seconds", "42")
+	assert.Less(t, age, float64(60))
+	expiry, _ := sessionMetric(t, "session_expiry_seconds", "42")
+	assert.InDelta(t, sessionTimeout.Seconds(), expiry, 60)
+
+	p.Close()
+	active, _ = sessionMetric(t, "session_active", "42")
+	assert.Equal(t, float64(0), active)
+	_, ok = sessionMetric(t, "session_age_seconds", "42")
+	assert.False(t, ok)
+}
+
+func TestRetireLogsOutSharedSessions(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 43, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithSessionKeepAlive(time.Hour))
+	assert.NoError(t, err)
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	p.Retire()
+	assert.Equal(t, 1, srv.Calls("logout"))
+	active, _ := sessionMetric(t, "session_active", "43")
+	assert.Equal(t, float64(0), active)
+
+	// calls still arriving do not log in again
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, errSessionsClosed)
+	assert.Equal(t, 1, srv.Calls("login"))
+}
@@ -0,0 +1,91 @@
+package netcup
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sessionTimeout is the inactivity after which the Netcup API expires a session.
+const sessionTimeout = 15 * time.Minute
+
+// sessionState is the state of the shared session of a customer.
+type sessionState struct {
+	session  *nc.NetcupSession
+	loggedIn time.Time
+	lastUsed time.Time
+}
+
+// sessionCollector reports the shared sessions of all providers, so the series survive
+// configuration reloads. Sessions are keyed by customer ID.
+type sessionCollector struct {
+	mu       sync.Mutex
+	sessions map[int]*sessionState
+
+	active, age, expiry *prometheus.Desc
+}
+
+var sessionMetrics = &sessionCollector{
+	sessions: map[int]*sessionState{},
+	active:   metrics.NewDesc("session_active", "Whether a shared Netcup API session is held for the customer (1) or not (0).", []string{"customer_id"}),
+	age:      metrics.NewDesc("session_age_seconds", "Time since the shared Netcup API session of the customer logged in.", []string{"customer_id"}),
+	expiry:   metrics.NewDesc("session_expiry_seconds", "Estimated time until the shared Netcup API session of the customer expires for inactivity.", []string{"customer_id"}),
+}
+
+func init() {
+	prometheus.MustRegister(sessionMetrics)
+}
+
+// Describe implements prometheus.Collector.
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.age
+	ch <- c.expiry
+}
+
+// Collect implements prometheus.Collector.
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for customerID, state := range c.sessions {
+		customer := strconv.Itoa(customerID)
+		if state.session == nil {
+			ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, 0, customer)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, 1, customer)
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, now.Sub(state.loggedIn).Seconds(), customer)
+		ch <- prometheus.MustNewConstMetric(c.expiry, prometheus.GaugeValue, max(state.lastUsed.Add(sessionTimeout).Sub(now), 0).Seconds(), customer)
+	}
+}
+
+// opened records that session logged in for customerID.
+func (c *sessionCollector) opened(customerID int, session *nc.NetcupSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.sessions[customerID] = &sessionState{session: session, loggedIn: now, lastUsed: now}
+}
+
+// used records that session was used, which keeps it from expiring.
+func (c *sessionCollector) used(customerID int, session *nc.NetcupSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, ok := c.sessions[customerID]; ok && state.session == session {
+		state.lastUsed = time.Now()
+	}
+}
+
+// closed records that session was logged out or dropped, unless another one replaced it already.
+func (c *sessionCollector) closed(customerID int, session *nc.NetcupSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, ok := c.sessions[customerID]; ok && state.session == session {
+		c.sessions[customerID] = &sessionState{}
+	}
+}
@@ -0,0 +1,160 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ShadowReport lists the differences between the records of the provider and a reference export.
+type ShadowReport struct {
+	Time       time.Time            `json:"time"`
+	Source     string               `json:"source"`
+	Missing    []*endpoint.Endpoint `json:"missing"`
+	Extra      []*endpoint.Endpoint `json:"extra"`
+	Mismatched []ShadowMismatch     `json:"mismatched"`
+}
+
+// ShadowMismatch is a record present in both sets with different targets.
+type ShadowMismatch struct {
+	Netcup    *endpoint.Endpoint `json:"netcup"`
+	Reference *endpoint.Endpoint `json:"reference"`
+}
+
+// ShadowComparer compares the records of the provider with an endpoint export of another
+// provider, e.g. the /records response of another webhook, during a migration.
+type ShadowComparer struct {
+	source string
+	client *http.Client
+
+	mu   sync.Mutex
+	last *ShadowReport
+}
+
+// NewShadowComparer compares with the JSON endpoint list at source, either a http(s) URL or a file path.
+func NewShadowComparer(source string) *ShadowComparer {
+	return &ShadowComparer{
+		source: source,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithShadowComparer runs the provider in shadow mode: changes are computed and recorded but never
+// applied, and the records are compared with the reference export of comparer.
+func WithShadowComparer(comparer *ShadowComparer) Option {
+	return func(p *NetcupProvider) {
+		p.shadow = comparer
+	}
+}
+
+// reference loads the endpoints of the reference export.
+func (c *ShadowComparer) reference(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	var body io.ReadCloser
+	if strings.HasPrefix(c.source, "http://") || strings.HasPrefix(c.source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.source, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/external.dns.webhook+json;version=1, application/json")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status code %d from '%s'", resp.StatusCode, c.source)
+		}
+		body = resp.Body
+	} else {
+		f, err := os.Open(c.source)
+		if err != nil {
+			return nil, err
+		}
+		body = f
+	}
+	defer body.Close()
+
+	endpoints := []*endpoint.Endpoint{}
+	if err := json.NewDecoder(body).Decode(&endpoints); err != nil {
+		return nil, fmt.Errorf("unable to decode endpoints from '%s': %v", c.source, err)
+	}
+	return endpoints, nil
+}
+
+// compare diffs records against the reference export and stores the report.
+func (c *ShadowComparer) compare(ctx context.Context, records []*endpoint.Endpoint) (*ShadowReport, error) {
+	reference, err := c.reference(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := func(ep *endpoint.Endpoint) string {
+		return strings.ToLower(strings.TrimSuffix(ep.DNSName, ".")) + "/" + ep.RecordType + "/" + ep.SetIdentifier
+	}
+	sortedTargets := func(ep *endpoint.Endpoint) []string {
+		targets := append([]string{}, ep.Targets...)
+		slices.Sort(targets)
+		return targets
+	}
+
+	own := map[string]*endpoint.Endpoint{}
+	for _, ep := range records {
+		own[key(ep)] = ep
+	}
+
+	report := &ShadowReport{
+		Time:       time.Now(),
+		Source:     c.source,
+		Missing:    []*endpoint.Endpoint{},
+		Extra:      []*endpoint.Endpoint{},
+		Mismatched: []ShadowMismatch{},
+	}
+	seen := map[string]bool{}
+	for _, ref := range reference {
+		k := key(ref)
+		seen[k] = true
+		ep, ok := own[k]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, ref)
+		case !slices.Equal(sortedTargets(ep), sortedTargets(ref)):
+			report.Mismatched = append(report.Mismatched, ShadowMismatch{Netcup: ep, Reference: ref})
+		}
+	}
+	for _, ep := range records {
+		if !seen[key(ep)] {
+			report.Extra = append(report.Extra, ep)
+		}
+	}
+
+	shadowDiscrepancies.WithLabelValues("missing").Set(float64(len(report.Missing)))
+	shadowDiscrepancies.WithLabelValues("extra").Set(float64(len(report.Extra)))
+	shadowDiscrepancies.WithLabelValues("mismatched").Set(float64(len(report.Mismatched)))
+
+	c.mu.Lock()
+	c.last = report
+	c.mu.Unlock()
+	return report, nil
+}
+
+// ServeHTTP returns the last comparison report as JSON.
+func (c *ShadowComparer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	report := c.last
+	c.mu.Unlock()
+	if report == nil {
+		http.Error(w, "no comparison has been run yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
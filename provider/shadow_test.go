@@ -0,0 +1,70 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestShadowComparer(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300",
+		nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"},
+		nc.DnsRecord{Hostname: "api", Type: "A", Destination: "2.2.2.2"},
+		nc.DnsRecord{Hostname: "extra", Type: "A", Destination: "3.3.3.3"},
+	)
+
+	reference := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("api.example.com", endpoint.RecordTypeA, "9.9.9.9"),
+			endpoint.NewEndpoint("missing.example.com", endpoint.RecordTypeA, "4.4.4.4"),
+		})
+	}))
+	defer reference.Close()
+
+	comparer := NewShadowComparer(reference.URL)
+	domainFilter := []string{"example.com"}
+	logger := promslog.New(&promslog.Config{})
+	history := NewChangeHistory(10)
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL), WithShadowComparer(comparer), WithChangeHistory(history))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	comparer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/shadow", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	comparer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/shadow", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var report ShadowReport
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	assert.Len(t, report.Missing, 1)
+	assert.Equal(t, "missing.example.com", report.Missing[0].DNSName)
+	assert.Len(t, report.Extra, 1)
+	assert.Equal(t, "extra.example.com", report.Extra[0].DNSName)
+	assert.Len(t, report.Mismatched, 1)
+	assert.Equal(t, "api.example.com", report.Mismatched[0].Reference.DNSName)
+
+	// changes are recorded but never applied
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", endpoint.RecordTypeA, "5.5.5.5")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, srv.Records("example.com"), 3)
+	assert.Equal(t, 0, srv.Calls("updateDnsRecords"))
+	assert.Equal(t, "shadow", history.Entries()[0].Outcome)
+}
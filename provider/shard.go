@@ -0,0 +1,46 @@
+package netcup
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// WithSharding makes the provider only serve the zones assigned to shard index out of count shards.
+func WithSharding(index, count int) Option {
+	return func(p *NetcupProvider) {
+		p.shardIndex = index
+		p.shardCount = count
+	}
+}
+
+// validateSharding checks that the shard configuration is consistent.
+func (p *NetcupProvider) validateSharding() error {
+	if p.shardCount < 1 {
+		return fmt.Errorf("shard count must be at least 1, got %d", p.shardCount)
+	}
+	if p.shardIndex < 0 || p.shardIndex >= p.shardCount {
+		return fmt.Errorf("shard index must be between 0 and %d, got %d", p.shardCount-1, p.shardIndex)
+	}
+	return nil
+}
+
+// zoneShard returns the shard a zone is assigned to.
+func zoneShard(zone string, shardCount int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(zone))
+	return int(h.Sum32() % uint32(shardCount))
+}
+
+// shardZones returns the zones assigned to the shard of this provider and updates the assignment metrics.
+func (p *NetcupProvider) shardZones(zones []string) []string {
+	owned := make([]string, 0, len(zones))
+	zoneShardAssignment.Reset()
+	for _, zone := range zones {
+		shard := zoneShard(zone, p.shardCount)
+		zoneShardAssignment.WithLabelValues(zone).Set(float64(shard))
+		if shard == p.shardIndex {
+			owned = append(owned, zone)
+		}
+	}
+	return owned
+}
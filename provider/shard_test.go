@@ -0,0 +1,42 @@
+package netcup
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestSharding(t *testing.T) {
+	domainFilter := []string{"a.com", "b.com", "c.com", "d.com", "e.com", "f.com"}
+	logger := promslog.New(&promslog.Config{})
+
+	// every zone is served by exactly one shard
+	served := map[string]int{}
+	for i := 0; i < 3; i++ {
+		p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger, WithSharding(i, 3))
+		assert.NoError(t, err)
+		for _, zone := range p.managedZones() {
+			served[zone]++
+			assert.Equal(t, i, zoneShard(zone, 3))
+		}
+		// external-dns only plans changes for the zones of the shard
+		assert.Equal(t, endpoint.NewDomainFilter(p.managedZones()), p.GetDomainFilter())
+	}
+	assert.Len(t, served, len(domainFilter))
+	for zone, count := range served {
+		assert.Equal(t, 1, count, zone)
+	}
+
+	// a single shard serves all zones
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, domainFilter, p.managedZones())
+	assert.Equal(t, endpoint.NewDomainFilter(domainFilter), p.GetDomainFilter())
+
+	_, err = NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger, WithSharding(3, 3))
+	assert.Error(t, err)
+	_, err = NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, logger, WithSharding(0, 0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package netcup
+
+import (
+	"context"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Modes in which changes are simulated instead of applied.
+const (
+	SimulationDryRun = "dry-run"
+	SimulationShadow = "shadow"
+)
+
+// ActionCounts counts the endpoints of a zone to create, update and delete.
+type ActionCounts struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+}
+
+// SimulatedChanges describes the changes the provider would have applied in a dry-run or shadow
+// mode. Mode is empty if the changes were applied.
+type SimulatedChanges struct {
+	Mode  string                  `json:"mode"`
+	Zones map[string]ActionCounts `json:"zones"`
+}
+
+type simulationKey struct{}
+
+// WithSimulationReport returns a context making ApplyChanges fill report if it only simulates the
+// changes.
+func WithSimulationReport(ctx context.Context, report *SimulatedChanges) context.Context {
+	return context.WithValue(ctx, simulationKey{}, report)
+}
+
+// reportSimulation logs the changes per zone that are simulated in mode and reports them to the
+// report of ctx, if any.
+func (p *NetcupProvider) reportSimulation(ctx context.Context, mode string, perZoneChanges map[string]*plan.Changes) {
+	zones := map[string]ActionCounts{}
+	for zone, c := range perZoneChanges {
+		if !c.HasChanges() {
+			continue
+		}
+		counts := ActionCounts{Create: len(c.Create), Update: len(c.UpdateNew), Delete: len(c.Delete)}
+		zones[zone] = counts
+		p.logger.Info(mode+" - not applying changes", "zone", zone, "create", counts.Create, "update", counts.Update, "delete", counts.Delete)
+	}
+	if report, ok := ctx.Value(simulationKey{}).(*SimulatedChanges); ok {
+		report.Mode = mode
+		report.Zones = zones
+	}
+}
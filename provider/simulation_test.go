@@ -0,0 +1,41 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestSimulationReport(t *testing.T) {
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", true, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+
+	var report SimulatedChanges
+	err = p.ApplyChanges(WithSimulationReport(context.TODO(), &report), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("b.example.com", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "2.2.2.2")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SimulatedChanges{
+		Mode: SimulationDryRun,
+		Zones: map[string]ActionCounts{
+			"example.com": {Create: 2},
+			"example.org": {Update: 1, Delete: 1},
+		},
+	}, report)
+
+	// applying without a report is fine as well
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", endpoint.RecordTypeA, "1.1.1.1")},
+	}))
+}
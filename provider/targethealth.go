@@ -0,0 +1,109 @@
+package netcup
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// WithTargetHealthCheck only publishes A and AAAA targets accepting TCP connections on port within
+// timeout. Endpoints without a healthy target are not created, and updates without one keep the
+// current targets; external-dns plans them again on its next sync.
+func WithTargetHealthCheck(port int, timeout time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.healthCheckPort = port
+		p.healthCheckTimeout = timeout
+	}
+}
+
+// withHealthyTargets removes the targets failing the health check from the creates and updates of
+// changes, logging and counting them.
+func (p *NetcupProvider) withHealthyTargets(ctx context.Context, changes *plan.Changes) *plan.Changes {
+	if p.healthCheckPort == 0 {
+		return changes
+	}
+	healthy := p.checkTargets(ctx, append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...))
+	healthyEndpoint := func(ep *endpoint.Endpoint) *endpoint.Endpoint {
+		if ep.RecordType != endpoint.RecordTypeA && ep.RecordType != endpoint.RecordTypeAAAA {
+			return ep
+		}
+		var targets endpoint.Targets
+		for _, target := range ep.Targets {
+			if healthy[target] {
+				targets = append(targets, target)
+				continue
+			}
+			p.logger.Warn("not publishing target failing the health check", "endpoint", ep.String(), "target", target, "port", p.healthCheckPort)
+			unhealthyTargetsTotal.WithLabelValues(ep.RecordType).Inc()
+		}
+		if len(targets) == len(ep.Targets) {
+			return ep
+		}
+		if len(targets) == 0 {
+			return nil
+		}
+		ep = ep.DeepCopy()
+		ep.Targets = targets
+		return ep
+	}
+
+	filtered := &plan.Changes{Delete: changes.Delete}
+	for _, ep := range changes.Create {
+		if ep = healthyEndpoint(ep); ep != nil {
+			filtered.Create = append(filtered.Create, ep)
+		}
+	}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		filtered.UpdateOld, filtered.UpdateNew = changes.UpdateOld, changes.UpdateNew
+		return filtered
+	}
+	for i, ep := range changes.UpdateNew {
+		if ep = healthyEndpoint(ep); ep != nil {
+			filtered.UpdateOld = append(filtered.UpdateOld, changes.UpdateOld[i])
+			filtered.UpdateNew = append(filtered.UpdateNew, ep)
+		}
+	}
+	return filtered
+}
+
+// checkTargets dials the A and AAAA targets of endpoints concurrently and returns the healthy ones.
+func (p *NetcupProvider) checkTargets(ctx context.Context, endpoints []*endpoint.Endpoint) map[string]bool {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	healthy := map[string]bool{}
+	dialer := &net.Dialer{Timeout: p.healthCheckTimeout}
+	for _, ep := range endpoints {
+		if ep.RecordType != endpoint.RecordTypeA && ep.RecordType != endpoint.RecordTypeAAAA {
+			continue
+		}
+		for _, target := range ep.Targets {
+			mu.Lock()
+			_, seen := healthy[target]
+			healthy[target] = false
+			mu.Unlock()
+			if seen {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target, strconv.Itoa(p.healthCheckPort)))
+				if err != nil {
+					p.logger.Debug("target failed the health check", "target", target, "error", err.Error())
+					return
+				}
+				_ = conn.Close()
+				mu.Lock()
+				healthy[target] = true
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+	return healthy
+}
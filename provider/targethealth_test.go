@@ -0,0 +1,55 @@
+package netcup
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTargetHealthCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "127.0.0.1"})
+	unhealthyTargetsTotal.Reset()
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithTargetHealthCheck(port, time.Second))
+	assert.NoError(t, err)
+
+	// nothing listens on 127.0.0.2
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "127.0.0.1", "127.0.0.2"),
+			endpoint.NewEndpoint("dead.example.com", endpoint.RecordTypeA, "127.0.0.2"),
+			endpoint.NewEndpoint("txt.example.com", endpoint.RecordTypeTXT, "127.0.0.2"),
+		},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "127.0.0.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", endpoint.RecordTypeA, "127.0.0.2")},
+	}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+
+	destinations := map[string][]string{}
+	for _, rec := range srv.Records("example.com") {
+		destinations[rec.Hostname] = append(destinations[rec.Hostname], rec.Destination)
+	}
+	assert.Equal(t, map[string][]string{
+		"app": {"127.0.0.1"},
+		"txt": {"127.0.0.2"},
+		"www": {"127.0.0.1"},
+	}, destinations)
+	assert.Equal(t, float64(3), testutil.ToFloat64(unhealthyTargetsTotal.WithLabelValues(endpoint.RecordTypeA)))
+}
@@ -0,0 +1,146 @@
+package netcup
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// defaultZoneTTL is the TTL assumed for zones whose TTL cannot be parsed, Netcup's automatic TTL.
+const defaultZoneTTL endpoint.TTL = 300
+
+// WithDefaultTTL sets the TTL assumed for the records of zones whose TTL cannot be parsed.
+func WithDefaultTTL(ttl endpoint.TTL) Option {
+	return func(p *NetcupProvider) {
+		p.defaultTTL = ttl
+	}
+}
+
+// WithRecordTTL reports ttl as the TTL of all records instead of the TTL of their zone. Netcup only
+// supports a TTL per zone, so this only changes the TTL external-dns compares the records with.
+func WithRecordTTL(ttl endpoint.TTL) Option {
+	return func(p *NetcupProvider) {
+		p.recordTTL = ttl
+	}
+}
+
+// parseZoneTTL parses the TTL of a zone. An empty or malformed TTL falls back to the default TTL
+// and is logged once per zone, so a single broken zone does not stop the others from syncing.
+func (p *NetcupProvider) parseZoneTTL(zone, ttl string) endpoint.TTL {
+	parsed, err := strconv.ParseUint(ttl, 10, 32)
+	p.badTTLZonesMu.Lock()
+	defer p.badTTLZonesMu.Unlock()
+	if err == nil {
+		delete(p.badTTLZones, zone)
+		return endpoint.TTL(parsed)
+	}
+	if _, warned := p.badTTLZones[zone]; !warned {
+		if p.badTTLZones == nil {
+			p.badTTLZones = map[string]struct{}{}
+		}
+		p.badTTLZones[zone] = struct{}{}
+		p.logger.Warn("unable to parse zone TTL, using the default TTL", "domain", zone, "ttl", ttl, "default", p.defaultTTL)
+	}
+	return p.defaultTTL
+}
+
+// requestedTTLProperty is the provider specific property holding the TTL requested by external-dns.
+// Netcup has no per-record TTL, so AdjustEndpoints moves the requested TTL into this property and
+// Records reports it back. Otherwise the plan would update records every cycle whose requested TTL
+// differs from the zone TTL.
+const requestedTTLProperty = "webhook/requested-ttl"
+
+// requestedTTLKey identifies an endpoint in the requested TTLs.
+func requestedTTLKey(ep *endpoint.Endpoint) string {
+	return strings.ToLower(strings.TrimSuffix(ep.DNSName, ".")) + "/" + ep.RecordType + "/" + ep.SetIdentifier
+}
+
+// adjustRequestedTTLs moves the TTL of the endpoints into the requested TTL property and remembers it
+// for Records.
+func (p *NetcupProvider) adjustRequestedTTLs(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	p.requestedTTLsMu.Lock()
+	defer p.requestedTTLsMu.Unlock()
+	for _, ep := range endpoints {
+		if ttl, ok := ep.GetProviderSpecificProperty(requestedTTLProperty); ok && !ep.RecordTTL.IsConfigured() {
+			// already adjusted
+			p.requestedTTLs[requestedTTLKey(ep)] = ttl
+			continue
+		}
+		if !ep.RecordTTL.IsConfigured() {
+			delete(p.requestedTTLs, requestedTTLKey(ep))
+			ep.DeleteProviderSpecificProperty(requestedTTLProperty)
+			continue
+		}
+		ttl := strconv.FormatInt(int64(ep.RecordTTL), 10)
+		p.requestedTTLs[requestedTTLKey(ep)] = ttl
+		ep.SetProviderSpecificProperty(requestedTTLProperty, ttl)
+		ep.RecordTTL = 0
+	}
+	return endpoints
+}
+
+// reportRequestedTTLs sets the requested TTL property of the endpoints read from Netcup.
+func (p *NetcupProvider) reportRequestedTTLs(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	p.requestedTTLsMu.Lock()
+	defer p.requestedTTLsMu.Unlock()
+	for _, ep := range endpoints {
+		if ttl, ok := p.requestedTTLs[requestedTTLKey(ep)]; ok {
+			ep.SetProviderSpecificProperty(requestedTTLProperty, ttl)
+		}
+	}
+	return endpoints
+}
+
+// rememberRequestedTTLs remembers the requested TTLs of changes for Records.
+func (p *NetcupProvider) rememberRequestedTTLs(changes *plan.Changes) {
+	p.requestedTTLsMu.Lock()
+	defer p.requestedTTLsMu.Unlock()
+	for _, ep := range slices.Concat(changes.Create, changes.UpdateNew) {
+		if ttl, ok := ep.GetProviderSpecificProperty(requestedTTLProperty); ok {
+			p.requestedTTLs[requestedTTLKey(ep)] = ttl
+		}
+	}
+	for _, ep := range changes.Delete {
+		delete(p.requestedTTLs, requestedTTLKey(ep))
+	}
+}
+
+// withoutTTLOnlyUpdates drops the updates that only change the requested TTL, as there is nothing
+// to change at Netcup for them.
+func (p *NetcupProvider) withoutTTLOnlyUpdates(changes *plan.Changes) *plan.Changes {
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return changes
+	}
+	filtered := &plan.Changes{Create: changes.Create, Delete: changes.Delete}
+	for i, old := range changes.UpdateOld {
+		updated := changes.UpdateNew[i]
+		if onlyRequestedTTLChanged(old, updated) {
+			p.logger.Debug("only the requested TTL changed, nothing to update", "endpoint", updated.String())
+			continue
+		}
+		filtered.UpdateOld = append(filtered.UpdateOld, old)
+		filtered.UpdateNew = append(filtered.UpdateNew, updated)
+	}
+	return filtered
+}
+
+// onlyRequestedTTLChanged reports whether old and updated only differ in the requested TTL.
+func onlyRequestedTTLChanged(old, updated *endpoint.Endpoint) bool {
+	if requestedTTLKey(old) != requestedTTLKey(updated) || !old.Targets.Same(updated.Targets) || old.RecordTTL != updated.RecordTTL {
+		return false
+	}
+	properties := func(ep *endpoint.Endpoint) map[string]string {
+		m := map[string]string{}
+		for _, ps := range ep.ProviderSpecific {
+			if ps.Name != requestedTTLProperty {
+				m[ps.Name] = ps.Value
+			}
+		}
+		return m
+	}
+	return maps.Equal(properties(old), properties(updated)) && maps.Equal(old.Labels, updated.Labels)
+}
@@ -0,0 +1,83 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRecordsWithUnparsableZoneTTL(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+	srv.AddZone("example.org", "600", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "2.2.2.2"})
+
+	domainFilter := []string{"example.com", "example.org"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithDefaultTTL(3600))
+	assert.NoError(t, err)
+
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	ttls := map[string]endpoint.TTL{}
+	for _, ep := range endpoints {
+		ttls[ep.DNSName] = ep.RecordTTL
+	}
+	assert.Equal(t, map[string]endpoint.TTL{"www.example.com": 3600, "www.example.org": 600}, ttls)
+	assert.Contains(t, p.badTTLZones, "example.com")
+	assert.NotContains(t, p.badTTLZones, "example.org")
+}
+
+func TestRecordsWithRecordTTL(t *testing.T) {
+	zoneTTL.Reset()
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "600", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithRecordTTL(60))
+	assert.NoError(t, err)
+
+	endpoints, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, endpoint.TTL(60), endpoints[0].RecordTTL)
+	// the zone TTL metric still reports the TTL configured at Netcup
+	assert.Equal(t, float64(600), testutil.ToFloat64(zoneTTL.WithLabelValues("example.com")))
+}
+
+func TestRequestedTTLDoesNotOscillate(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	calculate := func() *plan.Changes {
+		current, err := p.Records(context.TODO())
+		assert.NoError(t, err)
+		desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 60, "1.1.1.1")})
+		assert.NoError(t, err)
+		return (&plan.Plan{Current: current, Desired: desired, ManagedRecords: []string{endpoint.RecordTypeA}}).Calculate().Changes
+	}
+
+	// the first cycle after a start only records the requested TTL, nothing changes at Netcup
+	changes := calculate()
+	assert.Len(t, changes.UpdateNew, 1)
+	value, _ := changes.UpdateNew[0].GetProviderSpecificProperty(requestedTTLProperty)
+	assert.Equal(t, "60", value)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, []nc.DnsRecord{{Id: "1", Hostname: "www", Type: "A", Destination: "1.1.1.1"}}, srv.Records("example.com"))
+
+	// afterwards the plan is stable
+	assert.False(t, calculate().HasChanges())
+	assert.False(t, calculate().HasChanges())
+}
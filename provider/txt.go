@@ -0,0 +1,99 @@
+package netcup
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// unquoteTXT returns the text of a TXT record value. Values made of one or more quoted character
+// strings, e.g. `"v=spf1 " "-all"` as returned for long records, are unescaped and joined. Other
+// values, including ones with unbalanced quotes, are returned unchanged.
+func unquoteTXT(value string) string {
+	s := strings.TrimSpace(value)
+	if !strings.HasPrefix(s, "\"") {
+		return value
+	}
+
+	var text strings.Builder
+	for len(s) > 0 {
+		if s[0] != '"' {
+			return value
+		}
+		end := -1
+	segment:
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 == len(s) {
+					return value
+				}
+				i++
+				text.WriteByte(s[i])
+			case '"':
+				end = i
+				break segment
+			default:
+				text.WriteByte(s[i])
+			}
+		}
+		if end < 0 {
+			return value
+		}
+		s = strings.TrimLeft(s[end+1:], " \t")
+	}
+	return text.String()
+}
+
+// TXTQuoting selects the TXT records whose values are read and written without their surrounding quotes.
+type TXTQuoting string
+
+const (
+	// TXTQuotingAll strips the quotes of all TXT records.
+	TXTQuotingAll TXTQuoting = "all"
+	// TXTQuotingHeritage only strips the quotes of the heritage records of external-dns and keeps
+	// other TXT records verbatim, e.g. for tooling relying on embedded quotes.
+	TXTQuotingHeritage TXTQuoting = "heritage"
+)
+
+// WithTXTQuoting sets the TXT records whose quotes are stripped, TXTQuotingAll by default.
+func WithTXTQuoting(quoting TXTQuoting) Option {
+	return func(p *NetcupProvider) {
+		p.txtQuoting = quoting
+	}
+}
+
+// validate checks that q is a known quoting mode.
+func (q TXTQuoting) validate() error {
+	if q != TXTQuotingAll && q != TXTQuotingHeritage {
+		return fmt.Errorf("unknown TXT quoting '%s', must be '%s' or '%s'", q, TXTQuotingAll, TXTQuotingHeritage)
+	}
+	return nil
+}
+
+// text returns the text of a TXT record value, or value unchanged if its quotes are kept.
+func (q TXTQuoting) text(value string) string {
+	text := unquoteTXT(value)
+	if q == TXTQuotingHeritage && !strings.HasPrefix(text, "heritage=") {
+		return value
+	}
+	return text
+}
+
+// sameDestination reports whether target and the destination of a record of recordType are equal,
+// ignoring the quoting of TXT records whose quotes are stripped and the trailing dot of hostnames.
+// Values of opaque record types must be identical.
+//
+// Unquoting is not idempotent, e.g. `"\"a\""` is written as `"a"`, which reads as `a`, so a TXT
+// destination also matches if it is the value written for target.
+func (q TXTQuoting) sameDestination(recordType, target, destination string) bool {
+	switch {
+	case recordType == endpoint.RecordTypeTXT:
+		text := q.text(target)
+		return text == destination || text == q.text(destination)
+	case opaqueRecordType(recordType):
+		return target == destination
+	}
+	return strings.TrimSuffix(target, ".") == strings.TrimSuffix(destination, ".")
+}
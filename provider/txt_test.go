@@ -0,0 +1,71 @@
+package netcup
+
+import (
+	"testing"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestUnquoteTXT(t *testing.T) {
+	for _, tc := range []struct {
+		value, want string
+	}{
+		{"hello world", "hello world"},
+		{`"hello world"`, "hello world"},
+		{`"heritage=external-dns,external-dns/owner=default"`, "heritage=external-dns,external-dns/owner=default"},
+		{`"v=spf1 " "-all"`, "v=spf1 -all"},
+		{`"v=DKIM1; k=rsa; " 	"p=MIGf"`, "v=DKIM1; k=rsa; p=MIGf"},
+		{`"say \"hi\" \\ bye"`, `say "hi" \ bye`},
+		{`""`, ""},
+		{`"unterminated`, `"unterminated`},
+		{`"trailing escape\`, `"trailing escape\`},
+		{`"quoted" unquoted`, `"quoted" unquoted`},
+		{`text with "quotes"`, `text with "quotes"`},
+	} {
+		assert.Equal(t, tc.want, unquoteTXT(tc.value), tc.value)
+	}
+}
+
+func TestSameDestination(t *testing.T) {
+	assert.True(t, TXTQuotingAll.sameDestination("TXT", "v=spf1 -all", `"v=spf1 " "-all"`))
+	assert.False(t, TXTQuotingAll.sameDestination("TXT", "example.com.", "example.com"))
+	assert.True(t, TXTQuotingAll.sameDestination("CNAME", "example.com.", "example.com"))
+	assert.False(t, TXTQuotingAll.sameDestination("A", "1.1.1.1", "1.1.1.2"))
+	// `"\"a\""` is written as `"a"`, which must still match although it reads as `a`
+	assert.True(t, TXTQuotingAll.sameDestination("TXT", `"\"a\""`, `"a"`))
+}
+
+func TestTXTQuotingRoundTrip(t *testing.T) {
+	heritage := `"heritage=external-dns,external-dns/owner=default"`
+	quoted := `"keep \"these\" quotes"`
+	recs := []nc.DnsRecord{
+		{Id: "1", Hostname: "www", Type: "TXT", Destination: heritage},
+		{Id: "2", Hostname: "app", Type: "TXT", Destination: quoted},
+	}
+
+	for _, tc := range []struct {
+		quoting TXTQuoting
+		text    string
+	}{
+		{TXTQuotingAll, `keep "these" quotes`},
+		{TXTQuotingHeritage, quoted},
+	} {
+		endpoints := recordsToEndpoints("example.com", 300, recs, tc.quoting)
+		assert.Equal(t, endpoint.Targets{tc.text}, endpoints[0].Targets, tc.quoting)
+		assert.Equal(t, endpoint.Targets{"heritage=external-dns,external-dns/owner=default"}, endpoints[1].Targets, tc.quoting)
+
+		// writing the endpoints back keeps the IDs and the values as read
+		written := *convertToNetcupRecord(newRecordIDs(recs, tc.quoting), endpoints, "example.com", false, tc.quoting)
+		assert.Equal(t, "2", written[0].Id, tc.quoting)
+		assert.Equal(t, tc.text, written[0].Destination, tc.quoting)
+		assert.Equal(t, "1", written[1].Id, tc.quoting)
+		assert.Equal(t, "heritage=external-dns,external-dns/owner=default", written[1].Destination, tc.quoting)
+	}
+
+	// with heritage quoting, removing the quotes of another TXT record is a change
+	assert.True(t, TXTQuotingAll.sameDestination("TXT", `"text"`, "text"))
+	assert.False(t, TXTQuotingHeritage.sameDestination("TXT", `"text"`, "text"))
+	assert.True(t, TXTQuotingHeritage.sameDestination("TXT", `"heritage=external-dns"`, "heritage=external-dns"))
+}
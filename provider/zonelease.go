@@ -0,0 +1,257 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the credentials of the service account of a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// leaseTimeFormat is the format of the MicroTime fields of a Lease.
+const leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// lease is the subset of a coordination.k8s.io/v1 Lease used for locking.
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+	} `json:"spec"`
+}
+
+// expired reports whether the holder of l failed to renew it in time.
+func (l *lease) expired(now time.Time) bool {
+	renewed, err := time.Parse(leaseTimeFormat, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// LeaseZoneLocker locks zones with a Kubernetes Lease per zone, which instances in other clusters
+// can share by using the same Kubernetes API. The lease is renewed while it is held; a lease not
+// renewed within its duration is taken over.
+type LeaseZoneLocker struct {
+	server    string
+	client    *http.Client
+	tokenFile string
+	namespace string
+	holder    string
+	ttl       time.Duration
+	logger    *slog.Logger
+}
+
+// NewInClusterLeaseZoneLocker returns a locker using the Kubernetes API of the cluster it runs in with
+// the service account of its pod. The leases are created in namespace, or the namespace of the pod
+// if empty.
+func NewInClusterLeaseZoneLocker(namespace, holder string, ttl time.Duration, logger *slog.Logger) (*LeaseZoneLocker, error) {
+	server, client, podNamespace, err := inClusterAPI()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = podNamespace
+	}
+	return newLeaseZoneLocker(server, client, serviceAccountDir+"/token", namespace, holder, ttl, logger), nil
+}
+
+// inClusterAPI returns the URL of the Kubernetes API of the cluster the pod runs in, a client
+// trusting its CA and the namespace of the pod. Requests authenticate with the token of the
+// service account in serviceAccountDir.
+func inClusterAPI() (string, *http.Client, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return "", nil, "", fmt.Errorf("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return "", nil, "", err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return "", nil, "", fmt.Errorf("no certificates found in %s/ca.crt", serviceAccountDir)
+	}
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return "", nil, "", err
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		Timeout:   10 * time.Second,
+	}
+	return "https://" + net.JoinHostPort(host, port), client, strings.TrimSpace(string(namespace)), nil
+}
+
+func newLeaseZoneLocker(server string, client *http.Client, tokenFile, namespace, holder string, ttl time.Duration, logger *slog.Logger) *LeaseZoneLocker {
+	return &LeaseZoneLocker{
+		server:    server,
+		client:    client,
+		tokenFile: tokenFile,
+		namespace: namespace,
+		holder:    holder,
+		ttl:       ttl,
+		logger:    logger,
+	}
+}
+
+// Lock implements ZoneLocker.
+func (l *LeaseZoneLocker) Lock(ctx context.Context, zone string) (func(), error) {
+	name := "external-dns-netcup-" + strings.ToLower(strings.TrimSuffix(zone, "."))
+	for {
+		acquired, err := l.update(ctx, name, func(current *lease, now time.Time) bool {
+			if current.Spec.HolderIdentity != "" && current.Spec.HolderIdentity != l.holder && !current.expired(now) {
+				return false
+			}
+			if current.Spec.HolderIdentity != "" && current.Spec.HolderIdentity != l.holder {
+				l.logger.Warn("taking over expired zone lease", "lease", name, "holder", current.Spec.HolderIdentity, "renewed", current.Spec.RenewTime)
+			}
+			current.Spec.HolderIdentity = l.holder
+			current.Spec.LeaseDurationSeconds = int(l.ttl.Seconds())
+			current.Spec.AcquireTime = now.Format(leaseTimeFormat)
+			current.Spec.RenewTime = now.Format(leaseTimeFormat)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	held := func(current *lease) bool { return current.Spec.HolderIdentity == l.holder }
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_, err := l.update(context.Background(), name, func(current *lease, now time.Time) bool {
+					current.Spec.RenewTime = now.Format(leaseTimeFormat)
+					return held(current)
+				})
+				if err != nil {
+					l.logger.Warn("unable to renew zone lease", "lease", name, "error", err.Error())
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		_, err := l.update(context.Background(), name, func(current *lease, _ time.Time) bool {
+			if !held(current) {
+				return false
+			}
+			current.Spec.HolderIdentity = ""
+			return true
+		})
+		if err != nil {
+			l.logger.Warn("unable to release zone lease", "lease", name, "error", err.Error())
+		}
+	}, nil
+}
+
+// update reads the lease name, creating it if missing, and writes it back if modify returns true.
+// It returns false if modify did or a concurrent update won.
+func (l *LeaseZoneLocker) update(ctx context.Context, name string, modify func(*lease, time.Time) bool) (bool, error) {
+	collection := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.server, l.namespace)
+	current := &lease{}
+	status, err := l.do(ctx, http.MethodGet, collection+"/"+name, nil, current)
+	if err != nil {
+		return false, err
+	}
+	method, url := http.MethodPut, collection+"/"+name
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		method, url = http.MethodPost, collection
+		current = &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		current.Metadata.Name = name
+		current.Metadata.Namespace = l.namespace
+	default:
+		return false, fmt.Errorf("unable to get lease '%s': status %d", name, status)
+	}
+	if !modify(current, time.Now()) {
+		return false, nil
+	}
+	status, err = l.do(ctx, method, url, current, nil)
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusConflict:
+		return false, nil
+	case status != http.StatusOK && status != http.StatusCreated:
+		return false, fmt.Errorf("unable to update lease '%s': status %d", name, status)
+	}
+	return true, nil
+}
+
+// do sends a request to the Kubernetes API and decodes a successful response into out.
+func (l *LeaseZoneLocker) do(ctx context.Context, method, url string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		content, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if l.tokenFile != "" {
+		// projected service account tokens are rotated, read the current one
+		token, err := os.ReadFile(l.tokenFile)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, err
+		}
+	}
+	return resp.StatusCode, nil
+}
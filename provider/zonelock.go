@@ -0,0 +1,144 @@
+package netcup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockRetryInterval is the time between attempts to take a zone lock held by another instance.
+const lockRetryInterval = 500 * time.Millisecond
+
+// ZoneLocker serializes the changes of instances sharing a zone, so one instance does not read
+// records another one is changing. Lock blocks until the lock of zone is taken or ctx is done and
+// returns a function releasing it.
+type ZoneLocker interface {
+	Lock(ctx context.Context, zone string) (unlock func(), err error)
+}
+
+// WithZoneLocker takes the lock of every zone while applying changes to it, waiting at most timeout
+// for instances holding it.
+func WithZoneLocker(locker ZoneLocker, timeout time.Duration) Option {
+	return func(p *NetcupProvider) {
+		p.zoneLocker = locker
+		p.zoneLockTimeout = timeout
+	}
+}
+
+// lockZone takes the lock of zone, if a locker is configured and changes are applied.
+func (p *NetcupProvider) lockZone(ctx context.Context, zone string) (func(), error) {
+	if p.zoneLocker == nil || p.shadow != nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	lockCtx, cancel := context.WithTimeout(ctx, p.zoneLockTimeout)
+	defer cancel()
+	unlock, err := p.zoneLocker.Lock(lockCtx, zone)
+	if err != nil {
+		return nil, &APIError{Kind: ErrZoneLocked, Err: fmt.Errorf("unable to lock zone '%s': %w", zone, err)}
+	}
+	p.logger.Debug("locked zone", "zone", zone, "waited", time.Since(start))
+	return unlock, nil
+}
+
+// FileZoneLocker locks zones by creating a file per zone in a directory, e.g. on a volume shared by
+// the instances. The file of a lock is touched while it is held; a file not touched within the TTL
+// is considered left behind by a crashed instance and removed.
+type FileZoneLocker struct {
+	dir    string
+	holder string
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+// NewFileZoneLocker returns a locker creating lock files in dir, creating it if necessary. holder
+// identifies this instance in the lock files.
+func NewFileZoneLocker(dir, holder string, ttl time.Duration, logger *slog.Logger) (*FileZoneLocker, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileZoneLocker{dir: dir, holder: holder, ttl: ttl, logger: logger}, nil
+}
+
+// Lock implements ZoneLocker.
+func (l *FileZoneLocker) Lock(ctx context.Context, zone string) (func(), error) {
+	path := filepath.Join(l.dir, filepath.Base(zone)+".lock")
+	for {
+		acquired, err := l.tryLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if err := os.Chtimes(path, now, now); err != nil {
+					l.logger.Warn("unable to renew zone lock", "zone", zone, "error", err.Error())
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		// only remove the lock if it was not taken over after expiring
+		if content, err := os.ReadFile(path); err == nil && bytes.Equal(content, []byte(l.holder)) {
+			if err := os.Remove(path); err != nil {
+				l.logger.Warn("unable to release zone lock", "zone", zone, "error", err.Error())
+			}
+		}
+	}, nil
+}
+
+// tryLock creates the lock file at path, removing it first if it expired.
+func (l *FileZoneLocker) tryLock(path string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err == nil {
+		_, err = f.Write([]byte(l.holder))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+			return false, err
+		}
+		return true, nil
+	}
+	if !os.IsExist(err) {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// released in the meantime
+		return false, nil
+	}
+	if time.Since(info.ModTime()) > l.ttl {
+		holder, _ := os.ReadFile(path)
+		l.logger.Warn("removing expired zone lock", "path", path, "holder", string(holder), "modified", info.ModTime())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
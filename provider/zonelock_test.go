@@ -0,0 +1,166 @@
+package netcup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// testZoneLocking checks that a and b exclude each other.
+func testZoneLocking(t *testing.T, a, b ZoneLocker) {
+	unlock, err := a.Lock(context.TODO(), "example.com")
+	assert.NoError(t, err)
+
+	// held by a
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	_, err = b.Lock(ctx, "example.com")
+	cancel()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// other zones are independent
+	unlockOther, err := b.Lock(context.TODO(), "example.org")
+	assert.NoError(t, err)
+	unlockOther()
+
+	// b takes the lock once a releases it
+	acquired := make(chan func())
+	go func() {
+		unlock, err := b.Lock(context.TODO(), "example.com")
+		assert.NoError(t, err)
+		acquired <- unlock
+	}()
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	select {
+	case unlock := <-acquired:
+		unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired after release")
+	}
+}
+
+func TestFileZoneLocker(t *testing.T) {
+	dir := t.TempDir()
+	logger := promslog.New(&promslog.Config{})
+	a, err := NewFileZoneLocker(dir, "a", time.Minute, logger)
+	assert.NoError(t, err)
+	b, err := NewFileZoneLocker(dir, "b", time.Minute, logger)
+	assert.NoError(t, err)
+	testZoneLocking(t, a, b)
+
+	// expired locks of crashed instances are taken over
+	path := filepath.Join(dir, "example.com.lock")
+	assert.NoError(t, os.WriteFile(path, []byte("crashed"), 0o640))
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(path, old, old))
+	unlock, err := a.Lock(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	unlock()
+	assert.NoFileExists(t, path)
+}
+
+// newLeaseServer serves the leases of the coordination.k8s.io API with optimistic concurrency.
+func newLeaseServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	leases := map[string]*lease{}
+	version := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		const prefix = "/apis/coordination.k8s.io/v1/namespaces/test/leases"
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		var in lease
+		if r.Body != nil {
+			_ = json.NewDecoder(r.Body).Decode(&in)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			current, ok := leases[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(current)
+			return
+		case http.MethodPost:
+			if _, ok := leases[in.Metadata.Name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			name = in.Metadata.Name
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			if current, ok := leases[name]; !ok || current.Metadata.ResourceVersion != in.Metadata.ResourceVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		version++
+		in.Metadata.ResourceVersion = strconv.Itoa(version)
+		leases[name] = &in
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLeaseZoneLocker(t *testing.T) {
+	server := newLeaseServer(t)
+	logger := promslog.New(&promslog.Config{})
+	a := newLeaseZoneLocker(server.URL, server.Client(), "", "test", "a", time.Minute, logger)
+	b := newLeaseZoneLocker(server.URL, server.Client(), "", "test", "b", time.Minute, logger)
+	testZoneLocking(t, a, b)
+
+	// expired leases of crashed instances are taken over
+	crashed := newLeaseZoneLocker(server.URL, server.Client(), "", "test", "crashed", time.Second, logger)
+	_, err := crashed.update(context.TODO(), "external-dns-netcup-example.com", func(current *lease, now time.Time) bool {
+		current.Spec.HolderIdentity = "crashed"
+		current.Spec.LeaseDurationSeconds = 1
+		current.Spec.RenewTime = now.Add(-time.Hour).Format(leaseTimeFormat)
+		return true
+	})
+	assert.NoError(t, err)
+	unlock, err := a.Lock(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestZoneLockedApply(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	locker, err := NewFileZoneLocker(t.TempDir(), "other", time.Minute, promslog.New(&promslog.Config{}))
+	assert.NoError(t, err)
+	domainFilter := []string{"example.com"}
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, promslog.New(&promslog.Config{}), WithAPIEndpoint(srv.URL), WithZoneLocker(locker, 100*time.Millisecond))
+	assert.NoError(t, err)
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")}}
+
+	// another instance holds the lock, external-dns retries later
+	unlock, err := locker.Lock(context.TODO(), "example.com")
+	assert.NoError(t, err)
+	err = p.ApplyChanges(context.TODO(), changes)
+	assert.ErrorIs(t, err, ErrZoneLocked)
+	assert.ErrorIs(t, err, provider.SoftError)
+	assert.Len(t, srv.Records("example.com"), 1)
+	unlock()
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Len(t, srv.Records("example.com"), 2)
+}
@@ -0,0 +1,84 @@
+package netcup
+
+import (
+	"context"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+)
+
+// RefreshZones checks which zones of the domain filter exist in the Netcup account and
+// swaps the managed zones accordingly. Zones which cannot be queried because of transport
+// errors keep their previous state.
+func (p *NetcupProvider) RefreshZones(ctx context.Context) error {
+	if p.dryRun {
+		p.logger.Debug("dry run - skipping zone refresh")
+		return nil
+	}
+
+	sessions := p.newSessionSet()
+	defer sessions.close()
+
+	current := map[string]bool{}
+	for _, zone := range p.managedZones() {
+		current[zone] = true
+	}
+
+	domains := p.shardZones(p.currentDomainFilter().Filters)
+	zones := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		session, err := sessions.forZone(domain)
+		if err != nil {
+			return err
+		}
+		prev := session.LastResponse
+		_, err = session.InfoDnsZone(domain)
+		switch {
+		case err == nil:
+			zones = append(zones, domain)
+		case session.LastResponse == prev || session.LastResponse.Status != string(nc.StatusError):
+			p.logger.Warn("unable to query zone, keeping previous state", "zone", domain, "error", err.Error())
+			if current[domain] {
+				zones = append(zones, domain)
+			}
+		default:
+			p.logger.Debug("zone not found in account", "zone", domain, "error", err.Error())
+		}
+	}
+
+	refreshed := map[string]bool{}
+	for _, zone := range zones {
+		refreshed[zone] = true
+		if !current[zone] {
+			p.logger.Info("zone discovered", "zone", zone)
+			zonesDiscoveredTotal.Inc()
+		}
+	}
+	for zone := range current {
+		if !refreshed[zone] {
+			p.logger.Info("zone removed", "zone", zone)
+			zonesRemovedTotal.Inc()
+		}
+	}
+
+	p.setManagedZones(zones)
+	managedZones.Set(float64(len(zones)))
+	return nil
+}
+
+// RunZoneRefresher refreshes the managed zones every interval until ctx is cancelled.
+func (p *NetcupProvider) RunZoneRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.RefreshZones(ctx); err != nil {
+			p.logger.Error("unable to refresh zones", "error", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package netcup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshZones(t *testing.T) {
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, p.managedZones())
+
+	// zone missing in account is removed
+	assert.NoError(t, p.RefreshZones(context.TODO()))
+	assert.Equal(t, []string{"example.com"}, p.managedZones())
+
+	// zone added to account is discovered
+	srv.AddZone("example.org", "300")
+	assert.NoError(t, p.RefreshZones(context.TODO()))
+	assert.Equal(t, []string{"example.com", "example.org"}, p.managedZones())
+
+	// failing login keeps the current zones
+	srv.FailAction("login", 4001)
+	assert.Error(t, p.RefreshZones(context.TODO()))
+	assert.Equal(t, []string{"example.com", "example.org"}, p.managedZones())
+}
+
+func TestZoneMetrics(t *testing.T) {
+	zoneTTL.Reset()
+	zoneSerial.Reset()
+	srv := netcuptest.NewServer()
+	defer srv.Close()
+	srv.AddZone("example.com", "300")
+	srv.AddZone("example.org", "600")
+
+	domainFilter := []string{"example.com", "example.org"}
+	logger := promslog.New(&promslog.Config{})
+	p, err := NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, WithAPIEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, float64(300), testutil.ToFloat64(zoneTTL.WithLabelValues("example.com")))
+	assert.Equal(t, float64(600), testutil.ToFloat64(zoneTTL.WithLabelValues("example.org")))
+	assert.Equal(t, float64(2024010101), testutil.ToFloat64(zoneSerial.WithLabelValues("example.com")))
+
+	// series of zones no longer managed are removed
+	assert.NoError(t, p.SetDomainFilter([]string{"example.com"}))
+	assert.Equal(t, 1, testutil.CollectAndCount(zoneTTL))
+	assert.Equal(t, 1, testutil.CollectAndCount(zoneSerial))
+}
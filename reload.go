@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+var (
+	configReloadsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Total number of configuration reloads by result (success, failure).",
+	}, []string{"result"})
+	configLastReloadSuccessful = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "config_last_reload_successful",
+		Help: "Whether the last configuration reload succeeded.",
+	})
+)
+
+// reloadableProvider serves the webhook with the current provider. On SIGHUP it builds a new
+// provider from the flags and files and swaps it in atomically, so the servers keep running.
+// A configuration that fails to load leaves the current provider in place.
+type reloadableProvider struct {
+	build   func() (*netcup.NetcupProvider, error)
+	logger  *slog.Logger
+	current atomic.Pointer[netcup.NetcupProvider]
+}
+
+// newReloadableProvider builds the initial provider.
+func newReloadableProvider(build func() (*netcup.NetcupProvider, error), logger *slog.Logger) (*reloadableProvider, error) {
+	p, err := build()
+	if err != nil {
+		return nil, err
+	}
+	r := &reloadableProvider{build: build, logger: logger}
+	r.current.Store(p)
+	configLastReloadSuccessful.Set(1)
+	return r, nil
+}
+
+// Load returns the current provider.
+func (r *reloadableProvider) Load() *netcup.NetcupProvider {
+	return r.current.Load()
+}
+
+// Run runs loops for the current provider and restarts them for the new provider after every
+// successful reload, until ctx is cancelled.
+func (r *reloadableProvider) Run(ctx context.Context, loops func(context.Context, *netcup.NetcupProvider)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		loopCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func(p *netcup.NetcupProvider) {
+			defer close(done)
+			loops(loopCtx, p)
+		}(r.Load())
+
+		reloaded := false
+		for !reloaded {
+			select {
+			case <-ctx.Done():
+				cancel()
+				<-done
+				return
+			case <-hup:
+				reloaded = r.reload()
+			}
+		}
+		cancel()
+		<-done
+	}
+}
+
+// reload replaces the current provider with a newly built one and reports whether it succeeded.
+func (r *reloadableProvider) reload() bool {
+	r.logger.Info("reloading configuration")
+	next, err := r.build()
+	if err != nil {
+		r.logger.Error("Failed to reload configuration, keeping the current one", "error", err.Error())
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		configLastReloadSuccessful.Set(0)
+		return false
+	}
+	current := r.Load()
+	next.TakeOver(current)
+	r.current.Store(next)
+	current.Retire()
+	if !reflect.DeepEqual(current.GetDomainFilter(), next.GetDomainFilter()) {
+		r.logger.Warn(netcup.DomainFilterRestartWarning)
+	}
+	configReloadsTotal.WithLabelValues("success").Inc()
+	configLastReloadSuccessful.Set(1)
+	r.logger.Info("configuration reloaded")
+	return true
+}
+
+// Records returns the records of the current provider.
+func (r *reloadableProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return r.Load().Records(ctx)
+}
+
+// StreamRecords streams the records of the current provider.
+func (r *reloadableProvider) StreamRecords(ctx context.Context, fn func([]*endpoint.Endpoint) error) error {
+	return r.Load().StreamRecords(ctx, fn)
+}
+
+// ApplyChanges applies changes with the current provider.
+func (r *reloadableProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	return r.Load().ApplyChanges(ctx, changes)
+}
+
+// AdjustEndpoints adjusts endpoints with the current provider.
+func (r *reloadableProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return r.Load().AdjustEndpoints(endpoints)
+}
+
+// GetDomainFilter returns the domain filter of the current provider.
+func (r *reloadableProvider) GetDomainFilter() endpoint.DomainFilterInterface {
+	return r.Load().GetDomainFilter()
+}
+
+// ManagedRecordTypes returns the record types managed by the current provider.
+func (r *reloadableProvider) ManagedRecordTypes() []string {
+	return r.Load().ManagedRecordTypes()
+}
+
+// Capabilities returns the features enabled in the current provider.
+func (r *reloadableProvider) Capabilities() netcup.Capabilities {
+	return r.Load().Capabilities()
+}
+
+// FailingZones returns the failing zones of the current provider.
+func (r *reloadableProvider) FailingZones() map[string]string {
+	return r.Load().FailingZones()
+}
+
+// APIHealth returns the API health of the current provider.
+func (r *reloadableProvider) APIHealth() netcup.APIHealth {
+	return r.Load().APIHealth()
+}
+
+// PlanChanges plans changes with the current provider.
+func (r *reloadableProvider) PlanChanges(ctx context.Context, changes *plan.Changes) (*netcup.ChangePlan, error) {
+	return r.Load().PlanChanges(ctx, changes)
+}
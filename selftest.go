@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+)
+
+var (
+	selftestZone       = selftestCmd.Flag("zone", "Zone to create the probe record in").Required().String()
+	selftestNameserver = selftestCmd.Flag("nameserver", "Nameserver to verify the probe record via live DNS, e.g. root-dns.netcup.net; skipped if empty").Default("").String()
+	selftestTimeout    = selftestCmd.Flag("timeout", "Time to wait for the probe record to become visible via DNS").Default("5m").Duration()
+)
+
+// runSelfTest creates, verifies and removes a probe record and exits non-zero on failure, with the
+// exit code telling rejected credentials and an unavailable Netcup API apart.
+func runSelfTest(logger *slog.Logger) {
+	var providerOptions []netcup.Option
+	if *zoneCredentialsFile != "" {
+		zoneCredentials, err := netcup.ReadZoneCredentialsFile(*zoneCredentialsFile)
+		if err != nil {
+			logger.Error("Failed to read zone credentials file", "path", *zoneCredentialsFile, "error", err.Error())
+			os.Exit(exitConfigError)
+		}
+		providerOptions = append(providerOptions, netcup.WithZoneCredentials(zoneCredentials))
+	}
+
+	credentials, err := defaultCredentials(context.Background())
+	if err != nil {
+		logger.Error("Failed to read credentials", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+	zones := []string{*selftestZone}
+	ncProvider, err := netcup.NewNetcupProvider(&zones, credentials.CustomerID, credentials.APIKey, credentials.APIPassword, *dryRun, logger, providerOptions...)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(exitConfigError)
+	}
+
+	if err := ncProvider.SelfTest(context.Background(), zones[0], *selftestNameserver, *selftestTimeout); err != nil {
+		logger.Error("self test failed", "zone", *selftestZone, "error", err.Error())
+		os.Exit(exitCodeForError(err))
+	}
+	logger.Info("self test succeeded", "zone", *selftestZone)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionEncoders pool the encoders of the supported content codings, most preferred first.
+var compressionEncoders = []struct {
+	coding string
+	pool   *sync.Pool
+}{
+	{"zstd", &sync.Pool{New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		return enc
+	}}},
+	{"gzip", &sync.Pool{New: func() any {
+		enc, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return enc
+	}}},
+}
+
+// compressionEncoder is the common interface of the gzip and zstd encoders.
+type compressionEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressHandler compresses the responses of next with zstd or gzip if the client accepts it.
+// external-dns' HTTP client asks for gzip and decompresses transparently.
+func CompressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accepted := acceptedCodings(r.Header.Get("Accept-Encoding"))
+		for _, e := range compressionEncoders {
+			if accepted[e.coding] && r.Method != http.MethodHead {
+				cw := &compressWriter{ResponseWriter: w, coding: e.coding, pool: e.pool}
+				defer cw.close()
+				next.ServeHTTP(cw, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptedCodings returns the content codings of an Accept-Encoding header not refused with q=0.
+func acceptedCodings(header string) map[string]bool {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok && strings.Trim(q, "0.") == "" {
+			continue
+		}
+		if coding != "" {
+			accepted[coding] = true
+		}
+	}
+	return accepted
+}
+
+// compressWriter compresses the body written to it, unless the response has no body or is
+// encoded already, e.g. by the metrics handler.
+type compressWriter struct {
+	http.ResponseWriter
+	coding      string
+	pool        *sync.Pool
+	enc         compressionEncoder
+	wroteHeader bool
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if statusCode >= http.StatusOK && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", w.coding)
+		h.Del("Content-Length")
+		w.enc = w.pool.Get().(compressionEncoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+// Flush sends the data compressed so far to the client, for streamed responses.
+func (w *compressWriter) Flush() {
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close terminates the compressed body and returns the encoder to its pool.
+func (w *compressWriter) close() {
+	if w.enc == nil {
+		return
+	}
+	_ = w.enc.Close()
+	w.enc.Reset(nil)
+	w.pool.Put(w.enc)
+	w.enc = nil
+}
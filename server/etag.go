@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var recordsNotModifiedTotal = metrics.NewCounter(prometheus.CounterOpts{
+	Name: "records_not_modified_total",
+	Help: "Total number of record requests answered with 304 Not Modified because the client had the current records.",
+})
+
+// etagHandler sends GET responses of next with an ETag hashing their body, and answers requests
+// whose If-None-Match holds the current ETag with 304 Not Modified. The response is buffered to
+// hash it, so next is no longer streamed to the client.
+func etagHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buffered := &bufferedResponse{header: http.Header{}}
+		next.ServeHTTP(buffered, r)
+
+		maps.Copy(w.Header(), buffered.header)
+		if buffered.statusCode == http.StatusOK {
+			sum := sha256.Sum256(buffered.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				recordsNotModifiedTotal.Inc()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(buffered.statusCode)
+		_, _ = w.Write(buffered.body.Bytes())
+	})
+}
+
+// etagMatches reports whether the If-None-Match header ifNoneMatch contains etag, comparing
+// weakly as RFC 9110 requires.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds a response in memory.
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(statusCode int) {
+	if b.statusCode == 0 {
+		b.statusCode = statusCode
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/prometheus/common/version"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
+)
+
+// statusForError maps provider errors to webhook status codes. external-dns retries on its next run
+// for status codes 500-510 and exits on every other status, so every provider error is answered
+// within that range; the kind of the error is told by the errorKindHeader.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, netcup.ErrRateLimited), errors.Is(err, netcup.ErrChangeRateExceeded), errors.Is(err, netcup.ErrZoneLocked),
+		errors.Is(err, netcup.ErrMaintenance):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, netcup.ErrBackendUnavailable):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorKinds name the kinds of provider errors in the errorKindHeader, most specific first.
+var errorKinds = []struct {
+	kind error
+	name string
+}{
+	{netcup.ErrValidation, "validation"},
+	{netcup.ErrAuth, "auth"},
+	{netcup.ErrZoneNotFound, "zone_not_found"},
+	{netcup.ErrRateLimited, "rate_limited"},
+	{netcup.ErrChangeRateExceeded, "change_rate_exceeded"},
+	{netcup.ErrZoneLocked, "zone_locked"},
+	{netcup.ErrMaintenance, "maintenance"},
+	{netcup.ErrBackendUnavailable, "backend_unavailable"},
+}
+
+// simulatedChangesHeader describes the changes a dry-run or shadow mode provider did not apply.
+const simulatedChangesHeader = "X-Netcup-Simulated-Changes"
+
+// errorKindHeader tells the kind of a provider error, e.g. auth or validation, as its status code
+// only tells whether external-dns retries it.
+const errorKindHeader = "X-Netcup-Error-Kind"
+
+// WriteProviderError responds with the status code of err and its kind, asking the client to
+// retry later if the provider knows when the Netcup API can be called again.
+func WriteProviderError(w http.ResponseWriter, err error) {
+	var apiErr *netcup.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			w.Header().Set(errorKindHeader, k.name)
+			break
+		}
+	}
+	http.Error(w, err.Error(), statusForError(err))
+}
+
+// logProviderError logs err of the provider. Errors during a maintenance of the Netcup API are
+// reported by the provider itself, so they are only logged for debugging.
+func logProviderError(logger *slog.Logger, msg string, err error) {
+	if errors.Is(err, netcup.ErrMaintenance) {
+		logger.Debug(msg, "error", err.Error())
+		return
+	}
+	logger.Error(msg, "error", err.Error())
+}
+
+// Provider is the provider served by the webhook API.
+type Provider interface {
+	provider.Provider
+	StreamRecords(ctx context.Context, fn func([]*endpoint.Endpoint) error) error
+	ManagedRecordTypes() []string
+	Capabilities() netcup.Capabilities
+	FailingZones() map[string]string
+	APIHealth() netcup.APIHealth
+}
+
+// buildInfo identifies the build of the webhook.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	GoVersion string `json:"goVersion"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version.Version,
+		Revision:  version.Revision,
+		Branch:    version.Branch,
+		GoVersion: version.GoVersion,
+	}
+}
+
+// CapabilitiesHandler serves the build and the capabilities of the provider as JSON.
+func CapabilitiesHandler(ncProvider Provider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		response := map[string]any{
+			"build":        currentBuildInfo(),
+			"capabilities": ncProvider.Capabilities(),
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error("Failed to encode capabilities", "error", err.Error())
+		}
+	}
+}
+
+// negotiateHandler serves / like webhook.WebhookServer.NegotiateHandler and adds the record types
+// managed by the provider, its capabilities and the build of the webhook to the domain filter.
+// external-dns only decodes the domain filter; the other fields inform other clients and operators.
+// The record types are enforced by adjustEndpointsHandler.
+func negotiateHandler(ncProvider Provider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := json.Marshal(ncProvider.GetDomainFilter())
+		if err != nil {
+			logger.Error("Failed to encode domain filter", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var response map[string]any
+		if err := json.Unmarshal(filter, &response); err != nil {
+			logger.Error("Failed to encode domain filter", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		response["recordTypes"] = ncProvider.ManagedRecordTypes()
+		response["capabilities"] = ncProvider.Capabilities()
+		response["build"] = currentBuildInfo()
+		w.Header().Set(webhook.ContentTypeHeader, webhook.MediaTypeFormatAndVersion)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error("Failed to encode negotiation response", "error", err.Error())
+		}
+	}
+}
+
+// endpointStream writes the endpoints of a /records response as a JSON array while they are read
+// zone by zone. The response starts with the first endpoint, so errors before it still get their
+// status code.
+type endpointStream struct {
+	w       http.ResponseWriter
+	started bool
+	count   int
+}
+
+func (s *endpointStream) start() error {
+	s.started = true
+	s.w.Header().Set(webhook.ContentTypeHeader, webhook.MediaTypeFormatAndVersion)
+	s.w.WriteHeader(http.StatusOK)
+	_, err := s.w.Write([]byte("["))
+	return err
+}
+
+// write appends endpoints to the array and flushes them to the client.
+func (s *endpointStream) write(endpoints []*endpoint.Endpoint) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	for _, ep := range endpoints {
+		if s.count > 0 {
+			buf.WriteByte(',')
+		}
+		item, err := json.Marshal(ep)
+		if err != nil {
+			return err
+		}
+		buf.Write(item)
+		s.count++
+	}
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(s.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// close terminates the array.
+func (s *endpointStream) close() error {
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.Write([]byte("]\n"))
+	return err
+}
+
+// adjustEndpointsHandler serves /adjustendpoints like webhook.WebhookServer.AdjustEndpointsHandler,
+// but responds to provider errors with the status code of their kind.
+func adjustEndpointsHandler(ncProvider Provider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			logger.Error("Unsupported method", "method", r.Method)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		endpoints := []*endpoint.Endpoint{}
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			logger.Error("Failed to decode endpoints", "error", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		adjusted, err := ncProvider.AdjustEndpoints(endpoints)
+		if err != nil {
+			logProviderError(logger, "Failed to adjust endpoints", err)
+			WriteProviderError(w, err)
+			return
+		}
+		w.Header().Set(webhook.ContentTypeHeader, webhook.MediaTypeFormatAndVersion)
+		if err := json.NewEncoder(w).Encode(&adjusted); err != nil {
+			logger.Error("Failed to encode adjusted endpoints", "error", err.Error())
+		}
+	}
+}
+
+// recordsHandler serves /records like webhook.WebhookServer.RecordsHandler, but responds to
+// provider errors with the status code of their kind. Records are streamed zone by zone.
+func recordsHandler(ncProvider Provider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			stream := &endpointStream{w: w}
+			err := ncProvider.StreamRecords(r.Context(), stream.write)
+			if err == nil {
+				err = stream.close()
+			}
+			if err != nil {
+				logProviderError(logger, "Failed to get records", err)
+				if !stream.started {
+					WriteProviderError(w, err)
+					return
+				}
+				// the status has been sent already, abort the response so the client
+				// does not mistake the endpoints received so far for all records
+				panic(http.ErrAbortHandler)
+			}
+		case http.MethodPost:
+			var changes plan.Changes
+			if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+				logger.Error("Failed to decode changes", "error", err.Error())
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var simulated netcup.SimulatedChanges
+			ctx := netcup.WithSimulationReport(r.Context(), &simulated)
+			if err := ncProvider.ApplyChanges(ctx, &changes); err != nil {
+				logProviderError(logger, "Failed to apply changes", err)
+				WriteProviderError(w, err)
+				return
+			}
+			// external-dns requires an empty 204 response, so simulated changes are described
+			// in a header
+			if simulated.Mode != "" {
+				if summary, err := json.Marshal(simulated); err == nil {
+					w.Header().Set(simulatedChangesHeader, string(summary))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			logger.Error("Unsupported method", "method", r.Method)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+}
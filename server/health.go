@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthUnknown  = "unknown"
+	healthDisabled = "disabled"
+)
+
+// healthReport is the JSON body of /healthz?format=json.
+type healthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentHealth `json:"components"`
+}
+
+// componentHealth is the status of a single component with details explaining it.
+type componentHealth struct {
+	Status           string            `json:"status"`
+	LastSuccess      *time.Time        `json:"lastSuccess,omitempty"`
+	LastError        string            `json:"lastError,omitempty"`
+	LastErrorTime    *time.Time        `json:"lastErrorTime,omitempty"`
+	RateLimitedUntil *time.Time        `json:"rateLimitedUntil,omitempty"`
+	Failing          map[string]string `json:"failing,omitempty"`
+}
+
+// healthzHandler reports the webhook as healthy, or as degraded if the Netcup API fails or applying
+// changes to some zones failed. A degraded webhook still responds with 200 as restarting it does not
+// help. Clients asking for JSON, or passing format=json, get the status of every component.
+func healthzHandler(ncProvider Provider, metricsEnabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := newHealthReport(ncProvider, metricsEnabled)
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(report)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if report.Status == healthOK {
+			_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
+			return
+		}
+		var problems []string
+		for _, name := range slices.Sorted(maps.Keys(report.Components)) {
+			c := report.Components[name]
+			switch {
+			case c.Status != healthDegraded:
+			case len(c.Failing) > 0:
+				problems = append(problems, "applying changes failed for zones "+strings.Join(slices.Sorted(maps.Keys(c.Failing)), ", "))
+			case c.RateLimitedUntil != nil:
+				problems = append(problems, name+" rate limited until "+c.RateLimitedUntil.Format(time.RFC3339))
+			default:
+				problems = append(problems, name+": "+c.LastError)
+			}
+		}
+		_, _ = fmt.Fprintf(w, "DEGRADED: %s", strings.Join(problems, "; "))
+	}
+}
+
+// newHealthReport collects the status of the components of the webhook.
+func newHealthReport(ncProvider Provider, metricsEnabled bool) *healthReport {
+	report := &healthReport{
+		Status: healthOK,
+		Components: map[string]componentHealth{
+			// the webhook server answers this request
+			"webhook": {Status: healthOK},
+			"metrics": {Status: healthDisabled},
+		},
+	}
+	if metricsEnabled {
+		report.Components["metrics"] = componentHealth{Status: healthOK}
+	}
+
+	api := ncProvider.APIHealth()
+	netcup := componentHealth{
+		Status:           healthOK,
+		LastSuccess:      timeOrNil(api.LastSuccess),
+		LastError:        api.LastError,
+		LastErrorTime:    timeOrNil(api.LastErrorTime),
+		RateLimitedUntil: timeOrNil(api.RateLimitedUntil),
+	}
+	switch {
+	case !api.RateLimitedUntil.IsZero(), api.LastErrorTime.After(api.LastSuccess):
+		netcup.Status = healthDegraded
+	case api.LastSuccess.IsZero():
+		netcup.Status = healthUnknown
+	}
+	report.Components["netcup"] = netcup
+
+	zones := componentHealth{Status: healthOK, Failing: ncProvider.FailingZones()}
+	if len(zones.Failing) > 0 {
+		zones.Status = healthDegraded
+	}
+	report.Components["zones"] = zones
+
+	for _, c := range report.Components {
+		if c.Status == healthDegraded {
+			report.Status = healthDegraded
+		}
+	}
+	return report
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
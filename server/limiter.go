@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	appliesWaiting = metrics.NewGauge(prometheus.GaugeOpts{
+		Name: "applies_waiting",
+		Help: "Number of change sets queued waiting for an earlier one to be applied.",
+	})
+	appliesRejectedTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "applies_rejected_total",
+		Help: "Total number of change sets rejected by the apply limiter by reason (duplicate, queue_full).",
+	}, []string{"reason"})
+)
+
+// applyLimiter limits the number of change sets applied concurrently. Further change sets wait in a
+// bounded queue; a change set identical to one in flight or queued is rejected, since it is most
+// likely a retry by external-dns. Rejections are answered with 503, as external-dns exits on
+// statuses outside 500-510 instead of retrying on its next run.
+type applyLimiter struct {
+	next    http.Handler
+	proxies []netip.Prefix
+	logger  *slog.Logger
+	slots   chan struct{}
+	admit   chan struct{}
+
+	mu      sync.Mutex
+	pending map[[sha256.Size]byte]bool
+}
+
+// newApplyLimiter allows concurrency change sets in flight and queueSize more to wait.
+func newApplyLimiter(next http.Handler, concurrency, queueSize int, proxies []netip.Prefix, logger *slog.Logger) *applyLimiter {
+	return &applyLimiter{
+		next:    next,
+		proxies: proxies,
+		logger:  logger,
+		slots:   make(chan struct{}, concurrency),
+		admit:   make(chan struct{}, concurrency+queueSize),
+		pending: map[[sha256.Size]byte]bool{},
+	}
+}
+
+func (l *applyLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		l.next.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := sha256.Sum256(body)
+
+	l.mu.Lock()
+	if l.pending[key] {
+		l.mu.Unlock()
+		appliesRejectedTotal.WithLabelValues("duplicate").Inc()
+		l.logger.Warn("rejecting change set identical to one already being applied", "client", ClientIP(r, l.proxies))
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "identical change set is already being applied", http.StatusServiceUnavailable)
+		return
+	}
+	l.pending[key] = true
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.pending, key)
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.admit <- struct{}{}:
+		defer func() { <-l.admit }()
+	default:
+		appliesRejectedTotal.WithLabelValues("queue_full").Inc()
+		l.logger.Warn("rejecting change set, apply queue is full", "client", ClientIP(r, l.proxies))
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many change sets waiting to be applied", http.StatusServiceUnavailable)
+		return
+	}
+
+	appliesWaiting.Inc()
+	select {
+	case l.slots <- struct{}{}:
+		appliesWaiting.Dec()
+		defer func() { <-l.slots }()
+	case <-r.Context().Done():
+		appliesWaiting.Dec()
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	l.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	webhookRequestsTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_requests_total",
+		Help: "Total number of requests to the webhook API by route, method and HTTP status code.",
+	}, []string{"route", "method", "code"})
+	webhookRequestDuration = metrics.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "webhook_request_duration_seconds",
+		Help:    "Duration of requests to the webhook API by route and method, until the response has been sent.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// Middleware wraps the handler of a route of the webhook API, e.g. to instrument it. It is applied
+// per route, so it may label what it records with the route.
+type Middleware func(route string, next http.Handler) http.Handler
+
+// AccessLog returns a middleware logging every request to the webhook API at debug level, with the
+// address of the client behind the trusted proxies.
+func AccessLog(proxies []netip.Prefix, logger *slog.Logger) Middleware {
+	return func(route string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			logger.Debug("served request", "route", route, "method", r.Method, "path", r.URL.Path, "status", sw.status, "duration", time.Since(start).String(), "client", ClientIP(r, proxies))
+		})
+	}
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.status = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer, so streamed responses
+// are still flushed.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// InstrumentRoute counts the requests to a route of the webhook API and measures their duration.
+// Streamed responses keep being flushed to the client.
+func InstrumentRoute(route string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerCounter(webhookRequestsTotal.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(webhookRequestDuration.MustCurryWith(labels), next))
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var httpPanicsTotal = metrics.NewCounter(prometheus.CounterOpts{
+	Name: "http_panics_total",
+	Help: "Total number of panics recovered while serving HTTP requests.",
+})
+
+// RecoverHandler turns a panic in next into a 500 response and logs it with its stack trace and
+// the address of the client behind the trusted proxies, instead of dropping the connection.
+func RecoverHandler(next http.Handler, proxies []netip.Prefix, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+				// deliberately aborted response, let net/http handle it
+				panic(err)
+			}
+			httpPanicsTotal.Inc()
+			logger.Error("panic while serving request", "method", r.Method, "path", r.URL.Path, "client", ClientIP(r, proxies), "panic", err, "stack", string(debug.Stack()))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
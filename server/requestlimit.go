@@ -0,0 +1,99 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mrueg/external-dns-netcup-webhook/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestsRateLimitedTotal = metrics.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_rate_limited_total",
+		Help: "Total number of requests for records (read) and change sets (apply) rejected by the inbound rate limit.",
+	}, []string{"kind"})
+	requestTokens = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "request_rate_limit_tokens",
+		Help: "Requests for records (read) and change sets (apply) that may be served at once before the inbound rate limit rejects them.",
+	}, []string{"kind"})
+)
+
+// tokenBucket allows rate requests per second on average and burst at once.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// take takes a token at now. It returns the tokens left, or the time until the next token if there
+// was none.
+func (b *tokenBucket) take(now time.Time) (float64, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return b.tokens, time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return b.tokens, 0, true
+}
+
+// RequestRateLimiter limits the requests for records and the change sets of external-dns, e.g.
+// when it runs with a far too short interval, before they reach the Netcup API. Requests beyond
+// the limit are answered with 503 and the time to wait in Retry-After: external-dns exits on
+// statuses outside 500-510, such as 429, instead of retrying on its next run.
+type RequestRateLimiter struct {
+	proxies []netip.Prefix
+	logger  *slog.Logger
+	buckets map[string]*tokenBucket
+}
+
+// NewRequestRateLimiter allows rate requests per second and burst at once, for reads and applies each.
+// Rejected requests are logged with the address of the client behind the trusted proxies.
+func NewRequestRateLimiter(rate float64, burst int, proxies []netip.Prefix, logger *slog.Logger) *RequestRateLimiter {
+	l := &RequestRateLimiter{proxies: proxies, logger: logger, buckets: map[string]*tokenBucket{}}
+	for _, kind := range []string{"read", "apply"} {
+		l.buckets[kind] = newTokenBucket(rate, burst)
+		requestTokens.WithLabelValues(kind).Set(float64(burst))
+	}
+	return l
+}
+
+// Limit is the middleware limiting the /records route.
+func (l *RequestRateLimiter) Limit(route string, next http.Handler) http.Handler {
+	if route != "/records" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := "read"
+		if r.Method == http.MethodPost {
+			kind = "apply"
+		}
+		tokens, wait, ok := l.buckets[kind].take(time.Now())
+		requestTokens.WithLabelValues(kind).Set(math.Floor(tokens))
+		if !ok {
+			requestsRateLimitedTotal.WithLabelValues(kind).Inc()
+			l.logger.Warn("rejecting request, inbound rate limit exceeded", "kind", kind, "retry_after", wait.String(), "client", ClientIP(r, l.proxies))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests, external-dns may be running with a too short --interval", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
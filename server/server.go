@@ -0,0 +1,218 @@
+// Package server builds the HTTP servers of the webhook: the webhook API external-dns talks to and
+// the server for metrics, capabilities and the landing page. Derivative webhooks, e.g. for Netcup
+// resellers, can serve their own Provider with the same routes, limits and instrumentation.
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// config holds the settings of the servers.
+type config struct {
+	logger         *slog.Logger
+	maxBodySize    int64
+	maxApplies     int
+	applyQueueSize int
+	metricsEnabled bool
+	recordsETag    bool
+	middlewares    []Middleware
+	trustedProxies []netip.Prefix
+	routePrefix    string
+	name           string
+	description    string
+}
+
+// Option configures the servers built by NewWebhookMux and NewMetricsMux.
+type Option func(*config)
+
+// WithLogger logs errors of the handlers and rejected requests to logger instead of discarding them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithMaxBodySize rejects request bodies larger than size bytes instead of 10MiB.
+func WithMaxBodySize(size int64) Option {
+	return func(c *config) {
+		c.maxBodySize = size
+	}
+}
+
+// WithApplyLimit applies at most concurrency change sets at once and lets queueSize more wait,
+// instead of one and one. Further change sets are rejected with 503.
+func WithApplyLimit(concurrency, queueSize int) Option {
+	return func(c *config) {
+		c.maxApplies = concurrency
+		c.applyQueueSize = queueSize
+	}
+}
+
+// WithMetricsEnabled reports the metrics server as enabled in the health check.
+func WithMetricsEnabled(enabled bool) Option {
+	return func(c *config) {
+		c.metricsEnabled = enabled
+	}
+}
+
+// WithRecordsETag answers requests for unchanged records with 304 Not Modified. The records are no
+// longer streamed to the client then. Only clients other than external-dns benefit, as external-dns
+// never sends If-None-Match.
+func WithRecordsETag(enabled bool) Option {
+	return func(c *config) {
+		c.recordsETag = enabled
+	}
+}
+
+// WithMiddlewares wraps every route of the webhook API with middlewares, the first one outermost.
+func WithMiddlewares(middlewares ...Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithTrustedProxies takes the client of requests from proxies from their X-Forwarded-For header
+// when logging rejected requests.
+func WithTrustedProxies(proxies []netip.Prefix) Option {
+	return func(c *config) {
+		c.trustedProxies = proxies
+	}
+}
+
+// WithRoutePrefix prepends prefix to the links of the landing page, for a metrics server served
+// below a path prefix.
+func WithRoutePrefix(prefix string) Option {
+	return func(c *config) {
+		c.routePrefix = prefix
+	}
+}
+
+// WithLandingPage sets the name and description shown on the landing page.
+func WithLandingPage(name, description string) Option {
+	return func(c *config) {
+		c.name = name
+		c.description = description
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		maxBodySize:    10 << 20,
+		maxApplies:     1,
+		applyQueueSize: 1,
+		name:           "external-dns-netcup-webhook",
+		description:    "external-dns webhook provider for Netcup",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Collectors returns the metrics of the servers, to be registered with the registry the metrics
+// server gathers.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		httpPanicsTotal,
+		appliesWaiting,
+		appliesRejectedTotal,
+		recordsNotModifiedTotal,
+		webhookRequestsTotal,
+		webhookRequestDuration,
+		requestsRateLimitedTotal,
+		requestTokens,
+	}
+}
+
+// NewMetricsMux creates the mux for the metrics of registry, the capabilities of the provider and
+// the landing page.
+func NewMetricsMux(registry prometheus.Gatherer, capabilities http.Handler, opts ...Option) *http.ServeMux {
+	c := newConfig(opts)
+	mux := http.NewServeMux()
+
+	var metricsPath = "/metrics"
+	var capabilitiesPath = "/capabilities"
+	var rootPath = "/"
+
+	// Add metricsPath
+	mux.Handle(metricsPath, promhttp.HandlerFor(
+		registry,
+		promhttp.HandlerOpts{
+			EnableOpenMetrics:                   true,
+			EnableOpenMetricsTextCreatedSamples: true,
+		}))
+	mux.Handle(capabilitiesPath, capabilities)
+
+	// Add index
+	landingConfig := web.LandingConfig{
+		Name:        c.name,
+		Description: c.description,
+		Version:     version.Info(),
+		Links: []web.LandingLinks{
+			{
+				Address: c.routePrefix + metricsPath,
+				Text:    "Metrics",
+			},
+			{
+				Address: c.routePrefix + capabilitiesPath,
+				Text:    "Capabilities",
+			},
+		},
+	}
+	landingPage, err := web.NewLandingPage(landingConfig)
+	if err != nil {
+		c.logger.Error("failed to create landing page", "error", err.Error())
+	}
+	mux.Handle(rootPath, landingPage)
+
+	return mux
+}
+
+// NewWebhookMux creates the mux for the webhook API serving ncProvider. Further routes, e.g. for
+// debugging, may be added to it.
+func NewWebhookMux(ncProvider Provider, opts ...Option) *http.ServeMux {
+	c := newConfig(opts)
+	mux := http.NewServeMux()
+
+	var rootPath = "/"
+	var healthzPath = "/healthz"
+	var recordsPath = "/records"
+	var adjustEndpointsPath = "/adjustendpoints"
+	var openAPIPath = "/openapi.json"
+
+	handle := func(route string, h http.Handler) {
+		for _, m := range slices.Backward(c.middlewares) {
+			h = m(route, h)
+		}
+		mux.Handle(route, h)
+	}
+
+	// Add healthzPath
+	handle(healthzPath, healthzHandler(ncProvider, c.metricsEnabled))
+
+	// Add openAPIPath
+	handle(openAPIPath, http.HandlerFunc(serveOpenAPISpec))
+
+	// Add negotiatePath
+	handle(rootPath, negotiateHandler(ncProvider, c.logger))
+	// Add adjustEndpointsPath
+	handle(adjustEndpointsPath, validateJSONBody(adjustEndpointsHandler(ncProvider, c.logger), c.maxBodySize, validateAdjustEndpoints, c.logger))
+	// Add recordsPath
+	var records http.Handler = recordsHandler(ncProvider, c.logger)
+	if c.recordsETag {
+		records = etagHandler(records)
+	}
+	handle(recordsPath, validateJSONBody(newApplyLimiter(records, c.maxApplies, c.applyQueueSize, c.trustedProxies, c.logger), c.maxBodySize, validateChanges, c.logger))
+
+	return mux
+}
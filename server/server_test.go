@@ -0,0 +1,424 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	nc "github.com/aellwein/netcup-dns-api/pkg/v1"
+	"github.com/klauspost/compress/zstd"
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+	"sigs.k8s.io/external-dns/provider/webhook"
+	webhookapi "sigs.k8s.io/external-dns/provider/webhook/api"
+)
+
+// The media type external-dns negotiates. An upstream bump of the webhook API changes it and
+// must be checked against the handlers before release.
+const pinnedMediaType = "application/external.dns.webhook+json;version=1"
+
+// newContractServer serves the webhook for example.com backed by a fake Netcup API and returns
+// external-dns's own webhook client for it, which has negotiated the media type already.
+func newContractServer(t *testing.T) (*netcuptest.Server, *httptest.Server, *webhook.WebhookProvider) {
+	api := netcuptest.NewServer()
+	t.Cleanup(api.Close)
+	api.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	zones := []string{"example.com"}
+	p, err := netcup.NewNetcupProvider(&zones, 10, "KEY", "PASSWORD", false, logger, netcup.WithAPIEndpoint(api.URL))
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(RecoverHandler(CompressHandler(NewWebhookMux(p, WithLogger(logger), WithMaxBodySize(1<<20), WithMiddlewares(InstrumentRoute))), nil, logger))
+	t.Cleanup(server.Close)
+	client, err := webhook.NewWebhookProvider(server.URL)
+	assert.NoError(t, err)
+	return api, server, client
+}
+
+func TestWebhookContract(t *testing.T) {
+	assert.Equal(t, pinnedMediaType, webhookapi.MediaTypeFormatAndVersion)
+	api, server, client := newContractServer(t)
+	adjusted0 := testutil.ToFloat64(webhookRequestsTotal.WithLabelValues("/adjustendpoints", "post", "200"))
+	applied0 := testutil.ToFloat64(webhookRequestsTotal.WithLabelValues("/records", "post", "204"))
+
+	assert.Equal(t, endpoint.NewDomainFilter([]string{"example.com"}), client.GetDomainFilter())
+	records, err := client.Records(context.TODO())
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "www.example.com", records[0].DNSName)
+		assert.Equal(t, endpoint.Targets{"1.1.1.1"}, records[0].Targets)
+	}
+
+	adjusted, err := client.AdjustEndpoints([]*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")})
+	assert.NoError(t, err)
+	if assert.Len(t, adjusted, 1) {
+		assert.Equal(t, "app.example.com", adjusted[0].DNSName)
+	}
+
+	changes := &plan.Changes{
+		Create:    adjusted,
+		UpdateOld: records,
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", endpoint.RecordTypeA, 300, "3.3.3.3")},
+	}
+	assert.NoError(t, client.ApplyChanges(context.TODO(), changes))
+	destinations := map[string]string{}
+	for _, rec := range api.Records("example.com") {
+		destinations[rec.Hostname] = rec.Destination
+	}
+	assert.Equal(t, map[string]string{"www": "3.3.3.3", "app": "2.2.2.2"}, destinations)
+
+	// the negotiation and records responses carry the negotiated media type
+	for _, path := range []string{"/", "/records"} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", pinnedMediaType)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, pinnedMediaType, resp.Header.Get(webhookapi.ContentTypeHeader), path)
+	}
+
+	// every route is instrumented
+	assert.Equal(t, adjusted0+1, testutil.ToFloat64(webhookRequestsTotal.WithLabelValues("/adjustendpoints", "post", "200")))
+	assert.Equal(t, applied0+1, testutil.ToFloat64(webhookRequestsTotal.WithLabelValues("/records", "post", "204")))
+}
+
+func TestWebhookCompression(t *testing.T) {
+	_, server, _ := newContractServer(t)
+
+	for _, tc := range []struct {
+		acceptEncoding string
+		coding         string
+	}{
+		// like external-dns, Go's client asks for gzip and decompresses transparently
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"zstd;q=0, gzip;q=0.5", "gzip"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/records", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", pinnedMediaType)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		var body io.Reader = resp.Body
+		switch resp.Header.Get("Content-Encoding") {
+		case "gzip":
+			body, err = gzip.NewReader(resp.Body)
+			assert.NoError(t, err)
+		case "zstd":
+			body, err = zstd.NewReader(resp.Body)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, tc.coding, resp.Header.Get("Content-Encoding"), tc.acceptEncoding)
+		var records []*endpoint.Endpoint
+		assert.NoError(t, json.NewDecoder(body).Decode(&records), tc.acceptEncoding)
+		assert.Len(t, records, 1, tc.acceptEncoding)
+		resp.Body.Close()
+	}
+
+	// empty responses stay unencoded
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/records", strings.NewReader(`{}`))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", pinnedMediaType)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestRecordsETag(t *testing.T) {
+	api := netcuptest.NewServer()
+	defer api.Close()
+	api.AddZone("example.com", "300", nc.DnsRecord{Hostname: "www", Type: "A", Destination: "1.1.1.1"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	zones := []string{"example.com"}
+	p, err := netcup.NewNetcupProvider(&zones, 10, "KEY", "PASSWORD", false, logger, netcup.WithAPIEndpoint(api.URL))
+	assert.NoError(t, err)
+	server := httptest.NewServer(NewWebhookMux(p, WithLogger(logger), WithRecordsETag(true)))
+	defer server.Close()
+
+	get := func(etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/records", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", pinnedMediaType)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	resp := get("")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// unchanged records are not sent again
+	resp = get(etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+	api.UpdateRecords("example.com", nc.DnsRecord{Hostname: "app", Type: "A", Destination: "2.2.2.2"})
+	resp = get(etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+}
+
+func TestWebhookContractErrors(t *testing.T) {
+	api, _, client := newContractServer(t)
+
+	// external-dns retries failures of the Netcup API on its next run
+	api.FailAction("infoDnsRecords", 5000)
+	_, err := client.Records(context.TODO())
+	assert.True(t, errors.Is(err, provider.SoftError), err)
+	api.FailAction("infoDnsRecords", 0)
+
+	// as well as changes Netcup rejects as invalid, external-dns would exit on any other status
+	api.FailAction("updateDnsRecords", 4013)
+	err = client.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("app.example.com", endpoint.RecordTypeA, "2.2.2.2")}})
+	assert.True(t, errors.Is(err, provider.SoftError), err)
+	api.FailAction("updateDnsRecords", 0)
+
+	// and rejected credentials
+	api.FailAction("login", 2011)
+	_, err = client.Records(context.TODO())
+	assert.True(t, errors.Is(err, provider.SoftError), err)
+}
+
+func TestWriteProviderError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code int
+		kind string
+	}{
+		{&netcup.APIError{Kind: netcup.ErrValidation, Err: errors.New("invalid")}, http.StatusInternalServerError, "validation"},
+		{&netcup.APIError{Kind: netcup.ErrAuth, Err: errors.New("rejected")}, http.StatusInternalServerError, "auth"},
+		{&netcup.APIError{Kind: netcup.ErrZoneNotFound, Err: errors.New("unknown")}, http.StatusInternalServerError, "zone_not_found"},
+		{&netcup.APIError{Kind: netcup.ErrMaintenance, Err: errors.New("maintenance")}, http.StatusServiceUnavailable, "maintenance"},
+		{&netcup.APIError{Kind: netcup.ErrBackendUnavailable, Err: errors.New("down")}, http.StatusBadGateway, "backend_unavailable"},
+		{errors.New("unexpected"), http.StatusInternalServerError, ""},
+	} {
+		rec := httptest.NewRecorder()
+		WriteProviderError(rec, tc.err)
+		assert.Equal(t, tc.code, rec.Code, tc.err)
+		assert.Equal(t, tc.kind, rec.Header().Get(errorKindHeader), tc.err)
+	}
+}
+
+// filterProvider reports a fixed domain filter.
+type filterProvider struct {
+	Provider
+	filter endpoint.DomainFilter
+}
+
+func (p filterProvider) GetDomainFilter() endpoint.DomainFilterInterface {
+	return p.filter
+}
+
+func (p filterProvider) ManagedRecordTypes() []string {
+	return []string{endpoint.RecordTypeA, endpoint.RecordTypeTXT}
+}
+
+func (p filterProvider) Capabilities() netcup.Capabilities {
+	return netcup.Capabilities{RecordTypes: p.ManagedRecordTypes(), MultiTarget: true, RecordCache: true}
+}
+
+func TestNegotiateDomainFilter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, filter := range []endpoint.DomainFilter{
+		endpoint.NewDomainFilter([]string{"example.com", "example.org"}),
+		endpoint.NewDomainFilterWithExclusions([]string{"example.com"}, []string{"internal.example.com"}),
+		endpoint.NewRegexDomainFilter(regexp.MustCompile(`\.example\.com$`), regexp.MustCompile(`^internal\.`)),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		negotiateHandler(filterProvider{filter: filter}, logger).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, pinnedMediaType, rec.Header().Get(webhookapi.ContentTypeHeader))
+
+		// external-dns decodes the full filter, and the record types added to it
+		var got endpoint.DomainFilter
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, filter, got)
+		var response struct {
+			RecordTypes  []string            `json:"recordTypes"`
+			Capabilities netcup.Capabilities `json:"capabilities"`
+			Build        buildInfo           `json:"build"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{endpoint.RecordTypeA, endpoint.RecordTypeTXT}, response.RecordTypes)
+		assert.Equal(t, filterProvider{}.Capabilities(), response.Capabilities)
+		assert.Equal(t, currentBuildInfo(), response.Build)
+	}
+}
+
+func TestWebhookSimulatedChanges(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	zones := []string{"example.com"}
+	p, err := netcup.NewNetcupProvider(&zones, 10, "KEY", "PASSWORD", true, logger)
+	assert.NoError(t, err)
+	server := httptest.NewServer(NewWebhookMux(p, WithLogger(logger)))
+	defer server.Close()
+
+	body := `{"Create":[{"dnsName":"app.example.com","recordType":"A","targets":["1.2.3.4"]}]}`
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/records", strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", pinnedMediaType)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.JSONEq(t, `{"mode":"dry-run","zones":{"example.com":{"create":1,"update":0,"delete":0}}}`, resp.Header.Get(simulatedChangesHeader))
+}
+
+func TestValidateJSONBody(t *testing.T) {
+	var received plan.Changes
+	handler := validateJSONBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}), 1<<10, validateChanges, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		// fields of a newer external-dns are ignored
+		{`{"Create":[{"dnsName":"a.example.com","recordType":"A","targets":["1.1.1.1"],"newField":true}],"NewChanges":[]}`, http.StatusOK},
+		{`{"Create":[{"dnsName":"a.example.com"}]}`, http.StatusBadRequest},
+		{`{"Create":`, http.StatusBadRequest},
+		{`{"UpdateOld":[{"dnsName":"a.example.com","recordType":"A"}]}`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(tc.body)))
+		assert.Equal(t, tc.code, rec.Code, tc.body)
+	}
+	assert.Equal(t, "a.example.com", received.Create[0].DNSName)
+}
+
+func TestApplyLimiter(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+	limiter := newApplyLimiter(blocking, 1, 0, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	duplicates := testutil.ToFloat64(appliesRejectedTotal.WithLabelValues("duplicate"))
+	queueFull := testutil.ToFloat64(appliesRejectedTotal.WithLabelValues("queue_full"))
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		limiter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(`{"Create":[]}`)))
+		done <- rec.Code
+	}()
+	<-started
+
+	// duplicates and change sets beyond the queue are retried by external-dns on its next run
+	for _, body := range []string{`{"Create":[]}`, `{"Delete":[]}`} {
+		rec := httptest.NewRecorder()
+		limiter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(body)))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, body)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"), body)
+	}
+	assert.Equal(t, duplicates+1, testutil.ToFloat64(appliesRejectedTotal.WithLabelValues("duplicate")))
+	assert.Equal(t, queueFull+1, testutil.ToFloat64(appliesRejectedTotal.WithLabelValues("queue_full")))
+
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-done)
+}
+
+func TestRequestRateLimiter(t *testing.T) {
+	bucket := newTokenBucket(2, 2)
+	now := time.Now()
+	for range 2 {
+		_, _, ok := bucket.take(now)
+		assert.True(t, ok)
+	}
+	_, wait, ok := bucket.take(now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	_, _, ok = bucket.take(now.Add(500 * time.Millisecond))
+	assert.True(t, ok)
+
+	// reads and applies are limited separately, other routes not at all
+	limiter := NewRequestRateLimiter(0.001, 1, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ok200 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	records := limiter.Limit("/records", ok200)
+	adjust := limiter.Limit("/adjustendpoints", ok200)
+	for _, tc := range []struct {
+		handler http.Handler
+		method  string
+		code    int
+	}{
+		{records, http.MethodGet, http.StatusOK},
+		{records, http.MethodGet, http.StatusServiceUnavailable},
+		{records, http.MethodPost, http.StatusOK},
+		{records, http.MethodPost, http.StatusServiceUnavailable},
+		{adjust, http.MethodPost, http.StatusOK},
+		{adjust, http.MethodPost, http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, httptest.NewRequest(tc.method, "/", nil))
+		assert.Equal(t, tc.code, rec.Code)
+		if tc.code == http.StatusServiceUnavailable {
+			assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+		}
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(requestTokens.WithLabelValues("apply")))
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	assert.NoError(t, err)
+	_, err = ParseTrustedProxies([]string{"proxy"})
+	assert.Error(t, err)
+
+	for _, tc := range []struct {
+		remoteAddr string
+		forwarded  []string
+		client     string
+	}{
+		// the header of untrusted peers is ignored
+		{"192.0.2.1:1234", []string{"198.51.100.1"}, "192.0.2.1"},
+		{"10.0.0.1:1234", nil, "10.0.0.1"},
+		{"10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"[::1]:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		// addresses added by trusted proxies are skipped, the one added by the client is not trusted
+		{"10.0.0.1:1234", []string{"203.0.113.7, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"10.0.0.1:1234", []string{"203.0.113.7", "198.51.100.1"}, "198.51.100.1"},
+		{"10.0.0.1:1234", []string{"garbage"}, "10.0.0.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/records", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for _, value := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		assert.Equal(t, tc.client, ClientIP(req, proxies), tc)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// validationError is the body of a 400 response for a malformed request.
+type validationError struct {
+	Error string `json:"error"`
+}
+
+// validateJSONBody rejects POST requests whose body exceeds maxBytes, is not valid JSON for T, or
+// fails validate. Valid bodies are passed on to next unchanged. Fields unknown to T are logged and
+// ignored, so a newer external-dns sending additional fields is not rejected with a status it does
+// not retry.
+func validateJSONBody[T any](next http.Handler, maxBytes int64, validate func(T) error, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeValidationError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", maxBytes))
+				return
+			}
+			writeValidationError(w, http.StatusBadRequest, fmt.Errorf("unable to read request body: %v", err))
+			return
+		}
+
+		var target T
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&target)
+		if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+			logger.Warn("ignoring unknown fields in request body", "path", r.URL.Path, "error", err.Error())
+			target = *new(T)
+			decoder = json.NewDecoder(bytes.NewReader(body))
+			err = decoder.Decode(&target)
+		}
+		if err != nil {
+			writeValidationError(w, http.StatusBadRequest, fmt.Errorf("malformed request body: %v", err))
+			return
+		}
+		if decoder.More() {
+			writeValidationError(w, http.StatusBadRequest, errors.New("malformed request body: unexpected data after JSON value"))
+			return
+		}
+		if err := validate(target); err != nil {
+			writeValidationError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeValidationError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(validationError{Error: err.Error()})
+}
+
+// validateEndpoints checks that every endpoint has a name and a record type.
+func validateEndpoints(field string, endpoints []*endpoint.Endpoint) error {
+	for i, ep := range endpoints {
+		switch {
+		case ep == nil:
+			return fmt.Errorf("%s[%d]: endpoint must not be null", field, i)
+		case ep.DNSName == "":
+			return fmt.Errorf("%s[%d]: dnsName must not be empty", field, i)
+		case ep.RecordType == "":
+			return fmt.Errorf("%s[%d]: recordType must not be empty", field, i)
+		}
+	}
+	return nil
+}
+
+// validateChanges checks the endpoints of a change set and that every update has a current and desired state.
+func validateChanges(changes plan.Changes) error {
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return fmt.Errorf("UpdateOld and UpdateNew must have the same length, got %d and %d", len(changes.UpdateOld), len(changes.UpdateNew))
+	}
+	if err := validateEndpoints("Create", changes.Create); err != nil {
+		return err
+	}
+	if err := validateEndpoints("UpdateOld", changes.UpdateOld); err != nil {
+		return err
+	}
+	if err := validateEndpoints("UpdateNew", changes.UpdateNew); err != nil {
+		return err
+	}
+	return validateEndpoints("Delete", changes.Delete)
+}
+
+// validateAdjustEndpoints checks the endpoints passed to /adjustendpoints.
+func validateAdjustEndpoints(endpoints []*endpoint.Endpoint) error {
+	return validateEndpoints("endpoints", endpoints)
+}
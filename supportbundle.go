@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/common/version"
+)
+
+var (
+	bundleOutput     = supportBundleCmd.Flag("output", "Path of the support bundle tarball").Default("").String()
+	bundleWebhookURL = supportBundleCmd.Flag("webhook-url", "Base URL of the running webhook server").Default("http://localhost:8888").String()
+	bundleMetricsURL = supportBundleCmd.Flag("metrics-url", "URL of the metrics endpoint of the running webhook").Default("http://localhost:8889/metrics").String()
+)
+
+// secretFlagMarkers identify flags whose values must never end up in a support bundle.
+var secretFlagMarkers = []string{"password", "api-key", "token", "secret"}
+
+// runSupportBundle collects diagnostic data from the running webhook into a tarball with all secrets scrubbed.
+func runSupportBundle(logger *slog.Logger) {
+	output := *bundleOutput
+	if output == "" {
+		output = fmt.Sprintf("support-bundle-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	files := map[string][]byte{
+		"version.txt": []byte(version.Print("external-dns-netcup-webhook") + "\n"),
+		"config.json": sanitizedConfig(),
+	}
+	var failures []string
+	client := &http.Client{Timeout: 30 * time.Second}
+	for name, url := range map[string]string{
+		"metrics.txt":  *bundleMetricsURL,
+		"changes.json": strings.TrimSuffix(*bundleWebhookURL, "/") + "/debug/changes",
+		"logs.jsonl":   strings.TrimSuffix(*bundleWebhookURL, "/") + "/debug/logs",
+	} {
+		content, err := fetch(client, url, *debugToken)
+		if err != nil {
+			logger.Warn("unable to collect support bundle file", "file", name, "url", url, "error", err.Error())
+			failures = append(failures, fmt.Sprintf("%s: %s: %v", name, url, err))
+			continue
+		}
+		files[name] = content
+	}
+	if len(failures) > 0 {
+		files["errors.txt"] = []byte(strings.Join(failures, "\n") + "\n")
+	}
+
+	if err := writeBundle(output, files); err != nil {
+		logger.Error("Failed to write support bundle", "path", output, "error", err.Error())
+		os.Exit(exitFailure)
+	}
+	logger.Info("support bundle written", "path", output)
+}
+
+// sanitizedConfig returns the effective flag values as JSON with secrets replaced by their fingerprint.
+func sanitizedConfig() []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(effectiveConfig())
+	return buf.Bytes()
+}
+
+// effectiveConfig returns the value of every flag with secrets replaced by their fingerprint. The
+// built-in help, version and completion flags are left out.
+func effectiveConfig() map[string]string {
+	config := map[string]string{}
+	for _, s := range configs.settings() {
+		config[s.Name] = s.Value
+	}
+	return config
+}
+
+// displayValue returns the value of f, with secrets replaced by their fingerprint.
+func displayValue(f *kingpin.FlagModel) string {
+	value := f.String()
+	for _, marker := range secretFlagMarkers {
+		if strings.Contains(f.Name, marker) && value != "" {
+			value = secretFingerprint(value)
+		}
+	}
+	return string(scrubSecrets([]byte(value)))
+}
+
+// secretFingerprint identifies a secret by a short prefix of its SHA-256 hash, so operators can tell
+// which credentials are in use without learning their content or length.
+func secretFingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// loadedSecrets holds the secrets read at runtime, e.g. credentials from a secret store, which
+// scrubSecrets removes along with the secrets of the flags.
+var loadedSecrets struct {
+	sync.Mutex
+	values []string
+}
+
+// registerSecrets makes scrubSecrets remove secrets.
+func registerSecrets(secrets ...string) {
+	loadedSecrets.Lock()
+	defer loadedSecrets.Unlock()
+	for _, secret := range secrets {
+		if secret != "" && !slices.Contains(loadedSecrets.values, secret) {
+			loadedSecrets.values = append(loadedSecrets.values, secret)
+		}
+	}
+}
+
+// scrubSecrets removes any literal occurrence of the configured secrets from content.
+// Very short values are skipped as they would redact unrelated text.
+func scrubSecrets(content []byte) []byte {
+	loadedSecrets.Lock()
+	secrets := append([]string{*apiKey, *apiPassword, *approvalToken, *debugToken}, loadedSecrets.values...)
+	loadedSecrets.Unlock()
+	for _, secret := range secrets {
+		if len(secret) >= 8 {
+			content = bytes.ReplaceAll(content, []byte(secret), []byte("<redacted>"))
+		}
+	}
+	return content
+}
+
+// fetch gets url, with token as bearer token if set.
+func fetch(client *http.Client, url, token string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return scrubSecrets(content), nil
+}
+
+func writeBundle(path string, files map[string][]byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), ModTime: now}); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
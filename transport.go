@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/common/version"
+)
+
+// configureTransport tunes the default HTTP transport, which the Netcup API client uses for all
+// requests to the CCP API, makes it identify the webhook in the User-Agent header and counts the
+// requests by CCP status code.
+// With --log-api-payloads, the payloads of all requests are logged at trace level.
+func configureTransport(logger *slog.Logger) {
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.MaxIdleConns = *httpMaxIdleConns
+		transport.MaxIdleConnsPerHost = *httpMaxIdleConnsPerHost
+		transport.IdleConnTimeout = *httpIdleConnTimeout
+		transport.TLSHandshakeTimeout = *httpTLSHandshakeTimeout
+		transport.DisableKeepAlives = *httpDisableKeepAlives
+	}
+	http.DefaultTransport = &userAgentTransport{
+		next:      http.DefaultTransport,
+		userAgent: userAgent(*userAgentSuffix),
+	}
+	http.DefaultTransport = &apiMetricsTransport{next: http.DefaultTransport}
+	if *logAPIPayloads {
+		http.DefaultTransport = &payloadLogTransport{
+			next:   http.DefaultTransport,
+			logger: logger,
+		}
+	}
+}
+
+// userAgent returns the User-Agent of the webhook followed by suffix, if set.
+func userAgent(suffix string) string {
+	ua := "external-dns-netcup-webhook"
+	if version.Version != "" {
+		ua += "/" + version.Version
+	}
+	if suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+// userAgentTransport sets the User-Agent header of requests that do not have one.
+type userAgentTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("User-Agent") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(r)
+}
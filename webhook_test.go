@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	netcup "github.com/mrueg/external-dns-netcup-webhook/provider"
+	"github.com/mrueg/external-dns-netcup-webhook/provider/netcuptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretFingerprint(t *testing.T) {
+	assert.Equal(t, "sha256:2bb80d53", secretFingerprint("secret"))
+	assert.NotContains(t, secretFingerprint("secret"), "secret")
+}
+
+func TestExitCodeForError(t *testing.T) {
+	assert.Equal(t, exitAuthError, exitCodeForError(fmt.Errorf("login failed: %w", &netcup.APIError{Kind: netcup.ErrAuth, Err: errors.New("rejected")})))
+	assert.Equal(t, exitAPIUnavailable, exitCodeForError(&netcup.APIError{Kind: netcup.ErrRateLimited, Err: errors.New("throttled")}))
+	assert.Equal(t, exitAPIUnavailable, exitCodeForError(errors.Join(&netcup.APIError{Kind: netcup.ErrBackendUnavailable, Err: errors.New("down")})))
+	assert.Equal(t, exitFailure, exitCodeForError(errors.New("probe record not visible")))
+}
+
+func TestFlagSources(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "NETCUP_ZONE"), []byte("example.com\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "owner"), []byte("file-owner"), 0o600))
+	// the files only fill unset environment variables, the cleanup of t.Setenv unsets it again
+	t.Setenv("NETCUP_ZONE", "")
+	assert.NoError(t, os.Unsetenv("NETCUP_ZONE"))
+	t.Setenv("NETCUP_OWNER", "env-owner")
+	t.Setenv("NETCUP_CONFIG_DIR", "")
+
+	app := kingpin.New("test", "")
+	app.Flag("config-dir", "").Envar("NETCUP_CONFIG_DIR").String()
+	app.Flag("zone", "").Envar("NETCUP_ZONE").String()
+	app.Flag("owner", "").Envar("NETCUP_OWNER").String()
+	app.Flag("interval", "").Default("1m").Envar("NETCUP_INTERVAL").Duration()
+	app.Flag("dry-run", "").Envar("NETCUP_DRY_RUN").Bool()
+	r := newFlagSources(app, []string{"--config-dir=" + dir, "--no-dry-run"})
+	_, err := r.parse()
+	assert.NoError(t, err)
+
+	sources := map[string]setting{}
+	for _, s := range r.settings() {
+		sources[s.Name] = s
+	}
+	assert.Equal(t, setting{Name: "config-dir", Value: dir, Source: sourceFlag, Origin: "--config-dir"}, sources["config-dir"])
+	assert.Equal(t, setting{Name: "zone", Value: "example.com", Source: sourceConfigDir, Origin: filepath.Join(dir, "NETCUP_ZONE")}, sources["zone"])
+	// the environment takes precedence over the configuration directory
+	assert.Equal(t, setting{Name: "owner", Value: "env-owner", Source: sourceEnv, Origin: "NETCUP_OWNER"}, sources["owner"])
+	assert.Equal(t, setting{Name: "interval", Value: "1m0s", Source: sourceDefault}, sources["interval"])
+	assert.Equal(t, setting{Name: "dry-run", Value: "false", Source: sourceFlag, Origin: "--no-dry-run"}, sources["dry-run"])
+}
+
+func TestCredentialsSourceNotLogged(t *testing.T) {
+	const secretKey, secretPassword = "source-api-key-1234", "source-api-password-1234"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := fmt.Sprintf(`{"customerID":"10","apiKey":%q,"apiPassword":%q}`, secretKey, secretPassword)
+		_ = json.NewEncoder(w).Encode(map[string]string{"SecretString": secret})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+
+	// like --log-api-payloads
+	var logs bytes.Buffer
+	logger := slog.New(&traceHandler{slog.NewTextHandler(&logs, nil)})
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = &payloadLogTransport{next: defaultTransport, logger: logger}
+	defer func() { http.DefaultTransport = defaultTransport }()
+	source := *credentialsSource
+	*credentialsSource = "aws-secretsmanager://netcup?region=eu-central-1"
+	defer func() { *credentialsSource = source }()
+
+	credentials, err := defaultCredentials(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, secretKey, credentials.APIKey)
+	assert.Empty(t, logs.String())
+	// fetched secrets are scrubbed from payloads that do pass the payload log
+	resp, err := http.Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Contains(t, logs.String(), "API response")
+	assert.NotContains(t, logs.String(), secretKey)
+	assert.NotContains(t, logs.String(), secretPassword)
+	assert.Equal(t, "key <redacted>", string(scrubSecrets([]byte("key "+secretKey))))
+}
+
+func TestRequireToken(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "debug-token", nil, logger)
+	for _, tc := range []struct {
+		auth string
+		code int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer debug-token", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/logs", nil)
+		req.Header.Set("Authorization", tc.auth)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, tc.code, rec.Code, tc.auth)
+	}
+}
+
+func TestReloadLogsOutSharedSessions(t *testing.T) {
+	api := netcuptest.NewServer()
+	defer api.Close()
+	api.AddZone("example.com", "300")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	build := func() (*netcup.NetcupProvider, error) {
+		domainFilter := []string{"example.com"}
+		return netcup.NewNetcupProvider(&domainFilter, 10, "KEY", "PASSWORD", false, logger, netcup.WithAPIEndpoint(api.URL), netcup.WithSessionKeepAlive(time.Hour))
+	}
+	providers, err := newReloadableProvider(build, logger)
+	assert.NoError(t, err)
+	_, err = providers.Records(context.TODO())
+	assert.NoError(t, err)
+
+	assert.True(t, providers.reload())
+	assert.Equal(t, 1, api.Calls("logout"), "the session of the replaced provider is logged out")
+
+	_, err = providers.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, api.Calls("login"))
+	providers.Load().Close()
+	assert.Equal(t, 2, api.Calls("logout"))
+}